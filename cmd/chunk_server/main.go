@@ -1,10 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"simple-s3-adventure/internal/chunk_server/api"
 	"simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/pkg/logger"
 )
 
 func main() {
-	api.StartServer(service.NewServerConfig())
+	ctx, cancel := context.WithCancelCause(context.Background())
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		<-c
+		// A second signal kills the chunk server without waiting for the shutdown
+		signal.Stop(c)
+		logger.GetLogger().Debug("terminate chunk server")
+		cancel(errors.New("app termination by sigterm"))
+	}()
+
+	api.StartServer(ctx, service.NewServerConfig())
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"simple-s3-adventure/internal/front_server/placement_sim"
+)
+
+func main() {
+	var (
+		steps          = flag.Int("steps", 10000, "number of operations to simulate")
+		numParts       = flag.Int("parts", 6, "number of chunks per file")
+		initialServers = flag.Int("servers", 8, "number of chunk servers at start")
+		joinEvery      = flag.Int("join-every", 0, "add a chunk server every N steps (0 disables joins)")
+		maxServers     = flag.Int("max-servers", 0, "maximum number of chunk servers (0 means no limit)")
+		deleteProb     = flag.Float64("delete-prob", 0, "probability that a step deletes a random file")
+		sizes          = flag.String("sizes", "lognormal:1048576:1.5", "file size distribution: fixed:<size>, uniform:<min>:<max> or lognormal:<median>:<sigma>")
+		fillFactor     = flag.Float64("fill-factor", 0, "registry fill factor (0 keeps the default)")
+		reportEvery    = flag.Int("report-every", 100, "take a snapshot every N steps")
+		seed           = flag.Int64("seed", 1, "random seed")
+		format         = flag.String("format", "csv", "output format: csv or json")
+		output         = flag.String("output", "", "output file (stdout if empty)")
+	)
+	flag.Parse()
+
+	if err := run(placement_sim.Config{
+		Steps:             *steps,
+		NumParts:          *numParts,
+		InitialServers:    *initialServers,
+		JoinEvery:         *joinEvery,
+		MaxServers:        *maxServers,
+		DeleteProbability: *deleteProb,
+		FillFactor:        *fillFactor,
+		ReportEvery:       *reportEvery,
+		Seed:              *seed,
+	}, *sizes, *format, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "placement_sim:", err)
+		os.Exit(1)
+	}
+}
+
+func run(config placement_sim.Config, sizes, format, output string) error {
+	dist, err := placement_sim.ParseSizeDistribution(sizes)
+	if err != nil {
+		return err
+	}
+	config.Sizes = dist
+
+	sim, err := placement_sim.NewSimulator(config)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if output != "" {
+		out, err = os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer out.Close()
+	}
+
+	reporter, err := placement_sim.NewReporter(format, out)
+	if err != nil {
+		return err
+	}
+	if err := sim.Run(reporter.Report); err != nil {
+		return err
+	}
+	return reporter.Close()
+}
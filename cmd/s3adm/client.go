@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"simple-s3-adventure/pkg/cluster"
+)
+
+const requestTimeout = 5 * time.Minute
+
+// client sends requests to the front server.
+type client struct {
+	server     string
+	token      string
+	httpClient *http.Client
+}
+
+// newClient returns a client of the front server at server. The admin endpoints require the cluster token.
+func newClient(server string, token string) *client {
+	return &client{
+		server:     strings.TrimSuffix(server, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// do sends the request and decodes the JSON response into v unless v is nil.
+func (c *client) do(method string, path string, params url.Values, v any) error {
+	resp, err := c.send(method, path, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// send sends the request and returns the response if it is successful. The caller must close the body.
+func (c *client) send(method string, path string, params url.Values) (*http.Response, error) {
+	u := c.server + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	cluster.SetToken(req, c.token)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
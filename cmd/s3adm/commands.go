@@ -0,0 +1,464 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"text/tabwriter"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/front_service"
+	"simple-s3-adventure/internal/front_server/rebalance_service"
+)
+
+// pollInterval is how often a running scrub or garbage collection is checked with -wait.
+const pollInterval = time.Second
+
+// admin runs commands and prints their results as tables or JSON.
+type admin struct {
+	client *client
+	json   bool
+	out    io.Writer
+}
+
+func (a *admin) run(command string, args []string) error {
+	switch command {
+	case "servers":
+		return a.servers(args)
+	case "stats":
+		return a.stats(args)
+	case "drain":
+		return a.drain(http.MethodPut, args)
+	case "drain-status":
+		return a.drain(http.MethodGet, args)
+	case "drain-cancel":
+		return a.drain(http.MethodDelete, args)
+	case "remove":
+		return a.remove(args)
+	case "rebalance":
+		return a.rebalance(args)
+	case "scrub":
+		return a.scrub(args)
+	case "scrub-status":
+		return a.scrubStatus(args)
+	case "gc":
+		return a.gc(args)
+	case "gc-status":
+		return a.gcStatus(args)
+	case "layout":
+		return a.layout(args)
+	case "verify":
+		return a.verify(args)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func expectArgs(args []string, names ...string) error {
+	if len(args) != len(names) {
+		if len(names) == 0 {
+			return errors.New("unexpected arguments")
+		}
+		return fmt.Errorf("expected arguments: %v", names)
+	}
+	return nil
+}
+
+// print writes v as JSON in JSON mode, otherwise renders it as a table.
+func (a *admin) print(v any, table func(w *tabwriter.Writer)) error {
+	if a.json {
+		encoder := json.NewEncoder(a.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+func (a *admin) servers(args []string) error {
+	if err := expectArgs(args); err != nil {
+		return err
+	}
+	var servers []front_service.ChunkServerInfo
+	if err := a.client.do(http.MethodGet, "/admin/chunk_servers", nil, &servers); err != nil {
+		return err
+	}
+	return a.print(servers, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ADDRESS\tID\tSTATE\tMODE\tBYTES\tCHUNKS")
+		for _, s := range servers {
+			mode := s.Mode
+			if s.Offline {
+				mode = "offline"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\n", s.Address, s.ID, s.State, mode, s.Bytes, s.Chunks)
+		}
+	})
+}
+
+func (a *admin) stats(args []string) error {
+	if err := expectArgs(args); err != nil {
+		return err
+	}
+	var stats front_service.ClusterStats
+	if err := a.client.do(http.MethodGet, "/stats", nil, &stats); err != nil {
+		return err
+	}
+	if a.json {
+		return a.print(stats, nil)
+	}
+	return stats.WriteText(a.out)
+}
+
+func (a *admin) drain(method string, args []string) error {
+	if err := expectArgs(args, "url"); err != nil {
+		return err
+	}
+	var status rebalance_service.DrainStatus
+	if err := a.client.do(method, "/admin/chunk_servers/drain", url.Values{"url": {args[0]}}, &status); err != nil {
+		return err
+	}
+	return a.print(status, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ADDRESS\tSTATE\tINITIAL BYTES\tREMAINING BYTES\tREMAINING CHUNKS\tPROGRESS")
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%.1f%%\n", status.Address, status.State,
+			status.InitialBytes, status.RemainingBytes, status.RemainingChunks, status.Progress*100)
+	})
+}
+
+func (a *admin) remove(args []string) error {
+	if err := expectArgs(args, "url"); err != nil {
+		return err
+	}
+	if err := a.client.do(http.MethodDelete, "/admin/chunk_servers", url.Values{"url": {args[0]}}, nil); err != nil {
+		return err
+	}
+	return a.print(map[string]string{"removed": args[0]}, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Chunk server %s removed\n", args[0])
+	})
+}
+
+func (a *admin) rebalance(args []string) error {
+	method, path := http.MethodGet, "/admin/rebalance"
+	if len(args) == 1 && (args[0] == "pause" || args[0] == "resume") {
+		method, path = http.MethodPut, "/admin/rebalance/"+args[0]
+	} else if err := expectArgs(args); err != nil {
+		return err
+	}
+
+	var status rebalance_service.Status
+	if err := a.client.do(method, path, nil, &status); err != nil {
+		return err
+	}
+	return a.print(status, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Paused:\t%t\n", status.Paused)
+		fmt.Fprintf(w, "Active:\t%t\n", status.Active)
+		fmt.Fprintf(w, "Moved chunks:\t%d\n", status.MovedChunks)
+		fmt.Fprintf(w, "Moved bytes:\t%d\n", status.MovedBytes)
+		fmt.Fprintf(w, "Failed moves:\t%d\n", status.FailedMoves)
+		if m := status.CurrentMove; m != nil {
+			fmt.Fprintf(w, "Current move:\t%s chunk %d (%d bytes) %s -> %s, %s\n", m.FileUUID, m.Index, m.Size, m.From, m.To, m.Reason)
+		}
+		if status.LastError != "" {
+			fmt.Fprintf(w, "Last error:\t%s\n", status.LastError)
+		}
+	})
+}
+
+func (a *admin) scrub(args []string) error {
+	flags := flag.NewFlagSet("scrub", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "copy damaged replicas again from healthy ones")
+	wait := flags.Bool("wait", false, "wait until the scrub is finished")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := expectArgs(flags.Args()); err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	if *repair {
+		params.Set("repair", "true")
+	}
+	var status rebalance_service.ScrubStatus
+	if err := a.client.do(http.MethodPut, "/admin/scrub", params, &status); err != nil {
+		return err
+	}
+
+	for *wait && status.Running {
+		time.Sleep(pollInterval)
+		if err := a.client.do(http.MethodGet, "/admin/scrub", nil, &status); err != nil {
+			return err
+		}
+	}
+	return a.printScrub(status)
+}
+
+func (a *admin) scrubStatus(args []string) error {
+	if err := expectArgs(args); err != nil {
+		return err
+	}
+	var status rebalance_service.ScrubStatus
+	if err := a.client.do(http.MethodGet, "/admin/scrub", nil, &status); err != nil {
+		return err
+	}
+	return a.printScrub(status)
+}
+
+func (a *admin) printScrub(status rebalance_service.ScrubStatus) error {
+	return a.print(status, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Running:\t%t\n", status.Running)
+		fmt.Fprintf(w, "Repair:\t%t\n", status.Repair)
+		if status.StartedAt != nil {
+			fmt.Fprintf(w, "Started:\t%s\n", status.StartedAt.Format(time.RFC3339))
+		}
+		if status.FinishedAt != nil {
+			fmt.Fprintf(w, "Finished:\t%s\n", status.FinishedAt.Format(time.RFC3339))
+		}
+		fmt.Fprintf(w, "Objects:\t%d\n", status.Objects)
+		fmt.Fprintf(w, "Replicas:\t%d\n", status.Replicas)
+		fmt.Fprintf(w, "Problems:\t%d\n", len(status.Problems))
+		if status.Error != "" {
+			fmt.Fprintf(w, "Error:\t%s\n", status.Error)
+		}
+		if len(status.Problems) == 0 {
+			return
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "UUID\tCHUNK\tSERVER\tERROR\tREPAIRED")
+		for _, p := range status.Problems {
+			repaired := fmt.Sprint(p.Repaired)
+			if p.RepairError != "" {
+				repaired = "no: " + p.RepairError
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", p.FileUUID, p.Index, p.Server, p.Error, repaired)
+		}
+	})
+}
+
+func (a *admin) gc(args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report orphan chunks, don't delete them")
+	wait := flags.Bool("wait", false, "wait until the garbage collection is finished")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := expectArgs(flags.Args()); err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	if *dryRun {
+		params.Set("dry_run", "true")
+	}
+	var status rebalance_service.GCStatus
+	if err := a.client.do(http.MethodPut, "/admin/gc", params, &status); err != nil {
+		return err
+	}
+
+	for *wait && status.Running {
+		time.Sleep(pollInterval)
+		if err := a.client.do(http.MethodGet, "/admin/gc", nil, &status); err != nil {
+			return err
+		}
+	}
+	return a.printGC(status)
+}
+
+func (a *admin) gcStatus(args []string) error {
+	if err := expectArgs(args); err != nil {
+		return err
+	}
+	var status rebalance_service.GCStatus
+	if err := a.client.do(http.MethodGet, "/admin/gc", nil, &status); err != nil {
+		return err
+	}
+	return a.printGC(status)
+}
+
+func (a *admin) printGC(status rebalance_service.GCStatus) error {
+	return a.print(status, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Running:\t%t\n", status.Running)
+		fmt.Fprintf(w, "Dry run:\t%t\n", status.DryRun)
+		if status.StartedAt != nil {
+			fmt.Fprintf(w, "Started:\t%s\n", status.StartedAt.Format(time.RFC3339))
+		}
+		if status.FinishedAt != nil {
+			fmt.Fprintf(w, "Finished:\t%s\n", status.FinishedAt.Format(time.RFC3339))
+		}
+		fmt.Fprintf(w, "Servers:\t%d\n", status.Servers)
+		fmt.Fprintf(w, "Chunks:\t%d\n", status.Chunks)
+		fmt.Fprintf(w, "Orphans:\t%d (%d bytes)\n", status.OrphanChunks, status.OrphanBytes)
+		fmt.Fprintf(w, "Deleted:\t%d (%d bytes)\n", status.DeletedChunks, status.DeletedBytes)
+		for _, err := range status.Errors {
+			fmt.Fprintf(w, "Server error:\t%s\n", err)
+		}
+		if status.Error != "" {
+			fmt.Fprintf(w, "Error:\t%s\n", status.Error)
+		}
+		if len(status.Orphans) == 0 {
+			return
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "UUID\tCHUNK\tSERVER\tSIZE\tMODIFIED\tDELETED")
+		for _, o := range status.Orphans {
+			deleted := fmt.Sprint(o.Deleted)
+			if o.Error != "" {
+				deleted = "no: " + o.Error
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\t%s\n", o.FileUUID, o.Index, o.Server, o.Size, o.ModTime.Format(time.RFC3339), deleted)
+		}
+	})
+}
+
+func (a *admin) layout(args []string) error {
+	if err := expectArgs(args, "uuid"); err != nil {
+		return err
+	}
+	var layout front_service.ObjectLayout
+	if err := a.client.do(http.MethodGet, "/admin/objects", url.Values{"uuid": {args[0]}}, &layout); err != nil {
+		return err
+	}
+	return a.print(layout, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "UUID:\t%s\n", layout.UUID)
+		fmt.Fprintf(w, "Size:\t%d\n", layout.Size)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "CHUNK\tSIZE\tSERVERS")
+		for _, chunk := range layout.Chunks {
+			for i, server := range chunk.Servers {
+				if i == 0 {
+					fmt.Fprintf(w, "%d\t%d\t%s\n", chunk.Index, chunk.Size, server)
+				} else {
+					fmt.Fprintf(w, "\t\t%s\n", server)
+				}
+			}
+		}
+	})
+}
+
+// verification combines the replica report of the front server with a download of the object
+// through the public API.
+type verification struct {
+	rebalance_service.ObjectReport
+	Download download `json:"download"`
+}
+
+type download struct {
+	OK     bool     `json:"ok"`
+	Size   int64    `json:"size"`
+	SHA256 []string `json:"chunk_sha256"`
+	Error  string   `json:"error,omitempty"`
+}
+
+func (a *admin) verify(args []string) error {
+	if err := expectArgs(args, "uuid"); err != nil {
+		return err
+	}
+	uuid := args[0]
+
+	var v verification
+	if err := a.client.do(http.MethodGet, "/admin/objects/verify", url.Values{"uuid": {uuid}}, &v.ObjectReport); err != nil {
+		return err
+	}
+	v.Download = a.download(uuid, v.ObjectReport)
+
+	err := a.print(v, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "UUID:\t%s\n", v.UUID)
+		fmt.Fprintf(w, "Size:\t%d\n", v.Size)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "CHUNK\tSIZE\tREPLICA\tSTATUS\tSHA256")
+		for i, chunk := range v.Chunks {
+			for _, replica := range chunk.Replicas {
+				status := "ok"
+				downloaded := i < len(v.Download.SHA256)
+				switch {
+				case !replica.OK:
+					status = replica.Error
+				case downloaded && replica.SHA256 != v.Download.SHA256[i]:
+					status = "differs from download"
+				case !chunk.Consistent && downloaded:
+					status = "matches download"
+				case !chunk.Consistent:
+					status = "replicas differ"
+				}
+				fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\n", chunk.Index, chunk.Size, replica.Server, status, shortHash(replica.SHA256))
+			}
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Replicas:\t%s\n", okOrDamaged(v.Healthy))
+		download := okOrDamaged(v.Download.OK)
+		if v.Download.Error != "" {
+			download += ": " + v.Download.Error
+		}
+		fmt.Fprintf(w, "Download:\t%s\n", download)
+	})
+	if err != nil {
+		return err
+	}
+	if !v.Healthy || !v.Download.OK {
+		return errors.New("object is damaged")
+	}
+	return nil
+}
+
+// download reads the object through the front server and checks that every chunk matches its replicas.
+func (a *admin) download(uuid string, report rebalance_service.ObjectReport) download {
+	var d download
+
+	resp, err := a.client.send(http.MethodGet, "/get", url.Values{"uuid": {uuid}})
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	defer resp.Body.Close()
+
+	for _, chunk := range report.Chunks {
+		hash := sha256.New()
+		n, err := io.CopyN(hash, resp.Body, chunk.Size)
+		d.Size += n
+		if err != nil {
+			d.Error = fmt.Sprintf("chunk %d: %v", chunk.Index, err)
+			return d
+		}
+		d.SHA256 = append(d.SHA256, hex.EncodeToString(hash.Sum(nil)))
+	}
+	if n, _ := io.Copy(io.Discard, resp.Body); n > 0 {
+		d.Size += n
+		d.Error = fmt.Sprintf("received %d bytes more than expected", n)
+		return d
+	}
+
+	for i, chunk := range report.Chunks {
+		matched := false
+		for _, replica := range chunk.Replicas {
+			matched = matched || replica.OK && replica.SHA256 == d.SHA256[i]
+		}
+		if !matched {
+			d.Error = fmt.Sprintf("chunk %d does not match any replica", chunk.Index)
+			return d
+		}
+	}
+	d.OK = true
+	return d
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 16 {
+		return hash[:16]
+	}
+	return hash
+}
+
+func okOrDamaged(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "damaged"
+}
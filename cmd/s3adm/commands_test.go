@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"simple-s3-adventure/internal/front_server/front_service"
+	"simple-s3-adventure/internal/front_server/rebalance_service"
+	"simple-s3-adventure/pkg/cluster"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFront answers every path with a canned response and records the requests. Admin requests without the
+// cluster token are rejected.
+type fakeFront struct {
+	mu        sync.Mutex
+	requests  []string
+	responses map[string]any
+}
+
+func (f *fakeFront) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+	response, ok := f.responses[r.URL.Path]
+	f.mu.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/admin/") && !cluster.ValidToken(r, testToken) {
+		http.Error(w, "Invalid cluster token", http.StatusUnauthorized)
+		return
+	}
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	switch response := response.(type) {
+	case string:
+		w.Write([]byte(response))
+	default:
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+const testToken = "secret"
+
+func newTestAdmin(t *testing.T, responses map[string]any) (*admin, *fakeFront, *bytes.Buffer) {
+	t.Helper()
+	front := &fakeFront{responses: responses}
+	server := httptest.NewServer(front)
+	t.Cleanup(server.Close)
+	var out bytes.Buffer
+	return &admin{client: newClient(server.URL, testToken), out: &out}, front, &out
+}
+
+func TestAdmin_Dispatch(t *testing.T) {
+	tests := []struct {
+		command  string
+		args     []string
+		expected string
+	}{
+		{"servers", nil, "GET /admin/chunk_servers"},
+		{"stats", nil, "GET /stats"},
+		{"drain", []string{"http://cs:12090"}, "PUT /admin/chunk_servers/drain?url=http%3A%2F%2Fcs%3A12090"},
+		{"drain-status", []string{"http://cs:12090"}, "GET /admin/chunk_servers/drain?url=http%3A%2F%2Fcs%3A12090"},
+		{"drain-cancel", []string{"http://cs:12090"}, "DELETE /admin/chunk_servers/drain?url=http%3A%2F%2Fcs%3A12090"},
+		{"remove", []string{"http://cs:12090"}, "DELETE /admin/chunk_servers?url=http%3A%2F%2Fcs%3A12090"},
+		{"rebalance", nil, "GET /admin/rebalance"},
+		{"rebalance", []string{"pause"}, "PUT /admin/rebalance/pause"},
+		{"rebalance", []string{"resume"}, "PUT /admin/rebalance/resume"},
+		{"scrub", []string{"-repair"}, "PUT /admin/scrub?repair=true"},
+		{"scrub-status", nil, "GET /admin/scrub"},
+		{"gc", []string{"-dry-run"}, "PUT /admin/gc?dry_run=true"},
+		{"gc-status", nil, "GET /admin/gc"},
+		{"layout", []string{"69d973de-c7ba-4856-9e54-773bb0e58546"}, "GET /admin/objects?uuid=69d973de-c7ba-4856-9e54-773bb0e58546"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command+" "+strings.Join(tt.args, " "), func(t *testing.T) {
+			a, front, _ := newTestAdmin(t, map[string]any{
+				"/admin/chunk_servers":       "[]",
+				"/stats":                     "{}",
+				"/admin/chunk_servers/drain": "{}",
+				"/admin/rebalance":           "{}",
+				"/admin/rebalance/pause":     "{}",
+				"/admin/rebalance/resume":    "{}",
+				"/admin/scrub":               "{}",
+				"/admin/gc":                  "{}",
+				"/admin/objects":             "{}",
+			})
+
+			require.NoError(t, a.run(tt.command, tt.args))
+			assert.Equal(t, []string{tt.expected}, front.requests)
+		})
+	}
+}
+
+func TestAdmin_InvalidArguments(t *testing.T) {
+	a, front, _ := newTestAdmin(t, nil)
+
+	assert.EqualError(t, a.run("unknown", nil), `unknown command "unknown"`)
+	assert.EqualError(t, a.run("servers", []string{"extra"}), "unexpected arguments")
+	assert.EqualError(t, a.run("drain", nil), "expected arguments: [url]")
+	assert.EqualError(t, a.run("rebalance", []string{"stop"}), "unexpected arguments")
+	assert.Error(t, a.run("gc", []string{"-unknown"}))
+	assert.Empty(t, front.requests)
+}
+
+func TestAdmin_ErrorResponse(t *testing.T) {
+	a, _, _ := newTestAdmin(t, nil)
+
+	err := a.run("layout", []string{"69d973de-c7ba-4856-9e54-773bb0e58546"})
+	assert.ErrorContains(t, err, "GET /admin/objects: 404 Not Found: not found")
+}
+
+func TestAdmin_InvalidToken(t *testing.T) {
+	a, _, _ := newTestAdmin(t, map[string]any{"/admin/chunk_servers": "[]"})
+	a.client.token = "other"
+
+	err := a.run("servers", nil)
+	assert.ErrorContains(t, err, "GET /admin/chunk_servers: 401 Unauthorized: Invalid cluster token")
+}
+
+var testServers = []front_service.ChunkServerInfo{
+	{ID: "node-1", Address: "http://cs1:12090", State: "active", Mode: "read_write", Bytes: 100, Chunks: 2},
+	{ID: "node-2", Address: "http://cs2:12090", State: "draining", Mode: "read_only", Offline: true, Bytes: 50, Chunks: 1},
+}
+
+func TestAdmin_ServersTable(t *testing.T) {
+	a, _, out := newTestAdmin(t, map[string]any{"/admin/chunk_servers": testServers})
+
+	require.NoError(t, a.run("servers", nil))
+	assert.Equal(t, strings.Join([]string{
+		"ADDRESS           ID      STATE     MODE        BYTES  CHUNKS",
+		"http://cs1:12090  node-1  active    read_write  100    2",
+		"http://cs2:12090  node-2  draining  offline     50     1",
+		"",
+	}, "\n"), out.String())
+}
+
+func TestAdmin_ServersJSON(t *testing.T) {
+	a, _, out := newTestAdmin(t, map[string]any{"/admin/chunk_servers": testServers})
+	a.json = true
+
+	require.NoError(t, a.run("servers", nil))
+	var servers []front_service.ChunkServerInfo
+	require.NoError(t, json.Unmarshal(out.Bytes(), &servers))
+	assert.Equal(t, testServers, servers)
+}
+
+func TestAdmin_LayoutTable(t *testing.T) {
+	layout := front_service.ObjectLayout{
+		UUID: "69d973de-c7ba-4856-9e54-773bb0e58546",
+		Size: 30,
+		Chunks: []front_service.ChunkLayout{
+			{Index: 0, Size: 20, Servers: []string{"http://cs1:12090", "http://cs2:12090"}},
+			{Index: 1, Size: 10, Servers: []string{"http://cs2:12090"}},
+		},
+	}
+	a, _, out := newTestAdmin(t, map[string]any{"/admin/objects": layout})
+
+	require.NoError(t, a.run("layout", []string{layout.UUID}))
+	assert.Equal(t, strings.Join([]string{
+		"UUID:  69d973de-c7ba-4856-9e54-773bb0e58546",
+		"Size:  30",
+		"",
+		"CHUNK  SIZE  SERVERS",
+		"0      20    http://cs1:12090",
+		"             http://cs2:12090",
+		"1      10    http://cs2:12090",
+		"",
+	}, "\n"), out.String())
+}
+
+func sha256Hex(s string) string {
+	hash := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestAdmin_Verify(t *testing.T) {
+	report := rebalance_service.ObjectReport{
+		UUID:    "69d973de-c7ba-4856-9e54-773bb0e58546",
+		Size:    10,
+		Healthy: true,
+		Chunks: []rebalance_service.ChunkReport{
+			{Index: 0, Size: 5, Consistent: true, Replicas: []rebalance_service.ReplicaReport{
+				{Server: "http://cs1:12090", OK: true, Size: 5, SHA256: sha256Hex("hello")},
+			}},
+			{Index: 1, Size: 5, Consistent: true, Replicas: []rebalance_service.ReplicaReport{
+				{Server: "http://cs2:12090", OK: true, Size: 5, SHA256: sha256Hex("world")},
+			}},
+		},
+	}
+
+	t.Run("healthy", func(t *testing.T) {
+		a, _, out := newTestAdmin(t, map[string]any{"/admin/objects/verify": report, "/get": "helloworld"})
+		a.json = true
+
+		require.NoError(t, a.run("verify", []string{report.UUID}))
+		var v verification
+		require.NoError(t, json.Unmarshal(out.Bytes(), &v))
+		assert.True(t, v.Download.OK)
+		assert.Equal(t, int64(10), v.Download.Size)
+		assert.Equal(t, []string{sha256Hex("hello"), sha256Hex("world")}, v.Download.SHA256)
+	})
+
+	t.Run("download differs", func(t *testing.T) {
+		a, _, out := newTestAdmin(t, map[string]any{"/admin/objects/verify": report, "/get": "helloWORLD"})
+
+		assert.EqualError(t, a.run("verify", []string{report.UUID}), "object is damaged")
+		assert.Contains(t, out.String(), "differs from download")
+		assert.Contains(t, out.String(), "Download:  damaged: chunk 1 does not match any replica")
+	})
+}
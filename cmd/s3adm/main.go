@@ -0,0 +1,55 @@
+// s3adm is the admin CLI for the cluster. It talks to the admin endpoints of the front server.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"simple-s3-adventure/pkg/config"
+)
+
+const usage = `Usage: s3adm [flags] <command> [arguments]
+
+Commands:
+  servers                  list chunk servers with their state and mode
+  stats                    show data distribution across chunk servers
+  drain <url>              start draining a chunk server
+  drain-status <url>       show draining progress
+  drain-cancel <url>       make a draining chunk server active again
+  remove <url>             remove a drained chunk server
+  rebalance [pause|resume] show, pause or resume the rebalancer
+  scrub [-repair] [-wait]  check that all chunk replicas are intact, optionally repair them
+  scrub-status             show the status of the last scrub
+  gc [-dry-run] [-wait]    delete chunks that no object refers to, or only list them
+  gc-status                show the status of the last garbage collection
+  layout <uuid>            show the chunks of an object and where they are stored
+  verify <uuid>            read every replica of an object and download it end-to-end
+
+Flags:
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	server := flag.String("server", config.GetEnvString("S3ADM_SERVER", "http://localhost:13090"), "front server address")
+	output := flag.String("o", "table", "output format: table or json")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "s3adm: unknown output format %q\n", *output)
+		os.Exit(2)
+	}
+
+	a := &admin{client: newClient(*server, config.GetEnvString("CLUSTER_TOKEN", "")), json: *output == "json", out: os.Stdout}
+	if err := a.run(flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "s3adm:", err)
+		os.Exit(1)
+	}
+}
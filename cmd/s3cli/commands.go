@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"simple-s3-adventure/pkg/client"
+)
+
+const (
+	remotePrefix    = "s3://"
+	defaultParallel = 4
+)
+
+// usageError is reported with the usage of the command.
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// cli runs the commands.
+type cli struct {
+	client   *client.Client
+	quiet    bool
+	parallel int
+	stdout   io.Writer
+	stderr   *os.File
+}
+
+func (c *cli) run(ctx context.Context, command string, args []string) error {
+	switch command {
+	case "put":
+		return c.put(ctx, args)
+	case "get":
+		return c.get(ctx, args)
+	case "rm":
+		return c.rm(ctx, args)
+	case "ls":
+		return c.ls(ctx, args)
+	case "stat":
+		return c.stat(ctx, args)
+	case "cp":
+		return c.cp(ctx, args)
+	default:
+		return &usageError{fmt.Sprintf("unknown command %q", command)}
+	}
+}
+
+// parseFlags parses the flags of a command. Transfers accept -p for the number of parallel transfers.
+func (c *cli) parseFlags(flags *flag.FlagSet, args []string, transfers bool) error {
+	flags.SetOutput(io.Discard)
+	if transfers {
+		flags.IntVar(&c.parallel, "p", defaultParallel, "number of parallel transfers")
+	}
+	if err := flags.Parse(args); err != nil {
+		return &usageError{err.Error()}
+	}
+	if c.parallel < 1 {
+		return &usageError{"-p must be at least 1"}
+	}
+	return nil
+}
+
+func (c *cli) put(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("put", flag.ContinueOnError)
+	name := flags.String("name", "", "name of the stored file")
+	if err := c.parseFlags(flags, args, true); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return &usageError{"put: no files given"}
+	}
+	if *name != "" && flags.NArg() > 1 {
+		return &usageError{"put: -name requires a single file"}
+	}
+
+	var uploads []upload
+	for _, p := range flags.Args() {
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory, use cp -r", p)
+		}
+		u := upload{path: p, name: filepath.Base(p), size: info.Size()}
+		if *name != "" {
+			u.name = *name
+		}
+		uploads = append(uploads, u)
+	}
+	return c.upload(ctx, uploads)
+}
+
+func (c *cli) get(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	output := flags.String("o", "", "output file or directory, - for standard output")
+	if err := c.parseFlags(flags, args, true); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return &usageError{"get: no UUIDs given"}
+	}
+	if *output == "-" && flags.NArg() > 1 {
+		return &usageError{"get: only a single file can be written to standard output"}
+	}
+
+	var downloads []download
+	for _, uuid := range flags.Args() {
+		o, err := c.client.Stat(ctx, uuid)
+		if err != nil {
+			return fmt.Errorf("%s: %w", uuid, err)
+		}
+		d := download{Object: o, dest: defaultFileName(o)}
+		if *output != "" {
+			d.dest = *output
+			if *output != "-" {
+				d.dest = objectDest(*output, o)
+			}
+		}
+		downloads = append(downloads, d)
+	}
+	if *output != "" && *output != "-" && len(downloads) > 1 && downloads[0].dest == *output {
+		return &usageError{"get: -o must be a directory for several files"}
+	}
+	return c.download(ctx, downloads)
+}
+
+func (c *cli) rm(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return &usageError{"rm: no UUIDs given"}
+	}
+	failed := 0
+	for _, uuid := range args {
+		if err := c.client.Delete(ctx, uuid); err != nil {
+			fmt.Fprintf(c.stderr, "s3cli: %s: %v\n", uuid, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return &failedError{failed: failed, total: len(args)}
+	}
+	return nil
+}
+
+func (c *cli) ls(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return &usageError{"ls: expected at most one prefix"}
+	}
+	prefix := ""
+	if len(args) == 1 {
+		prefix = strings.TrimPrefix(args[0], remotePrefix)
+	}
+
+	objects, err := c.client.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UUID\tSIZE\tCREATED\tNAME")
+	for _, o := range objects {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", o.UUID, o.Size, formatTime(o.CreatedAt), o.Name)
+	}
+	return w.Flush()
+}
+
+func (c *cli) stat(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return &usageError{"stat: expected a single UUID"}
+	}
+	o, err := c.client.Stat(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "UUID:\t%s\n", o.UUID)
+	fmt.Fprintf(w, "Name:\t%s\n", o.Name)
+	fmt.Fprintf(w, "Size:\t%d (%s)\n", o.Size, formatBytes(o.Size))
+	fmt.Fprintf(w, "Created:\t%s\n", formatTime(o.CreatedAt))
+	return w.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+// cp copies between a local path and a stored name prefixed with s3://.
+func (c *cli) cp(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("cp", flag.ContinueOnError)
+	recursive := flags.Bool("r", false, "copy directory trees")
+	if err := c.parseFlags(flags, args, true); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return &usageError{"cp: expected a source and a destination"}
+	}
+	src, dst := flags.Arg(0), flags.Arg(1)
+	if src == "" || dst == "" {
+		return &usageError{"cp: empty source or destination"}
+	}
+
+	switch {
+	case !isRemote(src) && isRemote(dst):
+		name := strings.TrimPrefix(dst, remotePrefix)
+		if *recursive {
+			return c.uploadTree(ctx, src, name)
+		}
+		return c.uploadFile(ctx, src, name)
+	case isRemote(src) && !isRemote(dst):
+		name := strings.TrimPrefix(src, remotePrefix)
+		if *recursive {
+			return c.downloadTree(ctx, name, dst)
+		}
+		return c.downloadName(ctx, name, dst)
+	default:
+		return &usageError{"cp: exactly one of the source and the destination must start with " + remotePrefix}
+	}
+}
+
+func isRemote(p string) bool {
+	return strings.HasPrefix(p, remotePrefix)
+}
+
+func (c *cli) uploadFile(ctx context.Context, src, name string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory, use cp -r", src)
+	}
+	if name == "" || strings.HasSuffix(name, "/") {
+		name += filepath.Base(src)
+	}
+	return c.upload(ctx, []upload{{path: src, name: name, size: info.Size()}})
+}
+
+// uploadTree stores every regular file under dir, named by its path relative to dir below prefix.
+func (c *cli) uploadTree(ctx context.Context, dir, prefix string) error {
+	prefix = dirPrefix(prefix)
+	var uploads []upload
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		uploads = append(uploads, upload{path: p, name: prefix + filepath.ToSlash(rel), size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(uploads) == 0 {
+		return fmt.Errorf("%s: no files to copy", dir)
+	}
+	return c.upload(ctx, uploads)
+}
+
+// downloadName saves the newest file with the given name.
+func (c *cli) downloadName(ctx context.Context, name, dst string) error {
+	objects, err := c.client.List(ctx, name)
+	if err != nil {
+		return err
+	}
+	var found *client.Object
+	for i, o := range objects {
+		if o.Name == name && (found == nil || o.CreatedAt.After(found.CreatedAt)) {
+			found = &objects[i]
+		}
+	}
+	if found == nil {
+		return fmt.Errorf("%s%s: no such file", remotePrefix, name)
+	}
+	return c.download(ctx, []download{{Object: *found, dest: objectDest(dst, *found)}})
+}
+
+// downloadTree saves every file whose name is below prefix into dir, keeping the directory structure.
+// If several files have the same name, the newest one is saved.
+func (c *cli) downloadTree(ctx context.Context, prefix, dir string) error {
+	prefix = dirPrefix(prefix)
+	objects, err := c.client.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	newest := make(map[string]client.Object)
+	var rels []string
+	for _, o := range objects {
+		rel := strings.TrimPrefix(o.Name, prefix)
+		// Names come from the server, they must not escape the destination directory
+		if rel == "" || strings.HasSuffix(rel, "/") || !filepath.IsLocal(filepath.FromSlash(rel)) {
+			fmt.Fprintf(c.stderr, "s3cli: skipping %s (%s): not a valid path\n", o.UUID, o.Name)
+			continue
+		}
+		previous, ok := newest[rel]
+		if !ok {
+			rels = append(rels, rel)
+		}
+		if !ok || o.CreatedAt.After(previous.CreatedAt) {
+			newest[rel] = o
+		}
+	}
+	if len(rels) == 0 {
+		return fmt.Errorf("%s%s: no files to copy", remotePrefix, prefix)
+	}
+
+	downloads := make([]download, len(rels))
+	for i, rel := range rels {
+		downloads[i] = download{Object: newest[rel], dest: filepath.Join(dir, filepath.FromSlash(rel))}
+	}
+	return c.download(ctx, downloads)
+}
+
+// dirPrefix turns a name into a directory prefix, the empty prefix stays empty.
+func dirPrefix(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return path.Clean(prefix) + "/"
+}
+
+// exitCode returns the exit code for the error of a command.
+func exitCode(err error) int {
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		return 2
+	}
+	return 1
+}
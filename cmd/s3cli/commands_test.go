@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"simple-s3-adventure/pkg/client"
+
+	"github.com/cenkalti/backoff"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFront is an in-memory front server.
+type fakeFront struct {
+	mu      sync.Mutex
+	objects map[string]client.Object
+	data    map[string][]byte
+	// ranges are the Range headers of the downloads
+	ranges []string
+	// failures is the number of next downloads answered with 503 Service Unavailable
+	failures int
+}
+
+func newFakeFront() *fakeFront {
+	return &fakeFront{objects: make(map[string]client.Object), data: make(map[string][]byte)}
+}
+
+// store adds a file as if it was uploaded at the given time.
+func (f *fakeFront) store(name string, data []byte, createdAt time.Time) client.Object {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o := client.Object{UUID: uuid.New().String(), Name: name, Size: int64(len(data)), CreatedAt: createdAt}
+	f.objects[o.UUID] = o
+	f.data[o.UUID] = data
+	return o
+}
+
+// names returns the names of the stored files in order.
+func (f *fakeFront) names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for _, o := range f.objects {
+		names = append(names, o.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *fakeFront) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/put":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		o := f.store(r.FormValue("name"), data, time.Now())
+		json.NewEncoder(w).Encode(map[string]string{"uuid": o.UUID})
+	case "/get":
+		f.mu.Lock()
+		f.ranges = append(f.ranges, r.Header.Get("Range"))
+		fail := f.failures > 0
+		f.failures--
+		data, ok := f.data[r.FormValue("uuid")]
+		f.mu.Unlock()
+		switch {
+		case fail:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case !ok:
+			http.Error(w, "file not found", http.StatusNotFound)
+		default:
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+		}
+	case "/stat":
+		f.mu.Lock()
+		o, ok := f.objects[r.FormValue("uuid")]
+		f.mu.Unlock()
+		if !ok {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(o)
+	case "/list":
+		f.mu.Lock()
+		objects := []client.Object{}
+		for _, o := range f.objects {
+			if strings.HasPrefix(o.Name, r.FormValue("prefix")) {
+				objects = append(objects, o)
+			}
+		}
+		f.mu.Unlock()
+		sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+		json.NewEncoder(w).Encode(objects)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestCLI returns a quiet command line client of the front server retrying without delay.
+func newTestCLI(t *testing.T, front http.Handler) (*cli, *bytes.Buffer) {
+	t.Helper()
+	server := httptest.NewServer(front)
+	t.Cleanup(server.Close)
+	stderr, err := os.CreateTemp(t.TempDir(), "stderr")
+	require.NoError(t, err)
+	t.Cleanup(func() { stderr.Close() })
+
+	var stdout bytes.Buffer
+	return &cli{
+		client:   client.New(server.URL, client.WithBackOff(func() backoff.BackOff { return &backoff.ZeroBackOff{} })),
+		quiet:    true,
+		parallel: defaultParallel,
+		stdout:   &stdout,
+		stderr:   stderr,
+	}, &stdout
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestCp_RecursiveUploadAndDownload(t *testing.T) {
+	front := newFakeFront()
+	c, _ := newTestCLI(t, front)
+	ctx := context.Background()
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "a.txt"), "a")
+	writeFile(t, filepath.Join(src, "sub", "b.txt"), "b")
+
+	require.NoError(t, c.run(ctx, "cp", []string{"-r", src, "s3://backup"}))
+	assert.Equal(t, []string{"backup/a.txt", "backup/sub/b.txt"}, front.names())
+
+	// A newer file with the same name wins, names escaping the destination are skipped
+	front.store("backup/a.txt", []byte("newer a"), time.Now().Add(time.Hour))
+	front.store("backup/../escaped.txt", []byte("escaped"), time.Now())
+	dst := filepath.Join(t.TempDir(), "restored")
+	require.NoError(t, c.run(ctx, "cp", []string{"-r", "s3://backup/", dst}))
+	assert.Equal(t, "newer a", readFile(t, filepath.Join(dst, "a.txt")))
+	assert.Equal(t, "b", readFile(t, filepath.Join(dst, "sub", "b.txt")))
+	_, err := os.Stat(filepath.Join(filepath.Dir(dst), "escaped.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCp_SingleFile(t *testing.T) {
+	front := newFakeFront()
+	c, _ := newTestCLI(t, front)
+	ctx := context.Background()
+	src := filepath.Join(t.TempDir(), "report.pdf")
+	writeFile(t, src, "report")
+
+	// A name ending with a slash is a directory
+	require.NoError(t, c.run(ctx, "cp", []string{src, "s3://docs/"}))
+	assert.Equal(t, []string{"docs/report.pdf"}, front.names())
+
+	dir := t.TempDir()
+	require.NoError(t, c.run(ctx, "cp", []string{"s3://docs/report.pdf", dir}))
+	assert.Equal(t, "report", readFile(t, filepath.Join(dir, "report.pdf")))
+
+	err := c.run(ctx, "cp", []string{"s3://docs/missing.pdf", dir})
+	assert.ErrorContains(t, err, "no such file")
+}
+
+func TestCp_InvalidArguments(t *testing.T) {
+	c, _ := newTestCLI(t, newFakeFront())
+	ctx := context.Background()
+
+	for _, args := range [][]string{
+		{"s3://docs/report.pdf", ""},
+		{"", "s3://docs/"},
+		{"local", "other"},
+		{"s3://a", "s3://b"},
+		{"s3://a"},
+	} {
+		err := c.run(ctx, "cp", args)
+		var usageErr *usageError
+		assert.ErrorAs(t, err, &usageErr, "arguments %q", args)
+		assert.Equal(t, 2, exitCode(err))
+	}
+
+	err := c.run(ctx, "mv", nil)
+	assert.EqualError(t, err, `unknown command "mv"`)
+	assert.Equal(t, 2, exitCode(err))
+}
+
+func TestObjectDest(t *testing.T) {
+	dir := t.TempDir()
+	o := client.Object{UUID: "69d973de-c7ba-4856-9e54-773bb0e58546", Name: "docs/report.pdf"}
+
+	assert.Equal(t, "report.pdf", objectDest("", o))
+	assert.Equal(t, filepath.Join(dir, "report.pdf"), objectDest(dir, o))
+	assert.Equal(t, filepath.Join("new", "report.pdf"), objectDest("new"+string(filepath.Separator), o))
+	assert.Equal(t, filepath.Join(dir, "copy.pdf"), objectDest(filepath.Join(dir, "copy.pdf"), o))
+	// Names that aren't local paths are replaced by the UUID
+	assert.Equal(t, filepath.Join(dir, o.UUID), objectDest(dir, client.Object{UUID: o.UUID, Name: "../"}))
+}
@@ -0,0 +1,66 @@
+// s3cli uploads and downloads files through the front server.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"simple-s3-adventure/pkg/client"
+	"simple-s3-adventure/pkg/config"
+)
+
+const usage = `Usage: s3cli [flags] <command> [arguments]
+
+Commands:
+  put [-p N] [-name NAME] <file>...        upload files and print their UUIDs
+  get [-p N] [-o PATH] <uuid>...           download files, interrupted downloads are resumed
+  rm <uuid>...                             delete files
+  ls [prefix]                              list files whose name starts with prefix
+  stat <uuid>                              show the metadata of a file
+  cp [-r] [-p N] <file|dir> s3://<name>    upload a file or a directory tree
+  cp [-r] [-p N] s3://<name> <file|dir>    download a file or all files below a name
+
+-p sets the number of parallel transfers (default 4).
+
+Flags:
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	server := flag.String("server", config.GetEnvString("S3CLI_SERVER", "http://localhost:13090"), "front server address")
+	quiet := flag.Bool("q", false, "do not show progress")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	c := &cli{client: client.New(*server), quiet: *quiet, parallel: defaultParallel, stdout: os.Stdout, stderr: os.Stderr}
+	err := c.run(ctx, flag.Arg(0), flag.Args()[1:])
+	var failedErr *failedError
+	switch {
+	case err == nil:
+		return
+	case errors.As(err, &failedErr) && failedErr.total == 1:
+		// The error has been printed already
+	default:
+		fmt.Fprintln(os.Stderr, "s3cli:", err)
+	}
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintln(os.Stderr, "Run 's3cli -h' for usage.")
+	}
+	os.Exit(exitCode(err))
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	progressInterval = 200 * time.Millisecond
+	progressWidth    = 30
+)
+
+// progress renders a single progress bar for all transfers of a command. The bar is only drawn
+// when it goes to a terminal, so the output of scripts stays clean.
+type progress struct {
+	out        io.Writer
+	enabled    bool
+	totalBytes int64
+	totalFiles int
+	start      time.Time
+
+	bytes atomic.Int64
+	files atomic.Int64
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func newProgress(out *os.File, quiet bool, files int, bytes int64) *progress {
+	p := &progress{
+		out:        out,
+		enabled:    !quiet && isTerminal(out),
+		totalBytes: bytes,
+		totalFiles: files,
+		start:      time.Now(),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	if !p.enabled {
+		close(p.stopped)
+		return p
+	}
+
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.mu.Lock()
+				p.draw()
+				p.mu.Unlock()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Add counts transferred bytes.
+func (p *progress) Add(n int64) {
+	p.bytes.Add(n)
+}
+
+// FileDone counts a finished transfer.
+func (p *progress) FileDone() {
+	p.files.Add(1)
+}
+
+// Println prints a line to w without garbling the progress bar.
+func (p *progress) Println(w io.Writer, a ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	fmt.Fprintln(w, a...)
+	p.draw()
+}
+
+// Finish draws the final state of the bar and stops redrawing it.
+func (p *progress) Finish() {
+	if !p.enabled {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.draw()
+	fmt.Fprintln(p.out)
+	p.enabled = false
+}
+
+func (p *progress) clear() {
+	if p.enabled {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+}
+
+func (p *progress) draw() {
+	if !p.enabled {
+		return
+	}
+
+	bytes := p.bytes.Load()
+	ratio := 1.0
+	if p.totalBytes > 0 {
+		ratio = min(float64(bytes)/float64(p.totalBytes), 1)
+	}
+	filled := int(ratio * progressWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+	if filled > 0 && filled < progressWidth {
+		bar = bar[:filled-1] + ">" + bar[filled:]
+	}
+
+	rate := float64(bytes) / max(time.Since(p.start).Seconds(), 0.001)
+	fmt.Fprintf(p.out, "\r\033[K[%s] %3.0f%%  %s / %s  %s/s  %d/%d files",
+		bar, ratio*100, formatBytes(bytes), formatBytes(p.totalBytes), formatBytes(int64(rate)), p.files.Load(), p.totalFiles)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// progressReader counts the bytes read through it. Seeking back, e.g. to retry an upload, uncounts them.
+type progressReader struct {
+	r        io.ReadSeeker
+	progress *progress
+	pos      int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.pos += int64(n)
+	r.progress.Add(int64(n))
+	return n, err
+}
+
+func (r *progressReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.r.Seek(offset, whence)
+	if err == nil {
+		r.progress.Add(pos - r.pos)
+		r.pos = pos
+	}
+	return pos, err
+}
+
+// progressWriter counts the bytes written through it.
+type progressWriter struct {
+	w        io.Writer
+	progress *progress
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.progress.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"simple-s3-adventure/pkg/client"
+)
+
+// upload is a local file to be stored under name.
+type upload struct {
+	path string
+	name string
+	size int64
+}
+
+// download is a stored file to be saved to dest, "-" means standard output.
+type download struct {
+	client.Object
+	dest string
+}
+
+// failedError reports how many transfers of a command failed. Every failure has been printed already.
+type failedError struct {
+	failed, total int
+}
+
+func (e *failedError) Error() string {
+	return fmt.Sprintf("%d of %d transfers failed", e.failed, e.total)
+}
+
+// runParallel runs the tasks with at most parallel of them at a time and prints the errors of failed ones.
+func (c *cli) runParallel(ctx context.Context, parallel int, progress *progress, names []string, task func(ctx context.Context, i int) error) error {
+	var mu sync.Mutex
+	failed := 0
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range max(parallel, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				err := task(ctx, i)
+				progress.FileDone()
+				if err != nil {
+					progress.Println(c.stderr, "s3cli: "+names[i]+":", err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range names {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	progress.Finish()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if failed > 0 {
+		return &failedError{failed: failed, total: len(names)}
+	}
+	return nil
+}
+
+// upload stores the files and prints the UUID of every stored file.
+func (c *cli) upload(ctx context.Context, uploads []upload) error {
+	var total int64
+	names := make([]string, len(uploads))
+	for i, u := range uploads {
+		total += u.size
+		names[i] = u.path
+	}
+	progress := newProgress(c.stderr, c.quiet, len(uploads), total)
+
+	return c.runParallel(ctx, c.parallel, progress, names, func(ctx context.Context, i int) error {
+		u := uploads[i]
+		f, err := os.Open(u.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		uuid, err := c.client.Put(ctx, u.name, &progressReader{r: f, progress: progress})
+		if err != nil {
+			return err
+		}
+		progress.Println(c.stdout, uuid+"\t"+u.name)
+		return nil
+	})
+}
+
+// download saves the files.
+func (c *cli) download(ctx context.Context, downloads []download) error {
+	var total int64
+	names := make([]string, len(downloads))
+	for i, d := range downloads {
+		total += d.Size
+		names[i] = d.UUID
+		if d.Name != "" {
+			names[i] = d.Name
+		}
+	}
+	// The progress bar and the file share the terminal if the file goes to standard output
+	quiet := c.quiet || len(downloads) == 1 && downloads[0].dest == "-"
+	progress := newProgress(c.stderr, quiet, len(downloads), total)
+
+	return c.runParallel(ctx, c.parallel, progress, names, func(ctx context.Context, i int) error {
+		return c.downloadFile(ctx, downloads[i], progress)
+	})
+}
+
+// downloadFile downloads the file into a partial file next to the destination and renames it when it is complete.
+// The client resumes a broken download with a range request, and so does the next run of the command if this one gives up.
+func (c *cli) downloadFile(ctx context.Context, d download, progress *progress) error {
+	if d.dest == "-" {
+		_, err := c.client.Get(ctx, d.UUID, &progressWriter{w: c.stdout, progress: progress})
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(d.dest), 0o755); err != nil {
+		return err
+	}
+	partPath := d.dest + "." + d.UUID + ".part"
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if offset > d.Size {
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		if offset, err = f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	progress.Add(offset)
+
+	if offset < d.Size {
+		n, err := c.client.GetRange(ctx, d.UUID, offset, -1, &progressWriter{w: f, progress: progress})
+		offset += n
+		if err != nil && offset > 0 {
+			return fmt.Errorf("%w (%d of %d bytes saved, run the command again to resume)", err, offset, d.Size)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if offset != d.Size {
+		return fmt.Errorf("downloaded %d bytes, expected %d", offset, d.Size)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partPath, d.dest)
+}
+
+// objectDest returns where a downloaded object is saved: dest itself, or the base name of the object
+// inside dest if dest is a directory. An empty dest is the current directory.
+func objectDest(dest string, o client.Object) string {
+	if dest == "" {
+		return defaultFileName(o)
+	}
+	if info, err := os.Stat(dest); err == nil && info.IsDir() || os.IsPathSeparator(dest[len(dest)-1]) {
+		return filepath.Join(dest, defaultFileName(o))
+	}
+	return dest
+}
+
+// defaultFileName is the local name of a downloaded object.
+func defaultFileName(o client.Object) string {
+	if base := filepath.Base(filepath.FromSlash(o.Name)); o.Name != "" && filepath.IsLocal(base) {
+		return base
+	}
+	return o.UUID
+}
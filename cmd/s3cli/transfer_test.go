@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGet_ResumesPartialFile(t *testing.T) {
+	front := newFakeFront()
+	c, _ := newTestCLI(t, front)
+	data := strings.Repeat("0123456789", 100)
+	o := front.store("numbers.txt", []byte(data), time.Now())
+	dest := filepath.Join(t.TempDir(), "numbers.txt")
+	partPath := dest + "." + o.UUID + ".part"
+	writeFile(t, partPath, data[:400])
+
+	require.NoError(t, c.run(context.Background(), "get", []string{"-o", dest, o.UUID}))
+	assert.Equal(t, data, readFile(t, dest))
+	assert.Equal(t, []string{"bytes=400-"}, front.ranges)
+	_, err := os.Stat(partPath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestGet_RestartsOversizedPartialFile(t *testing.T) {
+	front := newFakeFront()
+	c, _ := newTestCLI(t, front)
+	o := front.store("small.txt", []byte("small"), time.Now())
+	dest := filepath.Join(t.TempDir(), "small.txt")
+	// Left by another file that was stored with the same UUID
+	writeFile(t, dest+"."+o.UUID+".part", "much larger content")
+
+	require.NoError(t, c.run(context.Background(), "get", []string{"-o", dest, o.UUID}))
+	assert.Equal(t, "small", readFile(t, dest))
+	assert.Equal(t, []string{""}, front.ranges)
+}
+
+func TestGet_Retries(t *testing.T) {
+	front := newFakeFront()
+	c, _ := newTestCLI(t, front)
+	o := front.store("flaky.txt", []byte("flaky"), time.Now())
+	dir := t.TempDir()
+
+	front.failures = 2
+	require.NoError(t, c.run(context.Background(), "get", []string{"-o", dir, o.UUID}))
+	assert.Equal(t, "flaky", readFile(t, filepath.Join(dir, "flaky.txt")))
+	assert.Len(t, front.ranges, 3)
+
+	// The client gives up, nothing is saved
+	front.failures = 10
+	dest := filepath.Join(dir, "gave-up.txt")
+	err := c.run(context.Background(), "get", []string{"-o", dest, o.UUID})
+	var failedErr *failedError
+	require.ErrorAs(t, err, &failedErr)
+	assert.Equal(t, 1, exitCode(err))
+	_, err = os.Stat(dest)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPut_PrintsUUIDs(t *testing.T) {
+	front := newFakeFront()
+	c, stdout := newTestCLI(t, front)
+	src := filepath.Join(t.TempDir(), "notes.txt")
+	writeFile(t, src, "notes")
+
+	require.NoError(t, c.run(context.Background(), "put", []string{"-name", "docs/notes.txt", src}))
+	assert.Equal(t, []string{"docs/notes.txt"}, front.names())
+	uuid, name, ok := strings.Cut(strings.TrimSpace(stdout.String()), "\t")
+	require.True(t, ok)
+	assert.Equal(t, "docs/notes.txt", name)
+	assert.Contains(t, front.objects, uuid)
+}
@@ -0,0 +1,4 @@
+// Package chunk_rpc contains the gRPC API of chunk servers used by the front server.
+package chunk_rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative chunk.proto
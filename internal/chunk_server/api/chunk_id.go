@@ -0,0 +1,12 @@
+package api
+
+import (
+	"net/http"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+)
+
+// chunkID reads the ID of the chunk from the "uuid" and "index" parameters.
+func chunkID(r *http.Request) (chunk_store.ChunkID, error) {
+	return chunk_store.NewChunkID(r.FormValue("uuid"), r.FormValue("index"))
+}
@@ -1,16 +1,17 @@
 package api
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 
 	srv "simple-s3-adventure/internal/chunk_server/service"
 	"simple-s3-adventure/pkg/logger"
-	uuid2 "simple-s3-adventure/pkg/uuid"
 )
 
-// DeleteHandler deletes the file with the given UUID from the server.
-func DeleteHandler(w http.ResponseWriter, r *http.Request, config *srv.ServerConfig) {
+// DeleteHandler deletes the chunk given by the "uuid" and "index" parameters from the server.
+// In maintenance mode or while shutting down, 503 Service Unavailable is returned.
+func DeleteHandler(w http.ResponseWriter, r *http.Request, chunkService *srv.ChunkService) {
 	lg := logger.GetLogger()
 
 	if r.Method != http.MethodDelete {
@@ -19,20 +20,21 @@ func DeleteHandler(w http.ResponseWriter, r *http.Request, config *srv.ServerCon
 		return
 	}
 
-	uuid := r.FormValue("uuid")
-	if err := uuid2.Validate(uuid); err != nil {
-		lg.Error("Incorrect UUID", slog.String("uuid", uuid))
-		http.Error(w, "Incorrect UUID", http.StatusBadRequest)
+	id, err := chunkID(r)
+	if err != nil {
+		lg.Error("Incorrect chunk ID", slog.Any("error", err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	err := srv.DeleteFile(config.UploadDir, uuid)
-	if err != nil {
-		if err.Error() == "file not found" {
-			lg.Error("File not found", slog.String("uuid", uuid))
+	if err := chunkService.DeleteFile(id); err != nil {
+		if errors.Is(err, srv.ErrFileNotFound) {
+			lg.Error("File not found", slog.String("chunk", id.String()))
 			http.Error(w, "File not found", http.StatusNotFound)
+		} else if errors.Is(err, srv.ErrWriteRejected) {
+			writeRejected(w, chunkService)
 		} else {
-			lg.Error("Failed to delete file on server", slog.String("uuid", uuid), slog.Any("error", err))
+			lg.Error("Failed to delete file on server", slog.String("chunk", id.String()), slog.Any("error", err))
 			http.Error(w, "Failed to delete file on server", http.StatusInternalServerError)
 		}
 		return
@@ -1,35 +1,39 @@
 package api
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 
 	chService "simple-s3-adventure/internal/chunk_server/service"
 	"simple-s3-adventure/pkg/logger"
-	uuid2 "simple-s3-adventure/pkg/uuid"
 )
 
-func GetHandler(w http.ResponseWriter, r *http.Request, config *chService.ServerConfig) {
+func GetHandler(w http.ResponseWriter, r *http.Request, chunkService *chService.ChunkService) {
 	lg := logger.GetLogger()
-	chunkService := chService.NewChunkService(config, lg)
 
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
 
-	uuid := r.URL.Query().Get("uuid")
-	if err := uuid2.Validate(uuid); err != nil {
-		lg.Error("Incorrect UUID", slog.String("uuid", uuid))
-		http.Error(w, "Incorrect UUID", http.StatusBadRequest)
+	id, err := chunkID(r)
+	if err != nil {
+		lg.Error("Incorrect chunk ID", slog.Any("error", err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := chunkService.CopyFileToResponse(uuid, w); err != nil {
-		lg.Error("Failed to copy file to response", slog.String("uuid", uuid), slog.Any("error", err))
+	if err := chunkService.CopyFileToResponse(id, w, r); err != nil {
+		if errors.Is(err, chService.ErrFileNotFound) {
+			lg.Error("File not found", slog.String("chunk", id.String()))
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		lg.Error("Failed to copy file to response", slog.String("chunk", id.String()), slog.Any("error", err))
 		http.Error(w, "Failed to copy file", http.StatusInternalServerError)
 		return
 	}
 
-	lg.Info("File sent", slog.String("uuid", uuid))
+	lg.Info("File sent", slog.String("chunk", id.String()))
 }
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"simple-s3-adventure/internal/chunk_rpc"
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	srv "simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/pkg/logger"
+	uuid2 "simple-s3-adventure/pkg/uuid"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMessageSize is the amount of chunk data sent in a single GetChunk message.
+const grpcMessageSize = 64 << 10
+
+// NewGRPCServer returns the gRPC server serving the chunk API of a chunk server. It serves the same
+// chunks as the HTTP handlers. Chunks are stored in the upload directory unless another store is given.
+func NewGRPCServer(config *srv.ServerConfig, nodeID string, opts ...srv.ChunkServiceOption) *grpc.Server {
+	return newGRPCServer(srv.NewChunkService(config, logger.GetLogger(), opts...), nodeID)
+}
+
+func newGRPCServer(chunkService *srv.ChunkService, nodeID string) *grpc.Server {
+	s := grpc.NewServer()
+	chunk_rpc.RegisterChunkServiceServer(s, &chunkServiceServer{chunkService: chunkService, nodeID: nodeID})
+	return s
+}
+
+type chunkServiceServer struct {
+	chunk_rpc.UnimplementedChunkServiceServer
+	chunkService *srv.ChunkService
+	nodeID       string
+}
+
+func grpcChunkID(uuid string, index int32) (chunk_store.ChunkID, error) {
+	if err := uuid2.Validate(uuid); err != nil {
+		return chunk_store.ChunkID{}, status.Error(codes.InvalidArgument, "incorrect UUID")
+	}
+	if index < 0 {
+		return chunk_store.ChunkID{}, status.Error(codes.InvalidArgument, "incorrect chunk index")
+	}
+	return chunk_store.ChunkID{UUID: uuid, Index: int(index)}, nil
+}
+
+func fileError(err error) error {
+	if errors.Is(err, srv.ErrFileNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, srv.ErrFileExists) {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	if errors.Is(err, srv.ErrInvalidRange) {
+		return status.Error(codes.OutOfRange, err.Error())
+	}
+	if errors.Is(err, srv.ErrWriteRejected) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// PutChunk stores the chunk streamed by the client. Nothing is stored if the stream breaks.
+// An existing chunk is only replaced if the client asks to overwrite it.
+func (s *chunkServiceServer) PutChunk(stream chunk_rpc.ChunkService_PutChunkServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	id, err := grpcChunkID(first.GetUuid(), first.GetIndex())
+	if err != nil {
+		return err
+	}
+
+	r := &putChunkReader{stream: stream, data: first.GetData(), limit: s.chunkService.Config.MaxUploadSize}
+	if err := s.chunkService.SaveUploadedFile(r, id, first.GetOverwrite()); err != nil {
+		if r.err != nil {
+			return r.err
+		}
+		return fileError(err)
+	}
+	return stream.SendAndClose(&chunk_rpc.PutChunkResponse{Size: r.size})
+}
+
+// putChunkReader reads the data of a PutChunk stream.
+type putChunkReader struct {
+	stream chunk_rpc.ChunkService_PutChunkServer
+	data   []byte
+	size   int64
+	limit  int64
+	// err is the error that interrupted the stream, if any
+	err error
+}
+
+func (r *putChunkReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		msg, err := r.stream.Recv()
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		r.data = msg.GetData()
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	r.size += int64(n)
+	if r.size > r.limit {
+		r.err = status.Errorf(codes.InvalidArgument, "chunk exceeds %d bytes", r.limit)
+		return n, r.err
+	}
+	return n, nil
+}
+
+// GetChunk streams the requested part of the chunk.
+func (s *chunkServiceServer) GetChunk(req *chunk_rpc.GetChunkRequest, stream chunk_rpc.ChunkService_GetChunkServer) error {
+	id, err := grpcChunkID(req.GetUuid(), req.GetIndex())
+	if err != nil {
+		return err
+	}
+
+	r, length, err := s.chunkService.OpenRange(id, req.GetOffset(), req.GetLength())
+	if err != nil {
+		return fileError(err)
+	}
+	defer r.Close()
+
+	if err := stream.Send(&chunk_rpc.GetChunkResponse{Size: length}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, grpcMessageSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := stream.Send(&chunk_rpc.GetChunkResponse{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, "failed to read file")
+		}
+	}
+}
+
+// DeleteChunk deletes the chunk.
+func (s *chunkServiceServer) DeleteChunk(_ context.Context, req *chunk_rpc.DeleteChunkRequest) (*chunk_rpc.DeleteChunkResponse, error) {
+	id, err := grpcChunkID(req.GetUuid(), req.GetIndex())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.chunkService.DeleteFile(id); err != nil {
+		return nil, fileError(err)
+	}
+	return &chunk_rpc.DeleteChunkResponse{}, nil
+}
+
+// Stat returns the size of the chunk.
+func (s *chunkServiceServer) Stat(_ context.Context, req *chunk_rpc.StatRequest) (*chunk_rpc.StatResponse, error) {
+	id, err := grpcChunkID(req.GetUuid(), req.GetIndex())
+	if err != nil {
+		return nil, err
+	}
+	size, err := s.chunkService.StatFile(id)
+	if err != nil {
+		return nil, fileError(err)
+	}
+	return &chunk_rpc.StatResponse{Size: size}, nil
+}
+
+// Heartbeat reports the node ID and the storage of the chunk server.
+func (s *chunkServiceServer) Heartbeat(context.Context, *chunk_rpc.HeartbeatRequest) (*chunk_rpc.HeartbeatResponse, error) {
+	stats, err := s.chunkService.StorageStats()
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to get storage stats: %v", err))
+	}
+	return &chunk_rpc.HeartbeatResponse{
+		NodeId:    s.nodeID,
+		Chunks:    int64(stats.Chunks),
+		Bytes:     stats.Bytes,
+		FreeBytes: srv.FreeBytes(s.chunkService.Disks()),
+	}, nil
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"simple-s3-adventure/internal/chunk_rpc"
+	srv "simple-s3-adventure/internal/chunk_server/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testUUID   = "123e4567-e89b-12d3-a456-426614174000"
+	testNodeID = "00000000-0000-4000-8000-000000000001"
+)
+
+func newTestGRPCClient(t *testing.T, maxUploadSize int64) chunk_rpc.ChunkServiceClient {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := NewGRPCServer(&srv.ServerConfig{UploadDir: t.TempDir(), MaxUploadSize: maxUploadSize}, testNodeID)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return chunk_rpc.NewChunkServiceClient(conn)
+}
+
+func putChunk(ctx context.Context, client chunk_rpc.ChunkServiceClient, uuid string, index int32, overwrite bool, data []byte) (*chunk_rpc.PutChunkResponse, error) {
+	stream, err := client.PutChunk(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Split the data to exercise reassembly on the server
+	half := len(data) / 2
+	if err := stream.Send(&chunk_rpc.PutChunkRequest{Uuid: uuid, Index: index, Overwrite: overwrite, Data: data[:half]}); err != nil {
+		return nil, err
+	}
+	if err := stream.Send(&chunk_rpc.PutChunkRequest{Data: data[half:]}); err != nil {
+		return nil, err
+	}
+	return stream.CloseAndRecv()
+}
+
+func getChunk(ctx context.Context, client chunk_rpc.ChunkServiceClient, req *chunk_rpc.GetChunkRequest) (int64, []byte, error) {
+	stream, err := client.GetChunk(ctx, req)
+	if err != nil {
+		return 0, nil, err
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	var data bytes.Buffer
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return first.GetSize(), data.Bytes(), nil
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		data.Write(msg.GetData())
+	}
+}
+
+func TestGRPCServer_RoundTrip(t *testing.T) {
+	client := newTestGRPCClient(t, 1<<20)
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+
+	resp, err := putChunk(ctx, client, testUUID, 1, false, data)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), resp.GetSize())
+
+	stat, err := client.Stat(ctx, &chunk_rpc.StatRequest{Uuid: testUUID, Index: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), stat.GetSize())
+
+	size, got, err := getChunk(ctx, client, &chunk_rpc.GetChunkRequest{Uuid: testUUID, Index: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), size)
+	assert.Equal(t, data, got)
+
+	size, got, err = getChunk(ctx, client, &chunk_rpc.GetChunkRequest{Uuid: testUUID, Index: 1, Offset: 5, Length: 12})
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), size)
+	assert.Equal(t, data[5:17], got)
+
+	// Other chunks of the file are stored separately
+	_, err = putChunk(ctx, client, testUUID, 0, false, []byte("first chunk"))
+	require.NoError(t, err)
+	_, _, err = getChunk(ctx, client, &chunk_rpc.GetChunkRequest{Uuid: testUUID, Index: 2})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	heartbeat, err := client.Heartbeat(ctx, &chunk_rpc.HeartbeatRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, testNodeID, heartbeat.GetNodeId())
+	assert.Equal(t, int64(2), heartbeat.GetChunks())
+	assert.Equal(t, int64(len(data)+11), heartbeat.GetBytes())
+
+	_, err = client.DeleteChunk(ctx, &chunk_rpc.DeleteChunkRequest{Uuid: testUUID, Index: 1})
+	require.NoError(t, err)
+
+	_, err = client.Stat(ctx, &chunk_rpc.StatRequest{Uuid: testUUID, Index: 1})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCServer_Errors(t *testing.T) {
+	client := newTestGRPCClient(t, 10)
+	ctx := context.Background()
+
+	_, err := putChunk(ctx, client, "invalid", 1, false, []byte("data"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = putChunk(ctx, client, testUUID, 1, false, []byte("more than ten bytes"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.Stat(ctx, &chunk_rpc.StatRequest{Uuid: testUUID, Index: 1})
+	assert.Equal(t, codes.NotFound, status.Code(err), "partial chunk must be removed")
+
+	_, _, err = getChunk(ctx, client, &chunk_rpc.GetChunkRequest{Uuid: testUUID, Index: 1})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = putChunk(ctx, client, testUUID, 1, false, []byte("data"))
+	require.NoError(t, err)
+	_, _, err = getChunk(ctx, client, &chunk_rpc.GetChunkRequest{Uuid: testUUID, Index: 1, Offset: 2, Length: 3})
+	assert.Equal(t, codes.OutOfRange, status.Code(err))
+
+	_, err = putChunk(ctx, client, testUUID, 1, false, []byte("new"))
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = putChunk(ctx, client, testUUID, 1, true, []byte("new"))
+	assert.NoError(t, err)
+	stat, err := client.Stat(ctx, &chunk_rpc.StatRequest{Uuid: testUUID, Index: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stat.GetSize())
+
+	_, err = putChunk(ctx, client, testUUID, -1, false, []byte("data"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.DeleteChunk(ctx, &chunk_rpc.DeleteChunkRequest{Uuid: "123e4567-e89b-12d3-a456-426614174001"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	srv "simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/pkg/cluster"
+	"simple-s3-adventure/pkg/logger"
+)
+
+// HandshakeHandler tells the front server the node ID and the protocol version of the chunk server, so it can
+// check that a registering chunk server is reachable at its URL and compatible before it stores chunks there.
+func HandshakeHandler(w http.ResponseWriter, r *http.Request, chunkService *srv.ChunkService) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	handshake := cluster.Handshake{NodeID: chunkService.NodeID(), ProtocolVersion: cluster.ProtocolVersion}
+	if err := json.NewEncoder(w).Encode(handshake); err != nil {
+		logger.GetLogger().Error("Failed to encode response", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	srv "simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/pkg/logger"
+)
+
+const (
+	defaultListLimit = 1000
+	maxListLimit     = 10000
+)
+
+// ListedChunk describes a stored chunk in the response of /list.
+type ListedChunk struct {
+	ID      string    `json:"id"`
+	UUID    string    `json:"uuid"`
+	Index   int       `json:"index"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// ListResponse is the response of /list. Next is set if more chunks follow, it is passed as "after"
+// to get the next page.
+type ListResponse struct {
+	Chunks []ListedChunk `json:"chunks"`
+	Next   string        `json:"next,omitempty"`
+}
+
+// listOptions reads the "prefix", "after" and "limit" parameters of /list.
+func listOptions(r *http.Request) (srv.ListOptions, error) {
+	opts := srv.ListOptions{Prefix: r.FormValue("prefix"), Limit: defaultListLimit}
+	if after := r.FormValue("after"); after != "" {
+		id, err := chunk_store.ParseChunkID(after)
+		if err != nil {
+			return srv.ListOptions{}, err
+		}
+		opts.After = id
+	}
+	if limit := r.FormValue("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > maxListLimit {
+			return srv.ListOptions{}, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		opts.Limit = n
+	}
+	return opts, nil
+}
+
+// ListHandler returns a page of the chunks stored on the server, ordered by UUID and index. The chunks
+// can be filtered by the "prefix" of their UUID, "after" is the ID of the last chunk of the previous page
+// and "limit" the size of the page.
+func ListHandler(w http.ResponseWriter, r *http.Request, chunkService *srv.ChunkService) {
+	lg := logger.GetLogger()
+
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	opts, err := listOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	chunks, more, err := chunkService.ListFiles(opts)
+	if err != nil {
+		http.Error(w, "Failed to list files", http.StatusInternalServerError)
+		return
+	}
+
+	resp := ListResponse{Chunks: make([]ListedChunk, 0, len(chunks))}
+	for _, chunk := range chunks {
+		resp.Chunks = append(resp.Chunks, ListedChunk{
+			ID:      chunk.ID.String(),
+			UUID:    chunk.ID.UUID,
+			Index:   chunk.ID.Index,
+			Size:    chunk.Size,
+			ModTime: chunk.ModTime,
+		})
+	}
+	if more {
+		resp.Next = chunks[len(chunks)-1].ID.String()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		lg.Error("Failed to encode response", slog.Any("error", err))
+	}
+}
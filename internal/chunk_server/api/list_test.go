@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	srv "simple-s3-adventure/internal/chunk_server/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const otherUUID = "223e4567-e89b-12d3-a456-426614174000"
+
+func listChunks(t *testing.T, handler http.Handler, query string) ListResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/list"+query, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp ListResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	return resp
+}
+
+func chunkIDs(resp ListResponse) []string {
+	ids := make([]string, len(resp.Chunks))
+	for i, chunk := range resp.Chunks {
+		ids[i] = chunk.ID
+	}
+	return ids
+}
+
+func TestListHandler(t *testing.T) {
+	store := chunk_store.NewMemoryStore()
+	for _, id := range []chunk_store.ChunkID{{UUID: otherUUID, Index: 0}, {UUID: testUUID, Index: 1}, {UUID: testUUID, Index: 0}} {
+		_, err := store.Put(id, strings.NewReader("chunk"), false)
+		require.NoError(t, err)
+	}
+	handler := NewHandler(&srv.ServerConfig{UploadDir: t.TempDir()}, srv.WithStore(store))
+
+	resp := listChunks(t, handler, "")
+	assert.Equal(t, []string{testUUID + "_0", testUUID + "_1", otherUUID + "_0"}, chunkIDs(resp))
+	assert.Empty(t, resp.Next)
+	assert.Equal(t, testUUID, resp.Chunks[1].UUID)
+	assert.Equal(t, 1, resp.Chunks[1].Index)
+	assert.Equal(t, int64(5), resp.Chunks[1].Size)
+	assert.False(t, resp.Chunks[1].ModTime.IsZero())
+
+	t.Run("Pages", func(t *testing.T) {
+		resp := listChunks(t, handler, "?limit=2")
+		assert.Equal(t, []string{testUUID + "_0", testUUID + "_1"}, chunkIDs(resp))
+		assert.Equal(t, testUUID+"_1", resp.Next)
+
+		resp = listChunks(t, handler, "?limit=2&after="+resp.Next)
+		assert.Equal(t, []string{otherUUID + "_0"}, chunkIDs(resp))
+		assert.Empty(t, resp.Next)
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		resp := listChunks(t, handler, "?prefix=123e")
+		assert.Equal(t, []string{testUUID + "_0", testUUID + "_1"}, chunkIDs(resp))
+
+		resp = listChunks(t, handler, "?prefix=123e&limit=1&after="+testUUID+"_0")
+		assert.Equal(t, []string{testUUID + "_1"}, chunkIDs(resp))
+		assert.Empty(t, resp.Next)
+
+		resp = listChunks(t, handler, "?prefix=323e")
+		assert.Empty(t, resp.Chunks)
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"?limit=0", "?limit=x", "?limit=10001", "?after=x"} {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/list"+query, nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/list", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestStatHandler(t *testing.T) {
+	config := &srv.ServerConfig{UploadDir: t.TempDir()}
+	store := srv.NewStore(config, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	handler := NewHandler(config, srv.WithStore(store))
+	id := chunk_store.ChunkID{UUID: testUUID, Index: 2}
+	_, err := store.Put(id, strings.NewReader("chunk content"), false)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stat?id="+id.String(), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp StatResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, testUUID, resp.UUID)
+	assert.Equal(t, 2, resp.Index)
+	assert.Equal(t, int64(13), resp.Size)
+	assert.Equal(t, fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte("chunk content"))), resp.Checksum)
+	assert.False(t, resp.ModTime.IsZero())
+	assert.Equal(t, config.UploadDir, resp.Location.Dir)
+	assert.True(t, strings.HasPrefix(resp.Location.Path, config.UploadDir))
+
+	for query, status := range map[string]int{
+		"?id=" + testUUID + "_3": http.StatusNotFound,
+		"?id=" + testUUID:        http.StatusBadRequest,
+		"":                       http.StatusBadRequest,
+	} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stat"+query, nil))
+		assert.Equal(t, status, w.Code, query)
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"log/slog"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	"simple-s3-adventure/internal/chunk_server/metrics"
+	"simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/pkg/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// storageCollector exports the state of the chunk store and its data directories at scrape time.
+type storageCollector struct {
+	chunkService *service.ChunkService
+
+	chunks    *prometheus.Desc
+	bytes     *prometheus.Desc
+	freeBytes *prometheus.Desc
+
+	dirChunks     *prometheus.Desc
+	dirBytes      *prometheus.Desc
+	dirFreeBytes  *prometheus.Desc
+	dirTotalBytes *prometheus.Desc
+	dirState      *prometheus.Desc
+}
+
+func newStorageCollector(chunkService *service.ChunkService) *storageCollector {
+	return &storageCollector{
+		chunkService: chunkService,
+		chunks: prometheus.NewDesc("s3_chunk_server_chunks",
+			"Number of stored chunks.", nil, nil),
+		bytes: prometheus.NewDesc("s3_chunk_server_stored_bytes",
+			"Bytes of stored chunks.", nil, nil),
+		freeBytes: prometheus.NewDesc("s3_chunk_server_disk_free_bytes",
+			"Bytes available for new chunks on the online data directories.", nil, nil),
+		dirChunks: prometheus.NewDesc("s3_chunk_server_data_dir_chunks",
+			"Number of chunks stored in the data directory.", []string{"dir"}, nil),
+		dirBytes: prometheus.NewDesc("s3_chunk_server_data_dir_stored_bytes",
+			"Bytes of chunks stored in the data directory.", []string{"dir"}, nil),
+		dirFreeBytes: prometheus.NewDesc("s3_chunk_server_data_dir_free_bytes",
+			"Bytes available on the file system of the data directory.", []string{"dir"}, nil),
+		dirTotalBytes: prometheus.NewDesc("s3_chunk_server_data_dir_capacity_bytes",
+			"Size of the file system of the data directory.", []string{"dir"}, nil),
+		dirState: prometheus.NewDesc("s3_chunk_server_data_dir_state",
+			"State of the data directory, 1 for the current state.", []string{"dir", "state"}, nil),
+	}
+}
+
+func (c *storageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.chunks
+	ch <- c.bytes
+	ch <- c.freeBytes
+	ch <- c.dirChunks
+	ch <- c.dirBytes
+	ch <- c.dirFreeBytes
+	ch <- c.dirTotalBytes
+	ch <- c.dirState
+}
+
+func (c *storageCollector) Collect(ch chan<- prometheus.Metric) {
+	lg := logger.GetLogger()
+
+	stats, err := c.chunkService.StorageStats()
+	if err != nil {
+		metrics.DiskErrors.WithLabelValues("stat").Inc()
+		lg.Error("Failed to collect storage stats", slog.Any("error", err))
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.chunks, prometheus.GaugeValue, float64(stats.Chunks))
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.GaugeValue, float64(stats.Bytes))
+	}
+
+	disks := c.chunkService.Disks()
+	ch <- prometheus.MustNewConstMetric(c.freeBytes, prometheus.GaugeValue, float64(service.FreeBytes(disks)))
+	for _, disk := range disks {
+		ch <- prometheus.MustNewConstMetric(c.dirChunks, prometheus.GaugeValue, float64(disk.Chunks), disk.Dir)
+		ch <- prometheus.MustNewConstMetric(c.dirBytes, prometheus.GaugeValue, float64(disk.Bytes), disk.Dir)
+		ch <- prometheus.MustNewConstMetric(c.dirFreeBytes, prometheus.GaugeValue, float64(disk.FreeBytes), disk.Dir)
+		ch <- prometheus.MustNewConstMetric(c.dirTotalBytes, prometheus.GaugeValue, float64(disk.TotalBytes), disk.Dir)
+		for _, state := range chunk_store.DiskStates {
+			var value float64
+			if disk.State == state {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.dirState, prometheus.GaugeValue, value, disk.Dir, string(state))
+		}
+	}
+}
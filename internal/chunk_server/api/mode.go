@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/cenkalti/backoff"
+
+	srv "simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/pkg/cluster"
+	"simple-s3-adventure/pkg/logger"
+)
+
+// modeHeader tells the client of a rejected write the mode of the chunk server.
+const modeHeader = "X-Chunk-Server-Mode"
+
+// ModeResponse is the response of /admin/mode.
+type ModeResponse struct {
+	Mode srv.Mode `json:"mode"`
+}
+
+// writeRejected answers a write the chunk server doesn't accept, because of its mode or because it is
+// shutting down, with 503 Service Unavailable, so the client can tell it from a failure of the chunk server
+// and store the chunk elsewhere.
+func writeRejected(w http.ResponseWriter, chunkService *srv.ChunkService) {
+	if chunkService.Stopping() {
+		http.Error(w, "Chunk server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	mode := chunkService.Mode()
+	w.Header().Set(modeHeader, string(mode))
+	http.Error(w, fmt.Sprintf("Chunk server is in %s mode", mode), http.StatusServiceUnavailable)
+}
+
+// ModeHandler returns the mode of the chunk server (GET) or switches it to the "mode" parameter (PUT).
+func ModeHandler(w http.ResponseWriter, r *http.Request, chunkService *srv.ChunkService) {
+	lg := logger.GetLogger()
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		mode, err := srv.ParseMode(r.FormValue("mode"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		chunkService.SetMode(mode)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ModeResponse{Mode: chunkService.Mode()}); err != nil {
+		lg.Error("Failed to encode response", slog.Any("error", err))
+	}
+}
+
+// toggleMode returns the mode a signal for target switches to: target, or read-write if the chunk server
+// already is in target.
+func toggleMode(current srv.Mode, target srv.Mode) srv.Mode {
+	if current == target {
+		return srv.ModeReadWrite
+	}
+	return target
+}
+
+// modeReporter tells the front server about mode changes, so placement avoids a chunk server that rejects
+// writes. A single goroutine sends the current mode on every change, so a report can't overtake a later one.
+type modeReporter struct {
+	frontServerAddress string
+	token              string
+	chunkServerPort    string
+	changed            chan struct{}
+}
+
+func newModeReporter(frontServerAddress string, token string, chunkServerPort string) *modeReporter {
+	return &modeReporter{
+		frontServerAddress: frontServerAddress,
+		token:              token,
+		chunkServerPort:    chunkServerPort,
+		changed:            make(chan struct{}, 1),
+	}
+}
+
+// notify is the mode listener of the chunk service, it doesn't block.
+func (m *modeReporter) notify(srv.Mode) {
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+}
+
+// run reports the mode of the chunk service after every change until the context is done. A failed report
+// is retried with backoff, unless the front server doesn't know the chunk server: it learns the mode when
+// the chunk server registers.
+func (m *modeReporter) run(ctx context.Context, chunkService *srv.ChunkService) {
+	lg := logger.GetLogger()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.changed:
+		}
+
+		hostname, err := os.Hostname()
+		if err != nil {
+			lg.Error("Failed to get hostname", slog.Any("error", err))
+			continue
+		}
+		chunkServerURL := serverURL(hostname, m.chunkServerPort)
+		bo := backoff.WithContext(backoff.NewExponentialBackOff(), ctx)
+		if err := backoff.Retry(func() error {
+			return reportMode(ctx, m.frontServerAddress, m.token, chunkServerURL, chunkService.Mode())
+		}, bo); err != nil {
+			lg.Error("Failed to report mode", slog.String("mode", string(chunkService.Mode())), slog.Any("error", err))
+		}
+	}
+}
+
+func reportMode(ctx context.Context, frontServerAddress string, token string, chunkServerURL string, mode srv.Mode) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	form := url.Values{"url": {chunkServerURL}, "mode": {string(mode)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, frontServerAddress+"/chunk_server_mode", strings.NewReader(form.Encode()))
+	if err != nil {
+		return backoff.Permanent(fmt.Errorf("failed to create PUT request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	cluster.SetToken(req, token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send PUT request: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound, http.StatusBadRequest, http.StatusUnauthorized:
+		return backoff.Permanent(fmt.Errorf("received HTTP status: %d", resp.StatusCode))
+	default:
+		return fmt.Errorf("received non-OK HTTP status: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	srv "simple-s3-adventure/internal/chunk_server/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func putRequest(t *testing.T, id chunk_store.ChunkID, data string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("uuid", id.UUID))
+	require.NoError(t, writer.WriteField("index", "0"))
+	part, err := writer.CreateFormFile("file", "chunk")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPut, "/put", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func setMode(t *testing.T, handler http.Handler, mode string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/admin/mode?mode="+mode, nil))
+	return w
+}
+
+func TestModeHandler(t *testing.T) {
+	handler := NewHandler(&srv.ServerConfig{UploadDir: t.TempDir(), MaxUploadSize: 1 << 20}, srv.WithStore(chunk_store.NewMemoryStore()))
+	id := chunk_store.ChunkID{UUID: testUUID}
+	deleteURL := "/delete?uuid=" + testUUID + "&index=0"
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/mode", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp ModeResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, srv.ModeReadWrite, resp.Mode)
+
+	w = setMode(t, handler, "read_only")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, srv.ModeReadOnly, resp.Mode)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, putRequest(t, id, "chunk"))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "read_only", w.Header().Get(modeHeader))
+
+	require.Equal(t, http.StatusOK, setMode(t, handler, "read_write").Code)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, putRequest(t, id, "chunk"))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.Equal(t, http.StatusOK, setMode(t, handler, "maintenance").Code)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, deleteURL, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "maintenance", w.Header().Get(modeHeader))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/get?uuid="+testUUID+"&index=0", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "chunk", w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, setMode(t, handler, "offline").Code)
+}
+
+func TestToggleMode(t *testing.T) {
+	assert.Equal(t, srv.ModeReadOnly, toggleMode(srv.ModeReadWrite, srv.ModeReadOnly))
+	assert.Equal(t, srv.ModeReadWrite, toggleMode(srv.ModeReadOnly, srv.ModeReadOnly))
+	assert.Equal(t, srv.ModeMaintenance, toggleMode(srv.ModeReadOnly, srv.ModeMaintenance))
+}
+
+func TestReportMode(t *testing.T) {
+	var form url.Values
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/chunk_server_mode" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+	}))
+	defer front.Close()
+
+	require.NoError(t, reportMode(context.Background(), front.URL, "secret", "http://chunk-server:12090", srv.ModeMaintenance))
+	assert.Equal(t, url.Values{"url": {"http://chunk-server:12090"}, "mode": {"maintenance"}}, form)
+
+	err := reportMode(context.Background(), front.URL+"/unknown", "secret", "http://chunk-server:12090", srv.ModeMaintenance)
+	assert.ErrorContains(t, err, "404")
+
+	err = reportMode(context.Background(), front.URL, "wrong", "http://chunk-server:12090", srv.ModeMaintenance)
+	assert.ErrorContains(t, err, "401")
+}
@@ -1,17 +1,19 @@
 package api
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 
 	srv "simple-s3-adventure/internal/chunk_server/service"
 	"simple-s3-adventure/pkg/logger"
-	uuid2 "simple-s3-adventure/pkg/uuid"
 )
 
-func PutHandler(w http.ResponseWriter, r *http.Request, config *srv.ServerConfig) {
+// PutHandler stores the chunk given by the "uuid" and "index" fields. An existing chunk is only replaced
+// if the "overwrite" field is "true", otherwise 409 Conflict is returned. Unless the chunk server is in
+// read-write mode and not shutting down, 503 Service Unavailable is returned.
+func PutHandler(w http.ResponseWriter, r *http.Request, chunkService *srv.ChunkService) {
 	lg := logger.GetLogger()
-	chunkService := srv.NewChunkService(config, lg)
 
 	if r.Method != http.MethodPut {
 		lg.Error("Method not allowed", slog.String("method", r.Method))
@@ -19,21 +21,22 @@ func PutHandler(w http.ResponseWriter, r *http.Request, config *srv.ServerConfig
 		return
 	}
 
-	uuid := r.FormValue("uuid")
-	if err := uuid2.Validate(uuid); err != nil {
-		http.Error(w, "Incorrect UUID", http.StatusBadRequest)
+	// Rejected before the chunk is read
+	if !chunkService.AcceptsPuts() {
+		writeRejected(w, chunkService)
 		return
 	}
 
-	if err := srv.CreateUploadDir(config.UploadDir); err != nil {
-		http.Error(w, "Failed to create upload directory", http.StatusInternalServerError)
+	id, err := chunkID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	overwrite := r.FormValue("overwrite") == "true"
 
 	// up to a total of 10MB bytes of the file are stored in memory,
 	// with the remainder stored on disk in temporary files.
-	err := r.ParseMultipartForm(config.MaxUploadSize)
-	if err != nil {
+	if err := r.ParseMultipartForm(chunkService.Config.MaxUploadSize); err != nil {
 		lg.Error("Failed to parse multipart form", slog.Any("error", err))
 		http.Error(w, "Failed to parse multipart form", http.StatusBadRequest)
 		return
@@ -47,7 +50,15 @@ func PutHandler(w http.ResponseWriter, r *http.Request, config *srv.ServerConfig
 	}
 	defer file.Close()
 
-	if err := chunkService.SaveUploadedFile(file, uuid); err != nil {
+	if err := chunkService.SaveUploadedFile(file, id, overwrite); err != nil {
+		if errors.Is(err, srv.ErrFileExists) {
+			http.Error(w, "Chunk already exists", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, srv.ErrWriteRejected) {
+			writeRejected(w, chunkService)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
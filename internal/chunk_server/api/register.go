@@ -4,44 +4,64 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/pkg/cluster"
 	"simple-s3-adventure/pkg/logger"
+	"strings"
 	"time"
+
+	"github.com/cenkalti/backoff"
 )
 
-const requestTimeout = 30 * time.Second
+const (
+	requestTimeout = 30 * time.Second
+	// maxErrorMessageSize is the part of an error response of the front server that is logged.
+	maxErrorMessageSize = 1 << 10
+)
 
-func register(frontServerAddress string, chunkServerPort string) error {
+// register registers the chunk server on the front server, authenticated with the cluster token. The gRPC
+// address is sent only if grpcPort is set. The mode tells the front server whether to place chunks on the
+// chunk server. A rejected token or a failed handshake is a permanent error, retrying doesn't fix it.
+func register(ctx context.Context, frontServerAddress string, token string, chunkServerPort string, grpcPort string, nodeID string, mode service.Mode) error {
 	hostname, err := os.Hostname()
 	if err != nil {
 		return logAndReturnError(fmt.Errorf("failed to get hostname: %w", err))
 	}
 
-	url := fmt.Sprintf("http://%s:%s", hostname, chunkServerPort)
+	url := serverURL(hostname, chunkServerPort)
+	var grpcAddress string
+	if grpcPort != "" {
+		grpcAddress = net.JoinHostPort(hostname, grpcPort)
+	}
 
 	lg := logger.GetLogger()
-	lg.Info("Registering chunk server", slog.String("front_server", frontServerAddress), slog.String("url", url))
+	lg.Info("Registering chunk server", slog.String("front_server", frontServerAddress), slog.String("url", url), slog.String("node_id", nodeID), slog.String("grpc_address", grpcAddress), slog.String("mode", string(mode)))
 
-	requestBody, contentType, err := createRequestBody(url)
+	requestBody, contentType, err := createRequestBody(url, nodeID, grpcAddress, mode)
 	if err != nil {
 		return logAndReturnError(err)
 	}
 
-	req, err := http.NewRequest("PUT", frontServerAddress+"/register_chunk_server", requestBody)
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", frontServerAddress+"/register_chunk_server", requestBody)
 	if err != nil {
 		return logAndReturnError(fmt.Errorf("failed to create PUT request: %w", err))
 	}
 
 	req.Header.Set("Content-Type", contentType)
+	cluster.SetToken(req, token)
 
 	client := &http.Client{}
-	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
-	defer cancel()
-
-	resp, err := client.Do(req.WithContext(ctx))
+	resp, err := client.Do(req)
 	if err != nil {
 		return logAndReturnError(fmt.Errorf("failed to send PUT request: %w", err))
 	}
@@ -51,19 +71,70 @@ func register(frontServerAddress string, chunkServerPort string) error {
 		}
 	}()
 
-	if resp.StatusCode != http.StatusOK {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusBadRequest, http.StatusConflict:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorMessageSize))
+		return backoff.Permanent(fmt.Errorf("registration rejected with HTTP status %d: %s", resp.StatusCode, strings.TrimSpace(string(message))))
+	default:
+		return fmt.Errorf("received non-OK HTTP status: %d", resp.StatusCode)
+	}
+}
+
+// deregister tells the front server that the chunk server is going away. The front server keeps what it knows
+// about the chunks of the chunk server, but doesn't place new chunks on it until it registers again.
+func deregister(ctx context.Context, frontServerAddress string, token string, chunkServerPort string, nodeID string) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	params := url.Values{"url": {serverURL(hostname, chunkServerPort)}, "id": {nodeID}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, frontServerAddress+"/register_chunk_server?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create DELETE request: %w", err)
+	}
+	cluster.SetToken(req, token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send DELETE request: %w", err)
+	}
+	resp.Body.Close()
+
+	// The front server doesn't know the chunk server, e.g. because it was restarted
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
 		return fmt.Errorf("received non-OK HTTP status: %d", resp.StatusCode)
 	}
 	return nil
 }
 
-func createRequestBody(url string) (*bytes.Buffer, string, error) {
+// serverURL returns the URL the chunk server registers with.
+func serverURL(hostname string, port string) string {
+	return fmt.Sprintf("http://%s:%s", hostname, port)
+}
+
+func createRequestBody(url string, nodeID string, grpcAddress string, mode service.Mode) (*bytes.Buffer, string, error) {
 	var requestBody bytes.Buffer
 	writer := multipart.NewWriter(&requestBody)
 
 	if err := writer.WriteField("url", url); err != nil {
 		return nil, "", fmt.Errorf("failed to add URL field: %w", err)
 	}
+	if err := writer.WriteField("id", nodeID); err != nil {
+		return nil, "", fmt.Errorf("failed to add ID field: %w", err)
+	}
+	if grpcAddress != "" {
+		if err := writer.WriteField("grpc_address", grpcAddress); err != nil {
+			return nil, "", fmt.Errorf("failed to add gRPC address field: %w", err)
+		}
+	}
+	if err := writer.WriteField("mode", string(mode)); err != nil {
+		return nil, "", fmt.Errorf("failed to add mode field: %w", err)
+	}
 	if err := writer.Close(); err != nil {
 		return nil, "", fmt.Errorf("failed to close writer: %w", err)
 	}
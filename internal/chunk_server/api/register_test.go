@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	srv "simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/pkg/cluster"
+
+	"github.com/cenkalti/backoff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandshakeHandler(t *testing.T) {
+	handler := NewHandler(&srv.ServerConfig{UploadDir: t.TempDir()}, srv.WithStore(chunk_store.NewMemoryStore()), srv.WithNodeID("node-1"))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/handshake", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var handshake cluster.Handshake
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&handshake))
+	assert.Equal(t, cluster.Handshake{NodeID: "node-1", ProtocolVersion: cluster.ProtocolVersion}, handshake)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/handshake", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestRegister(t *testing.T) {
+	status := http.StatusOK
+	var form map[string]string
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cluster.ValidToken(r, "secret") {
+			http.Error(w, "Invalid cluster token", http.StatusUnauthorized)
+			return
+		}
+		form = map[string]string{"id": r.FormValue("id"), "mode": r.FormValue("mode")}
+		http.Error(w, "status", status)
+	}))
+	defer front.Close()
+	ctx := context.Background()
+
+	require.NoError(t, register(ctx, front.URL, "secret", "12090", "", "node-1", srv.ModeReadOnly))
+	assert.Equal(t, map[string]string{"id": "node-1", "mode": "read_only"}, form)
+
+	var permanent *backoff.PermanentError
+	err := register(ctx, front.URL, "wrong", "12090", "", "node-1", srv.ModeReadWrite)
+	assert.True(t, errors.As(err, &permanent))
+	assert.ErrorContains(t, err, "Invalid cluster token")
+
+	// A chunk server that can't be reached yet is retried
+	status = http.StatusBadGateway
+	err = register(ctx, front.URL, "secret", "12090", "", "node-1", srv.ModeReadWrite)
+	assert.ErrorContains(t, err, "502")
+	assert.False(t, errors.As(err, &permanent))
+
+	status = http.StatusBadRequest
+	err = register(ctx, front.URL, "secret", "12090", "", "node-1", srv.ModeReadWrite)
+	assert.True(t, errors.As(err, &permanent))
+
+	// A node ID mismatch can't be resolved by retrying
+	status = http.StatusConflict
+	err = register(ctx, front.URL, "secret", "12090", "", "node-1", srv.ModeReadWrite)
+	assert.True(t, errors.As(err, &permanent))
+	assert.ErrorContains(t, err, "409")
+	assert.ErrorContains(t, err, "status")
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	"simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/pkg/cluster"
+
+	"github.com/cenkalti/backoff"
+)
+
+// reportRetryTime bounds the retries of a report, so the scrubber isn't held up while the front server is down.
+// The scrubber sends the chunks it couldn't report again on its next pass.
+const reportRetryTime = time.Minute
+
+// corruptChunkReporter returns the function telling the front server about chunks quarantined by the scrubber,
+// so it copies them again from another replica. The chunk server is identified by the URL it registers with.
+// A failed report is retried with backoff for up to reportRetryTime. A chunk the front server doesn't know,
+// e.g. of a deleted file, isn't retried and fails with service.ErrUnknownChunk.
+func corruptChunkReporter(frontServerAddress string, token string, chunkServerPort string) service.ReportFunc {
+	return func(ctx context.Context, id chunk_store.ChunkID) error {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("failed to get hostname: %w", err)
+		}
+		bo := backoff.NewExponentialBackOff()
+		bo.MaxElapsedTime = reportRetryTime
+		return backoff.Retry(func() error {
+			return reportCorruptChunk(ctx, frontServerAddress, token, serverURL(hostname, chunkServerPort), id)
+		}, backoff.WithContext(bo, ctx))
+	}
+}
+
+func reportCorruptChunk(ctx context.Context, frontServerAddress string, token string, chunkServerURL string, id chunk_store.ChunkID) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	form := url.Values{
+		"url":   {chunkServerURL},
+		"uuid":  {id.UUID},
+		"index": {strconv.Itoa(id.Index)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, frontServerAddress+"/report_corrupt_chunk", strings.NewReader(form.Encode()))
+	if err != nil {
+		return backoff.Permanent(fmt.Errorf("failed to create PUT request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	cluster.SetToken(req, token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send PUT request: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		return nil
+	case http.StatusNotFound:
+		return backoff.Permanent(fmt.Errorf("%w: received HTTP status: %d", service.ErrUnknownChunk, resp.StatusCode))
+	case http.StatusBadRequest, http.StatusUnauthorized:
+		return backoff.Permanent(fmt.Errorf("received HTTP status: %d", resp.StatusCode))
+	default:
+		return fmt.Errorf("received non-Accepted HTTP status: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	"simple-s3-adventure/internal/chunk_server/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportCorruptChunk(t *testing.T) {
+	var form map[string]string
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/report_corrupt_chunk" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		form = map[string]string{"url": r.FormValue("url"), "uuid": r.FormValue("uuid"), "index": r.FormValue("index"), "token": r.Header.Get("Authorization")}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer front.Close()
+
+	id := chunk_store.ChunkID{UUID: testUUID, Index: 3}
+	assert.NoError(t, reportCorruptChunk(context.Background(), front.URL, "secret", "http://chunk-server:12090", id))
+	assert.Equal(t, map[string]string{"url": "http://chunk-server:12090", "uuid": testUUID, "index": "3", "token": "Bearer secret"}, form)
+
+	assert.Error(t, reportCorruptChunk(context.Background(), front.URL+"/unknown", "secret", "http://chunk-server:12090", id))
+}
+
+func TestCorruptChunkReporter_Retries(t *testing.T) {
+	var attempts atomic.Int32
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer front.Close()
+
+	id := chunk_store.ChunkID{UUID: testUUID, Index: 3}
+	assert.NoError(t, corruptChunkReporter(front.URL, "secret", "12090")(context.Background(), id))
+	assert.Equal(t, int32(2), attempts.Load())
+
+	// A rejected token isn't retried
+	err := corruptChunkReporter(front.URL, "other", "12090")(context.Background(), id)
+	assert.True(t, err != nil && strings.Contains(err.Error(), "401"), "unexpected error: %v", err)
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestCorruptChunkReporter_UnknownChunk(t *testing.T) {
+	var attempts atomic.Int32
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "replica not found", http.StatusNotFound)
+	}))
+	defer front.Close()
+
+	err := corruptChunkReporter(front.URL, "secret", "12090")(context.Background(), chunk_store.ChunkID{UUID: testUUID, Index: 3})
+	assert.ErrorIs(t, err, service.ErrUnknownChunk)
+	assert.Equal(t, int32(1), attempts.Load())
+}
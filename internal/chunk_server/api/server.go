@@ -2,16 +2,19 @@ package api
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"simple-s3-adventure/internal/chunk_server/metrics"
 	"simple-s3-adventure/internal/chunk_server/service"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 
 	"simple-s3-adventure/pkg/logger"
 )
@@ -20,52 +23,248 @@ const (
 	registrationDelay = 5 * time.Second
 )
 
-func registerHandlers(mux *http.ServeMux, config *service.ServerConfig) {
-	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
-		PutHandler(w, r, config)
-	})
-	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
-		GetHandler(w, r, config)
-	})
-	mux.HandleFunc("/delete", func(w http.ResponseWriter, r *http.Request) {
-		DeleteHandler(w, r, config)
-	})
+func registerHandlers(mux *http.ServeMux, chunkService *service.ChunkService) {
+	mux.Handle("/put", metrics.InstrumentHandler("put", func(w http.ResponseWriter, r *http.Request) {
+		PutHandler(w, r, chunkService)
+	}))
+	mux.Handle("/get", metrics.InstrumentHandler("get", func(w http.ResponseWriter, r *http.Request) {
+		GetHandler(w, r, chunkService)
+	}))
+	mux.Handle("/delete", metrics.InstrumentHandler("delete", func(w http.ResponseWriter, r *http.Request) {
+		DeleteHandler(w, r, chunkService)
+	}))
+	mux.Handle("/list", metrics.InstrumentHandler("list", func(w http.ResponseWriter, r *http.Request) {
+		ListHandler(w, r, chunkService)
+	}))
+	mux.Handle("/stat", metrics.InstrumentHandler("stat", func(w http.ResponseWriter, r *http.Request) {
+		StatHandler(w, r, chunkService)
+	}))
+	mux.Handle("/handshake", metrics.InstrumentHandler("handshake", func(w http.ResponseWriter, r *http.Request) {
+		HandshakeHandler(w, r, chunkService)
+	}))
+	mux.Handle("/admin/mode", metrics.InstrumentHandler("admin_mode", func(w http.ResponseWriter, r *http.Request) {
+		ModeHandler(w, r, chunkService)
+	}))
+	mux.Handle("/metrics", promhttp.Handler())
 }
 
-// StartServer starts the HTTP server on the given port.
-func StartServer(config *service.ServerConfig) {
+// NewHandler returns the HTTP handler serving the API of a chunk server.
+// Chunks are stored in the upload directory unless another store is given.
+func NewHandler(config *service.ServerConfig, opts ...service.ChunkServiceOption) http.Handler {
 	mux := http.NewServeMux()
-	registerHandlers(mux, config)
+	registerHandlers(mux, service.NewChunkService(config, logger.GetLogger(), opts...))
+	return mux
+}
 
+// StartServer starts the HTTP server on the given port and serves until the context is done. The chunk server
+// then stops accepting writes, deregisters from the front server and waits up to the shutdown timeout for
+// the requests in flight and the background tasks.
+func StartServer(ctx context.Context, config *service.ServerConfig) {
 	lg := logger.GetLogger()
 
-	// Wait for launch of HTTP server
-	time.AfterFunc(registrationDelay, func() {
-		ctx, cancel := context.WithCancelCause(context.Background())
-		defer cancel(fmt.Errorf("registration cancelled"))
-
-		var attempt int
-		bo := backoff.WithContext(backoff.NewExponentialBackOff(), ctx)
-		if err := backoff.Retry(func() error {
-			attempt++
-			if err := register(config.FrontServerAddress, config.Port); err != nil {
-				lg.Error("Failed to register chunk server", slog.Int("attempt", attempt), slog.String("error", err.Error()))
-				return err
-			}
-			return nil
-		}, bo); err != nil {
-			if errors.Is(err, context.Canceled) {
-				lg.Info("Registration cancelled")
-			} else {
-				lg.Error("Failed to register chunk server", slog.String("error", err.Error()))
-			}
+	// Chunks of the flat layout are migrated here, before any request is served
+	store := service.NewStore(config, lg)
+	if err := store.Load(); err != nil {
+		lg.Error("Failed to load chunks", slog.Any("error", err))
+		os.Exit(1)
+	}
+	for _, disk := range store.Disks() {
+		lg.Info("Loaded data directory",
+			slog.String("dir", disk.Dir),
+			slog.String("state", string(disk.State)),
+			slog.Int("chunks", disk.Chunks),
+			slog.Int64("free_bytes", disk.FreeBytes),
+			slog.Int64("capacity_bytes", disk.TotalBytes))
+	}
+	stats, err := service.GetStorageStats(store)
+	if err != nil {
+		lg.Error("Failed to load chunks", slog.Any("error", err))
+		os.Exit(1)
+	}
+	lg.Info("Loaded chunks", slog.Int("chunks", stats.Chunks), slog.Int64("bytes", stats.Bytes))
+
+	// Background tasks stop with the context, shutdown waits for the running ones to finish
+	var tasks sync.WaitGroup
+	runTask := func(task func()) {
+		tasks.Add(1)
+		go func() {
+			defer tasks.Done()
+			task()
+		}()
+	}
+
+	nodeID, err := service.LoadOrCreateNodeID(config.UploadDir)
+	if err != nil {
+		lg.Error("Failed to load node ID", slog.Any("error", err))
+		os.Exit(1)
+	}
+	if config.ClusterToken == "" {
+		if !config.InsecureRegistration {
+			lg.Error("CLUSTER_TOKEN is not set, set INSECURE_REGISTRATION=true to register without a token")
 			os.Exit(1)
 		}
-	})
+		lg.Warn("CLUSTER_TOKEN is not set, the front server must accept chunk servers without a token")
+	}
+
+	modes := newModeReporter(config.FrontServerAddress, config.ClusterToken, config.Port)
+	chunkService := service.NewChunkService(config, lg,
+		service.WithStore(store),
+		service.WithNodeID(nodeID),
+		service.WithModeListener(modes.notify))
+	runTask(func() { modes.run(ctx, chunkService) })
+	runTask(func() { handleModeSignals(ctx, chunkService) })
+	lg.Info("Chunk server mode", slog.String("mode", string(chunkService.Mode())))
 
-	lg.Info("Starting chunk server", slog.String("port", config.Port))
-	address := net.JoinHostPort("", config.Port)
-	if err := http.ListenAndServe(address, mux); err != nil {
+	inMaintenance := func() bool {
+		return chunkService.Mode() == service.ModeMaintenance
+	}
+	if config.SmallChunkSize > 0 && config.CompactionInterval > 0 {
+		runTask(func() {
+			service.RunCompaction(ctx, store, config.CompactionInterval, config.CompactionGarbage, inMaintenance, lg)
+		})
+	}
+	if config.ScrubInterval > 0 {
+		report := corruptChunkReporter(config.FrontServerAddress, config.ClusterToken, config.Port)
+		runTask(func() {
+			service.RunScrubber(ctx, store, config.ScrubInterval, config.ScrubBandwidth, report, inMaintenance, lg)
+		})
+	}
+	mux := http.NewServeMux()
+	registerHandlers(mux, chunkService)
+	prometheus.MustRegister(newStorageCollector(chunkService))
+
+	registered := make(chan bool, 1)
+	go func() {
+		registered <- registerWithRetry(ctx, config, nodeID, chunkService)
+	}()
+
+	var grpcServer *grpc.Server
+	if config.GRPCPort != "" {
+		lis, err := net.Listen("tcp", net.JoinHostPort("", config.GRPCPort))
+		if err != nil {
+			lg.Error("Could not listen for gRPC", slog.Any("error", err))
+			os.Exit(1)
+		}
+		grpcServer = newGRPCServer(chunkService, nodeID)
+		go func() {
+			lg.Info("Starting gRPC chunk server", slog.String("port", config.GRPCPort))
+			if err := grpcServer.Serve(lis); err != nil {
+				lg.Error("Could not start gRPC server", slog.Any("error", err))
+			}
+		}()
+	}
+
+	lg.Info("Starting chunk server", slog.String("port", config.Port), slog.String("node_id", nodeID))
+	server := &http.Server{
+		Addr:    net.JoinHostPort("", config.Port),
+		Handler: mux,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
 		lg.Error("Could not start server", slog.Any("error", err))
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	lg.Info("Shutting down chunk server", slog.Duration("timeout", config.ShutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	// New writes are rejected while the front server learns that the chunk server is going away,
+	// reads are served until the listeners are closed.
+	chunkService.StopWrites()
+	if <-registered {
+		if err := deregister(shutdownCtx, config.FrontServerAddress, config.ClusterToken, config.Port, nodeID); err != nil {
+			lg.Error("Failed to deregister chunk server", slog.Any("error", err))
+		} else {
+			lg.Info("Deregistered chunk server")
+		}
+		metrics.Registered.Set(0)
+	}
+
+	shutdown(shutdownCtx, server, grpcServer)
+	if !waitUntil(shutdownCtx, tasks.Wait) {
+		lg.Warn("Background tasks didn't finish before the shutdown timeout")
+	}
+	lg.Info("Chunk server shut down")
+}
+
+// registerWithRetry registers the chunk server once the HTTP server is up, retrying with backoff until it
+// succeeds or the context is done. It reports whether the chunk server was registered, and exits if the
+// front server can't be reached or rejects the chunk server.
+func registerWithRetry(ctx context.Context, config *service.ServerConfig, nodeID string, chunkService *service.ChunkService) bool {
+	lg := logger.GetLogger()
+
+	// Wait for launch of HTTP server
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(registrationDelay):
+	}
+
+	var attempt int
+	bo := backoff.WithContext(backoff.NewExponentialBackOff(), ctx)
+	if err := backoff.Retry(func() error {
+		attempt++
+		if err := register(ctx, config.FrontServerAddress, config.ClusterToken, config.Port, config.GRPCPort, nodeID, chunkService.Mode()); err != nil {
+			metrics.RegistrationFailures.Inc()
+			lg.Error("Failed to register chunk server", slog.Int("attempt", attempt), slog.String("error", err.Error()))
+			return err
+		}
+		metrics.Registered.Set(1)
+		return nil
+	}, bo); err != nil {
+		if ctx.Err() != nil {
+			lg.Info("Registration cancelled")
+			return false
+		}
+		lg.Error("Failed to register chunk server", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	return true
+}
+
+// shutdown closes the listeners of the servers and waits for the requests in flight. Requests still running
+// when the context is done are aborted.
+func shutdown(ctx context.Context, server *http.Server, grpcServer *grpc.Server) {
+	lg := logger.GetLogger()
+
+	grpcStopped := make(chan bool, 1)
+	if grpcServer != nil {
+		go func() {
+			grpcStopped <- waitUntil(ctx, grpcServer.GracefulStop)
+		}()
+	} else {
+		grpcStopped <- true
+	}
+
+	if err := server.Shutdown(ctx); err != nil {
+		lg.Warn("Aborted requests in flight", slog.Any("error", err))
+		server.Close()
+	}
+	if !<-grpcStopped {
+		lg.Warn("Aborted gRPC calls in flight")
+		grpcServer.Stop()
+	}
+}
+
+// waitUntil runs wait and reports whether it returned before the context was done.
+func waitUntil(ctx context.Context, wait func()) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wait()
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	srv "simple-s3-adventure/internal/chunk_server/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopWrites(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	chunkService := srv.NewChunkService(&srv.ServerConfig{UploadDir: t.TempDir(), MaxUploadSize: 1 << 20}, logger, srv.WithStore(chunk_store.NewMemoryStore()))
+	mux := http.NewServeMux()
+	registerHandlers(mux, chunkService)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, putRequest(t, chunk_store.ChunkID{UUID: testUUID}, "chunk"))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	chunkService.StopWrites()
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, putRequest(t, chunk_store.ChunkID{UUID: testUUID}, "other"))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "shutting down")
+	assert.Empty(t, w.Header().Get(modeHeader))
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/delete?uuid="+testUUID+"&index=0", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/get?uuid="+testUUID+"&index=0", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "chunk", w.Body.String())
+}
+
+// startBlockingServer serves requests that block until release is closed, started tells when one arrived.
+func startBlockingServer(t *testing.T, release chan struct{}) (*http.Server, string, chan struct{}) {
+	t.Helper()
+	started := make(chan struct{}, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			w.Write([]byte("done"))
+		case <-r.Context().Done():
+		}
+	})}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(lis)
+	return server, "http://" + lis.Addr().String(), started
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("Requests in flight finish", func(t *testing.T) {
+		release := make(chan struct{})
+		server, address, started := startBlockingServer(t, release)
+
+		body := make(chan string, 1)
+		go func() {
+			resp, err := http.Get(address)
+			if err != nil {
+				body <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			data, _ := io.ReadAll(resp.Body)
+			body <- string(data)
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			shutdown(ctx, server, nil)
+		}()
+
+		// New connections are refused while the request in flight is running
+		require.Eventually(t, func() bool {
+			_, err := net.Dial("tcp", address[len("http://"):])
+			return err != nil
+		}, time.Second, 10*time.Millisecond)
+
+		close(release)
+		assert.Equal(t, "done", <-body)
+		<-stopped
+		assert.NoError(t, ctx.Err())
+	})
+
+	t.Run("Requests are aborted after the timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		server, address, started := startBlockingServer(t, release)
+
+		failed := make(chan error, 1)
+		go func() {
+			resp, err := http.Get(address)
+			if err == nil {
+				resp.Body.Close()
+			}
+			failed <- err
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		shutdown(ctx, server, nil)
+		assert.Error(t, <-failed)
+	})
+}
+
+func TestDeregister(t *testing.T) {
+	var (
+		method, token string
+		query         url.Values
+		status        = http.StatusOK
+	)
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, token, query = r.Method, r.Header.Get("Authorization"), r.URL.Query()
+		w.WriteHeader(status)
+	}))
+	defer front.Close()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	require.NoError(t, deregister(context.Background(), front.URL, "secret", "12090", "node-1"))
+	assert.Equal(t, http.MethodDelete, method)
+	assert.Equal(t, "Bearer secret", token)
+	assert.Equal(t, url.Values{"url": {serverURL(hostname, "12090")}, "id": {"node-1"}}, query)
+
+	// The front server doesn't know the chunk server
+	status = http.StatusNotFound
+	assert.NoError(t, deregister(context.Background(), front.URL, "secret", "12090", "node-1"))
+
+	status = http.StatusInternalServerError
+	assert.ErrorContains(t, deregister(context.Background(), front.URL, "secret", "12090", "node-1"), "500")
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package api
+
+import (
+	"context"
+
+	srv "simple-s3-adventure/internal/chunk_server/service"
+)
+
+// handleModeSignals does nothing, switching the mode by signal is not supported on this platform.
+func handleModeSignals(context.Context, *srv.ChunkService) {}
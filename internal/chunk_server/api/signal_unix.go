@@ -0,0 +1,41 @@
+//go:build linux || darwin
+
+package api
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	srv "simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/pkg/logger"
+)
+
+// modeSignals maps the signals switching the mode of the chunk server to the mode they enter. A signal
+// sent while the server is already in that mode switches it back to read-write.
+var modeSignals = map[os.Signal]srv.Mode{
+	syscall.SIGUSR1: srv.ModeReadOnly,
+	syscall.SIGUSR2: srv.ModeMaintenance,
+}
+
+// handleModeSignals switches the mode on SIGUSR1 and SIGUSR2 until the context is done.
+func handleModeSignals(ctx context.Context, chunkService *srv.ChunkService) {
+	signals := make(chan os.Signal, 1)
+	for sig := range modeSignals {
+		signal.Notify(signals, sig)
+	}
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			mode := toggleMode(chunkService.Mode(), modeSignals[sig])
+			logger.GetLogger().Info("Switching mode on signal", slog.String("signal", sig.String()), slog.String("mode", string(mode)))
+			chunkService.SetMode(mode)
+		}
+	}
+}
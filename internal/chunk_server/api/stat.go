@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	srv "simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/pkg/logger"
+)
+
+// StatLocation is where the chunk is kept on disk, in the response of /stat.
+type StatLocation struct {
+	Dir    string `json:"dir"`
+	Path   string `json:"path"`
+	Volume bool   `json:"volume"`
+	Offset int64  `json:"offset,omitempty"`
+}
+
+// StatResponse is the response of /stat. Checksum is the CRC-32 (IEEE) of the chunk in hex, it is empty
+// for a chunk written before checksums were kept until the scrubber has verified it.
+type StatResponse struct {
+	ID       string       `json:"id"`
+	UUID     string       `json:"uuid"`
+	Index    int          `json:"index"`
+	Size     int64        `json:"size"`
+	Checksum string       `json:"checksum,omitempty"`
+	ModTime  time.Time    `json:"mod_time"`
+	Location StatLocation `json:"location"`
+}
+
+// StatHandler describes the chunk given by the "id" parameter, in the form <uuid>_<index>.
+func StatHandler(w http.ResponseWriter, r *http.Request, chunkService *srv.ChunkService) {
+	lg := logger.GetLogger()
+
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := chunk_store.ParseChunkID(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	details, err := chunkService.InspectFile(id)
+	if errors.Is(err, srv.ErrFileNotFound) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to stat file", http.StatusInternalServerError)
+		return
+	}
+
+	resp := StatResponse{
+		ID:      id.String(),
+		UUID:    id.UUID,
+		Index:   id.Index,
+		Size:    details.Size,
+		ModTime: details.ModTime,
+		Location: StatLocation{
+			Dir:    details.Location.Dir,
+			Path:   details.Location.Path,
+			Volume: details.Location.Volume,
+			Offset: details.Location.Offset,
+		},
+	}
+	if details.HasChecksum {
+		resp.Checksum = fmt.Sprintf("%08x", details.Checksum)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		lg.Error("Failed to encode response", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,125 @@
+package chunk_store
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// CompactionStats describes a compaction.
+type CompactionStats struct {
+	// Volumes is the number of removed volumes
+	Volumes int
+	// Chunks is the number of chunks copied out of them
+	Chunks int
+	// ReclaimedBytes is the disk space freed
+	ReclaimedBytes int64
+}
+
+// Compact rewrites the volumes in which deleted and replaced chunks take at least minGarbage percent of the space:
+// their chunks are copied to the active volume, then the volume is removed. The active volume is never compacted.
+// Chunks are copied one by one, so reads and writes continue during compaction.
+//
+// If the server crashes before the volume is removed, the copies win on restart, as they are in a later volume,
+// and the next compaction removes the volume.
+func (s *FSStore) Compact(minGarbage int) (CompactionStats, error) {
+	if err := s.Load(); err != nil {
+		return CompactionStats{}, err
+	}
+
+	var stats CompactionStats
+	for _, vol := range s.compactionCandidates(minGarbage) {
+		chunks, copied, err := s.compactVolume(vol)
+		stats.Chunks += chunks
+		stats.ReclaimedBytes -= copied
+		if err != nil {
+			return stats, fmt.Errorf("failed to compact volume %d: %w", vol.id, err)
+		}
+		stats.Volumes++
+		stats.ReclaimedBytes += vol.size
+	}
+	return stats, nil
+}
+
+func (s *FSStore) compactionCandidates(minGarbage int) []*volume {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var candidates []*volume
+	for _, vol := range s.volumes {
+		if vol != s.active && vol.garbage() >= int64(minGarbage) {
+			candidates = append(candidates, vol)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].id < candidates[j].id
+	})
+	return candidates
+}
+
+// compactVolume copies the chunks of the volume to the active volume and removes it.
+// It returns the number of copied chunks and the size of their new records.
+func (s *FSStore) compactVolume(vol *volume) (int, int64, error) {
+	s.mu.RLock()
+	var ids []ChunkID
+	for id, e := range s.index {
+		if e.volume == vol {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.RUnlock()
+
+	var chunks int
+	var copied int64
+	for _, id := range ids {
+		n, err := s.moveRecord(id, vol)
+		if err != nil {
+			return chunks, copied, err
+		}
+		if n > 0 {
+			chunks++
+			copied += n
+		}
+	}
+
+	// Only the active volume receives new records, so no chunk can have been added to the volume meanwhile
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.volumes, vol.id)
+	vol.file.Close()
+	if err := os.Remove(vol.path); err != nil {
+		return chunks, copied, fmt.Errorf("failed to remove volume: %w", err)
+	}
+	return chunks, copied, nil
+}
+
+// moveRecord copies the chunk from the volume to the active volume and returns the size of the new record.
+// Chunks that were deleted or replaced meanwhile are skipped.
+func (s *FSStore) moveRecord(id ChunkID, from *volume) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.index[id]
+	if !ok || e.volume != from {
+		return 0, nil
+	}
+	data, err := from.read(e.offset, id, e.Size)
+	if err != nil {
+		return 0, err
+	}
+	record := encodeRecord(id, 0, e.ModTime, data)
+	to, err := s.activeVolume(int64(len(record)))
+	if err != nil {
+		return 0, err
+	}
+	offset, err := to.append(record)
+	if err != nil {
+		return 0, err
+	}
+	to.live += int64(len(record))
+	from.live -= int64(len(record))
+
+	e.volume, e.offset = to, offset
+	s.setEntry(e)
+	return int64(len(record)), nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package chunk_store
+
+import "errors"
+
+// DiskSpace is not supported on this platform.
+func DiskSpace(dir string) (free, total int64, err error) {
+	return 0, 0, errors.New("free disk space is not supported on this platform")
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package chunk_store
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// DiskSpace returns the number of bytes available to the chunk server and the size of the file system
+// of the directory.
+func DiskSpace(dir string) (free, total int64, err error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(dir, &stat); err != nil {
+		return 0, 0, fmt.Errorf("failed to get file system stats: %w", err)
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), int64(stat.Blocks) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,526 @@
+package chunk_store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tmpSuffix ends the names of chunks being written
+	tmpSuffix = ".tmp"
+	// checksumSuffix ends the names of the files next to chunk files that hold their CRC-32
+	checksumSuffix = ".crc"
+)
+
+// FSStore stores every chunk in a file named by its ID. The files are spread over two levels of directories
+// named by the first two bytes of the SHA-256 of the ID, like ab/cd/<uuid>_<index>, so a directory never holds
+// more than a small fraction of the chunks. Files with other names, like the node ID, are ignored.
+//
+// The CRC-32 of every chunk file is kept in a file named like the chunk with the .crc suffix, see Scrub.
+//
+// With volumes enabled, chunks smaller than the small chunk size are packed into volume files in the volumes
+// directory instead, see Compact for reclaiming the space of deleted chunks.
+//
+// The store keeps an index of its chunks in memory, sorted by ID, which answers Stat, List, ListPage and Usage
+// and rejects requests for missing chunks without touching the disk.
+type FSStore struct {
+	dir            string
+	smallChunkSize int64
+	volumeSize     int64
+
+	loadOnce sync.Once
+	loadErr  error
+
+	mu         sync.RWMutex
+	index      map[ChunkID]entry
+	sorted     sortedIDs // the IDs of the index in order, see setEntry
+	chunks     int       // the number of chunks in the index
+	bytes      int64     // the total size of the chunks in the index
+	volumes    map[int]*volume
+	active     *volume
+	nextVolume int
+}
+
+// entry locates a chunk, in its own file or in a volume if volume is set.
+type entry struct {
+	ChunkInfo
+	volume *volume
+	offset int64
+}
+
+type FSStoreOption func(*FSStore)
+
+// WithVolumes packs chunks smaller than smallChunkSize into volume files of about volumeSize bytes.
+// A zero smallChunkSize disables volumes.
+func WithVolumes(smallChunkSize, volumeSize int64) FSStoreOption {
+	return func(s *FSStore) {
+		s.smallChunkSize = smallChunkSize
+		s.volumeSize = volumeSize
+	}
+}
+
+func NewFSStore(dir string, opts ...FSStoreOption) *FSStore {
+	s := &FSStore{dir: dir}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Dir returns the directory of the store.
+func (s *FSStore) Dir() string {
+	return s.dir
+}
+
+// Load builds the index from the directory. Chunks stored directly in the directory by older versions
+// are moved to their shard directories first. Load runs once, before the first operation on the store,
+// so call it on startup to see its errors early. A missing directory is an empty store.
+func (s *FSStore) Load() error {
+	s.loadOnce.Do(func() {
+		s.loadErr = s.load()
+	})
+	return s.loadErr
+}
+
+func (s *FSStore) load() error {
+	s.index = make(map[ChunkID]entry)
+	s.volumes = make(map[int]*volume)
+
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read upload directory: %w", err)
+	}
+
+	// Volumes are loaded first, so a chunk found in a volume and in a file can be resolved
+	if err := s.loadVolumes(); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			if isShardName(name) {
+				if err := s.loadShard(name); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if strings.HasSuffix(name, tmpSuffix) {
+			// Left behind by a crash during an upload
+			prefix, _, _ := strings.Cut(name, ".")
+			if _, err := ParseChunkID(prefix); err == nil {
+				os.Remove(filepath.Join(s.dir, name))
+			}
+			continue
+		}
+		id, err := ParseChunkID(name)
+		if err != nil {
+			continue
+		}
+		info, err := s.migrate(id)
+		if err != nil {
+			return err
+		}
+		if err := s.addFile(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadShard adds the chunks in the shard directories below the top-level directory to the index.
+func (s *FSStore) loadShard(top string) error {
+	shards, err := os.ReadDir(filepath.Join(s.dir, top))
+	if err != nil {
+		return fmt.Errorf("failed to read shard directory: %w", err)
+	}
+	for _, shard := range shards {
+		if !shard.IsDir() || !isShardName(shard.Name()) {
+			continue
+		}
+		dir := filepath.Join(s.dir, top, shard.Name())
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("failed to read shard directory: %w", err)
+		}
+		var checksums []ChunkID
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			if name, ok := strings.CutSuffix(entry.Name(), checksumSuffix); ok {
+				if id, err := ParseChunkID(name); err == nil {
+					checksums = append(checksums, id)
+				}
+				continue
+			}
+			id, err := ParseChunkID(entry.Name())
+			// A chunk in the wrong shard could never be found by its ID
+			if err != nil || s.shardDir(id) != dir {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return fmt.Errorf("failed to stat file: %w", err)
+			}
+			if err := s.addFile(chunkInfo(id, info)); err != nil {
+				return err
+			}
+		}
+		// Left behind by a crash while a chunk file was deleted
+		for _, id := range checksums {
+			if e, ok := s.index[id]; !ok || e.volume != nil {
+				os.Remove(s.checksumPath(id))
+			}
+		}
+	}
+	return nil
+}
+
+// addFile adds a chunk file to the index while loading. After a crash while a chunk was being replaced,
+// the chunk can be both in a file and in a volume: the older copy is removed.
+func (s *FSStore) addFile(info ChunkInfo) error {
+	if old, ok := s.index[info.ID]; ok {
+		if old.ModTime.After(info.ModTime) {
+			return s.remove(entry{ChunkInfo: info})
+		}
+		if err := s.remove(old); err != nil {
+			return err
+		}
+	}
+	s.setEntry(entry{ChunkInfo: info})
+	return nil
+}
+
+// migrate moves the chunk from the flat layout of older versions to its shard directory.
+func (s *FSStore) migrate(id ChunkID) (ChunkInfo, error) {
+	if err := os.MkdirAll(s.shardDir(id), os.ModePerm); err != nil {
+		return ChunkInfo{}, fmt.Errorf("failed to create shard directory: %w", err)
+	}
+	if err := os.Rename(filepath.Join(s.dir, id.String()), s.path(id)); err != nil {
+		return ChunkInfo{}, fmt.Errorf("failed to move chunk %s: %w", id, err)
+	}
+	info, err := os.Stat(s.path(id))
+	if err != nil {
+		return ChunkInfo{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return chunkInfo(id, info), nil
+}
+
+func isShardName(name string) bool {
+	if len(name) != 2 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil && strings.ToLower(name) == name
+}
+
+func (s *FSStore) shardDir(id ChunkID) string {
+	sum := sha256.Sum256([]byte(id.String()))
+	prefix := hex.EncodeToString(sum[:2])
+	return filepath.Join(s.dir, prefix[:2], prefix[2:])
+}
+
+func (s *FSStore) path(id ChunkID) string {
+	return filepath.Join(s.shardDir(id), id.String())
+}
+
+func (s *FSStore) checksumPath(id ChunkID) string {
+	return s.path(id) + checksumSuffix
+}
+
+// setEntry adds or replaces the index entry of a chunk. The caller must hold the lock.
+func (s *FSStore) setEntry(e entry) {
+	if old, ok := s.index[e.ID]; ok {
+		s.bytes -= old.Size
+	} else {
+		s.chunks++
+	}
+	s.bytes += e.Size
+	s.index[e.ID] = e
+	s.sorted.add(e.ID)
+}
+
+// deleteEntry removes the index entry of a chunk. The caller must hold the lock.
+func (s *FSStore) deleteEntry(id ChunkID) {
+	if old, ok := s.index[id]; ok {
+		s.chunks--
+		s.bytes -= old.Size
+	}
+	delete(s.index, id)
+	s.sorted.remove(id)
+}
+
+// Usage returns the number of stored chunks and their total size, which are counted as chunks are stored
+// and deleted.
+func (s *FSStore) Usage() (int, int64, error) {
+	if err := s.Load(); err != nil {
+		return 0, 0, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.chunks, s.bytes, nil
+}
+
+func (s *FSStore) lookup(id ChunkID) (entry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.index[id]
+	return e, ok
+}
+
+// remove deletes the stored copy of the chunk, but not its index entry. The caller must hold the lock.
+func (s *FSStore) remove(e entry) error {
+	if e.volume != nil {
+		if _, err := e.volume.append(encodeTombstone(e.ID, e.offset)); err != nil {
+			return err
+		}
+		e.volume.live -= recordSize(e.ID, e.Size)
+		return nil
+	}
+	if err := os.Remove(s.path(e.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	if err := os.Remove(s.checksumPath(e.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete checksum: %w", err)
+	}
+	return nil
+}
+
+// Put writes the chunk to a temporary file first, so readers never see a partially written chunk.
+// Without overwrite, the file is linked to its final name, which fails atomically if the chunk exists.
+// Small chunks are read into memory and appended to a volume instead.
+func (s *FSStore) Put(id ChunkID, r io.Reader, overwrite bool) (int64, error) {
+	if err := s.Load(); err != nil {
+		return 0, err
+	}
+	if _, ok := s.lookup(id); ok && !overwrite {
+		return 0, ErrExists
+	}
+
+	if s.smallChunkSize > 0 {
+		data, err := io.ReadAll(io.LimitReader(r, s.smallChunkSize))
+		if err != nil {
+			return int64(len(data)), fmt.Errorf("failed to read chunk: %w", err)
+		}
+		if int64(len(data)) < s.smallChunkSize {
+			return int64(len(data)), s.putSmall(id, data, overwrite)
+		}
+		r = io.MultiReader(bytes.NewReader(data), r)
+	}
+
+	if err := os.MkdirAll(s.shardDir(id), os.ModePerm); err != nil {
+		return 0, fmt.Errorf("failed to create shard directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, id.String()+".*"+tmpSuffix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	checksum := crc32.NewIEEE()
+	n, err := io.Copy(io.MultiWriter(tmp, checksum), r)
+	if err != nil {
+		tmp.Close()
+		return n, fmt.Errorf("failed to write file: %w", err)
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return n, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return n, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	// The index must change together with the file
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, exists := s.index[id]
+	if exists && !overwrite {
+		return n, ErrExists
+	}
+	if overwrite {
+		// Without a checksum, a crash before the new one is written only costs the check of the new chunk
+		if err := os.Remove(s.checksumPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return n, fmt.Errorf("failed to delete checksum: %w", err)
+		}
+		if err := os.Rename(tmp.Name(), s.path(id)); err != nil {
+			return n, fmt.Errorf("failed to rename file: %w", err)
+		}
+	} else if err := os.Link(tmp.Name(), s.path(id)); errors.Is(err, os.ErrExist) {
+		return n, ErrExists
+	} else if err != nil {
+		return n, fmt.Errorf("failed to link file: %w", err)
+	}
+	s.setEntry(entry{ChunkInfo: chunkInfo(id, info)})
+	if err := s.writeChecksum(id, checksum.Sum32()); err != nil {
+		return n, err
+	}
+	if exists && old.volume != nil {
+		return n, s.remove(old)
+	}
+	return n, nil
+}
+
+// putSmall appends the chunk to the active volume.
+func (s *FSStore) putSmall(id ChunkID, data []byte, overwrite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, exists := s.index[id]
+	if exists && !overwrite {
+		return ErrExists
+	}
+	modTime := time.Now()
+	record := encodeRecord(id, 0, modTime, data)
+	vol, err := s.activeVolume(int64(len(record)))
+	if err != nil {
+		return err
+	}
+	offset, err := vol.append(record)
+	if err != nil {
+		return err
+	}
+	vol.live += int64(len(record))
+
+	s.setEntry(entry{ChunkInfo: ChunkInfo{ID: id, Size: int64(len(data)), ModTime: modTime}, volume: vol, offset: offset})
+	if exists {
+		return s.remove(old)
+	}
+	return nil
+}
+
+func (s *FSStore) Get(id ChunkID) (Chunk, error) {
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	e, ok := s.index[id]
+	if ok && e.volume != nil {
+		// Volumes are only closed under the write lock
+		data, err := e.volume.read(e.offset, id, e.Size)
+		s.mu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		return &bytesChunk{Reader: bytes.NewReader(data), info: e.ChunkInfo}, nil
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return &fileChunk{File: f, info: chunkInfo(id, info)}, nil
+}
+
+func (s *FSStore) Delete(id ChunkID) error {
+	if err := s.Load(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.index[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := s.remove(e); err != nil {
+		return err
+	}
+	s.deleteEntry(id)
+	return nil
+}
+
+func (s *FSStore) Stat(id ChunkID) (ChunkInfo, error) {
+	if err := s.Load(); err != nil {
+		return ChunkInfo{}, err
+	}
+	e, ok := s.lookup(id)
+	if !ok {
+		return ChunkInfo{}, ErrNotFound
+	}
+	return e.ChunkInfo, nil
+}
+
+func (s *FSStore) List() ([]ChunkInfo, error) {
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	chunks := make([]ChunkInfo, 0, len(s.index))
+	for _, e := range s.index {
+		chunks = append(chunks, e.ChunkInfo)
+	}
+	s.mu.RUnlock()
+
+	sortChunks(chunks)
+	return chunks, nil
+}
+
+func (s *FSStore) ListPage(after ChunkID, prefix string, limit int) ([]ChunkInfo, bool, error) {
+	if err := s.Load(); err != nil {
+		return nil, false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	chunks := []ChunkInfo{}
+	more := false
+	s.sorted.ascend(pageStart(after, prefix), func(id ChunkID) bool {
+		if !strings.HasPrefix(id.UUID, prefix) {
+			return false
+		}
+		if limit > 0 && len(chunks) == limit {
+			more = true
+			return false
+		}
+		chunks = append(chunks, s.index[id].ChunkInfo)
+		return true
+	})
+	return chunks, more, nil
+}
+
+func chunkInfo(id ChunkID, info os.FileInfo) ChunkInfo {
+	return ChunkInfo{ID: id, Size: info.Size(), ModTime: info.ModTime()}
+}
+
+type fileChunk struct {
+	*os.File
+	info ChunkInfo
+}
+
+func (c *fileChunk) Info() ChunkInfo {
+	return c.info
+}
@@ -0,0 +1,133 @@
+package chunk_store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSStore_Layout(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	store := NewFSStore(dir)
+
+	// A missing directory is an empty store, it is created by the first upload
+	chunks, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, chunks)
+
+	_, err = store.Put(testID1, bytes.NewReader([]byte("test content")), false)
+	require.NoError(t, err)
+
+	path := store.path(testID1)
+	rel, err := filepath.Rel(dir, path)
+	require.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f]{2}/[0-9a-f]{2}/`+testUUID+`_0$`, filepath.ToSlash(rel))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "test content", string(data))
+}
+
+func TestFSStore_SkipsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewFSStore(dir).Put(testID1, bytes.NewReader([]byte("12345")), false)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "node_id"), []byte(testUUID), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, testID2.String()+".123.tmp"), []byte("partial"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, testID2.String()+"_1"), os.ModePerm))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "00", "00"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00", "00", testID2.String()), []byte("wrong shard"), 0644))
+
+	store := NewFSStore(dir)
+	chunks, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, testID1, chunks[0].ID)
+	assert.Equal(t, int64(5), chunks[0].Size)
+
+	_, err = os.Stat(filepath.Join(dir, testID2.String()+".123.tmp"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "partial uploads must be removed")
+	_, err = os.Stat(filepath.Join(dir, "node_id"))
+	assert.NoError(t, err)
+}
+
+func TestFSStore_MigratesFlatLayout(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, testID1.String()), []byte("first"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, testID2.String()), []byte("second"), 0644))
+	// Chunks stored by their UUID alone predate chunk indexes and stay where they are
+	require.NoError(t, os.WriteFile(filepath.Join(dir, testUUID), []byte("legacy"), 0644))
+
+	store := NewFSStore(dir)
+	require.NoError(t, store.Load())
+
+	chunks, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+	assert.Equal(t, int64(5), chunks[0].Size)
+	assert.Equal(t, int64(6), chunks[1].Size)
+
+	for _, id := range []ChunkID{testID1, testID2} {
+		_, err := os.Stat(filepath.Join(dir, id.String()))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		_, err = os.Stat(store.path(id))
+		assert.NoError(t, err)
+	}
+	_, err = os.Stat(filepath.Join(dir, testUUID))
+	assert.NoError(t, err)
+
+	chunk, err := store.Get(testID2)
+	require.NoError(t, err)
+	assert.Equal(t, "second", readAll(t, chunk))
+}
+
+func TestFSStore_IndexSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir)
+	_, err := store.Put(testID1, bytes.NewReader([]byte("12345")), false)
+	require.NoError(t, err)
+	_, err = store.Put(testID2, bytes.NewReader([]byte("123")), false)
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(testID1))
+
+	restarted := NewFSStore(dir)
+	chunks, err := restarted.List()
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, testID2, chunks[0].ID)
+	assert.Equal(t, int64(3), chunks[0].Size)
+
+	_, err = restarted.Put(testID2, bytes.NewReader([]byte("new")), false)
+	assert.ErrorIs(t, err, ErrExists)
+}
+
+func TestFSStore_UsageSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir, WithVolumes(1<<10, 4<<10))
+	_, err := store.Put(testID1, bytes.NewReader(make([]byte, 100)), false)
+	require.NoError(t, err)
+	_, err = store.Put(testID2, bytes.NewReader(make([]byte, 2000)), false)
+	require.NoError(t, err)
+	_, err = store.Put(testID1, bytes.NewReader(make([]byte, 300)), true)
+	require.NoError(t, err)
+
+	chunks, size, err := NewFSStore(dir, WithVolumes(1<<10, 4<<10)).Usage()
+	require.NoError(t, err)
+	assert.Equal(t, 2, chunks)
+	assert.Equal(t, int64(2300), size)
+}
+
+func TestFSStore_LoadError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+
+	store := NewFSStore(file)
+	assert.Error(t, store.Load())
+	_, err := store.Stat(testID1)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,78 @@
+package chunk_store
+
+import "hash/crc32"
+
+// ChunkLocation is where a chunk is kept on disk.
+type ChunkLocation struct {
+	// Dir is the data directory of the chunk
+	Dir string
+	// Path is the chunk file, or the volume file holding the chunk
+	Path string
+	// Volume tells whether Path is a volume, Offset is then the offset of the record of the chunk
+	Volume bool
+	Offset int64
+}
+
+// ChunkDetails describes a chunk together with its checksum and its location.
+type ChunkDetails struct {
+	ChunkInfo
+	// Checksum is the CRC-32 (IEEE) of the chunk data, or false if the chunk has no checksum yet
+	Checksum    uint32
+	HasChecksum bool
+	Location    ChunkLocation
+}
+
+// Inspector is implemented by stores that can tell the checksum and the location of a chunk.
+type Inspector interface {
+	Inspect(id ChunkID) (ChunkDetails, error)
+}
+
+// Inspect describes the chunk with its checksum and location. The checksum of a chunk file is read from its
+// checksum file, a chunk in a volume is read, as its record checksum also covers the record header.
+func (s *FSStore) Inspect(id ChunkID) (ChunkDetails, error) {
+	if err := s.Load(); err != nil {
+		return ChunkDetails{}, err
+	}
+	s.mu.RLock()
+	e, ok := s.index[id]
+	details := ChunkDetails{ChunkInfo: e.ChunkInfo, Location: ChunkLocation{Dir: s.dir}}
+	if ok && e.volume != nil {
+		// Volumes are only closed under the write lock, so compaction can't move the record while it is read
+		data, err := e.volume.read(e.offset, e.ID, e.Size)
+		s.mu.RUnlock()
+		if err != nil {
+			return ChunkDetails{}, err
+		}
+		details.Checksum, details.HasChecksum = crc32.ChecksumIEEE(data), true
+		details.Location.Path, details.Location.Volume, details.Location.Offset = e.volume.path, true, e.offset
+		return details, nil
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return ChunkDetails{}, ErrNotFound
+	}
+
+	checksum, ok, err := s.readChecksum(id)
+	if err != nil {
+		return ChunkDetails{}, err
+	}
+	details.Checksum, details.HasChecksum = checksum, ok
+	details.Location.Path = s.path(id)
+	return details, nil
+}
+
+// Inspect describes the chunk, see FSStore.Inspect. The directory goes offline if reading the chunk fails.
+func (s *MultiStore) Inspect(id ChunkID) (ChunkDetails, error) {
+	if err := s.Load(); err != nil {
+		return ChunkDetails{}, err
+	}
+	d, ok := s.find(id)
+	if !ok {
+		return ChunkDetails{}, ErrNotFound
+	}
+	details, err := d.store.Inspect(id)
+	if err != nil {
+		s.check(d, DiskOffline, err)
+	}
+	return details, err
+}
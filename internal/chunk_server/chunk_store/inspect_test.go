@@ -0,0 +1,58 @@
+package chunk_store
+
+import (
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSStore_Inspect(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir, WithVolumes(10, 1<<20))
+	_, err := store.Put(testID1, strings.NewReader("large content"), false)
+	require.NoError(t, err)
+	_, err = store.Put(testID2, strings.NewReader("small"), false)
+	require.NoError(t, err)
+
+	details, err := store.Inspect(testID1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(13), details.Size)
+	assert.True(t, details.HasChecksum)
+	assert.Equal(t, crc32.ChecksumIEEE([]byte("large content")), details.Checksum)
+	assert.Equal(t, ChunkLocation{Dir: dir, Path: store.path(testID1)}, details.Location)
+
+	details, err = store.Inspect(testID2)
+	require.NoError(t, err)
+	assert.Equal(t, crc32.ChecksumIEEE([]byte("small")), details.Checksum)
+	assert.True(t, details.Location.Volume)
+	assert.Equal(t, volumeFiles(t, dir)[0], details.Location.Path)
+	assert.Positive(t, details.Location.Offset)
+
+	require.NoError(t, os.Remove(store.checksumPath(testID1)))
+	details, err = store.Inspect(testID1)
+	require.NoError(t, err)
+	assert.False(t, details.HasChecksum)
+
+	_, err = store.Inspect(ChunkID{UUID: testID1.UUID, Index: 5})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMultiStore_Inspect(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	store, _ := newTestMultiStore(t, map[string]int64{dir1: 100, dir2: 200}, dir1, dir2)
+	_, err := store.Put(testID1, strings.NewReader("content"), false)
+	require.NoError(t, err)
+
+	details, err := store.Inspect(testID1)
+	require.NoError(t, err)
+	assert.Equal(t, dir2, details.Location.Dir)
+	assert.Equal(t, dir2, filepath.Dir(filepath.Dir(filepath.Dir(details.Location.Path))))
+
+	_, err = store.Inspect(testID2)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
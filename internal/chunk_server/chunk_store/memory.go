@@ -0,0 +1,102 @@
+package chunk_store
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps chunks in memory. It is meant for tests.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	chunks map[ChunkID]memoryChunk
+}
+
+type memoryChunk struct {
+	data    []byte
+	modTime time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{chunks: make(map[ChunkID]memoryChunk)}
+}
+
+func (s *MemoryStore) Put(id ChunkID, r io.Reader, overwrite bool) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return int64(len(data)), fmt.Errorf("failed to read chunk: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.chunks[id]; exists && !overwrite {
+		return int64(len(data)), ErrExists
+	}
+	s.chunks[id] = memoryChunk{data: data, modTime: time.Now()}
+	return int64(len(data)), nil
+}
+
+func (s *MemoryStore) Get(id ChunkID) (Chunk, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chunk, ok := s.chunks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	// The data is never modified, Put replaces it
+	return &bytesChunk{Reader: bytes.NewReader(chunk.data), info: chunk.info(id)}, nil
+}
+
+func (s *MemoryStore) Delete(id ChunkID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.chunks[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.chunks, id)
+	return nil
+}
+
+func (s *MemoryStore) Stat(id ChunkID) (ChunkInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chunk, ok := s.chunks[id]
+	if !ok {
+		return ChunkInfo{}, ErrNotFound
+	}
+	return chunk.info(id), nil
+}
+
+func (s *MemoryStore) List() ([]ChunkInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chunks := make([]ChunkInfo, 0, len(s.chunks))
+	for id, chunk := range s.chunks {
+		chunks = append(chunks, chunk.info(id))
+	}
+	sortChunks(chunks)
+	return chunks, nil
+}
+
+func (c memoryChunk) info(id ChunkID) ChunkInfo {
+	return ChunkInfo{ID: id, Size: int64(len(c.data)), ModTime: c.modTime}
+}
+
+type bytesChunk struct {
+	*bytes.Reader
+	info ChunkInfo
+}
+
+func (c *bytesChunk) Close() error {
+	return nil
+}
+
+func (c *bytesChunk) Info() ChunkInfo {
+	return c.info
+}
@@ -0,0 +1,510 @@
+package chunk_store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var errNoDisk = errors.New("no writable data directory")
+
+// DiskState is the health of a data directory of a MultiStore.
+type DiskState string
+
+const (
+	// DiskOnline directories store and serve chunks
+	DiskOnline DiskState = "online"
+	// DiskReadOnly directories failed a write, they serve and delete their chunks but receive no new ones
+	DiskReadOnly DiskState = "read_only"
+	// DiskOffline directories failed a read or could not be loaded, their chunks are not served
+	DiskOffline DiskState = "offline"
+)
+
+// DiskStates lists the states from the healthiest to the worst.
+var DiskStates = []DiskState{DiskOnline, DiskReadOnly, DiskOffline}
+
+func (st DiskState) severity() int {
+	for i, state := range DiskStates {
+		if st == state {
+			return i
+		}
+	}
+	return 0
+}
+
+// DiskStatus describes a data directory.
+type DiskStatus struct {
+	Dir   string
+	State DiskState
+	// Err is the error that took the directory out of the online state
+	Err error
+	// Chunks and Bytes describe the chunks stored in the directory, they are zero if it is offline
+	Chunks int
+	Bytes  int64
+	// FreeBytes and TotalBytes describe the file system of the directory, they are zero if it can't be measured
+	FreeBytes  int64
+	TotalBytes int64
+}
+
+// DiskReporter is implemented by stores that spread their chunks over several data directories.
+type DiskReporter interface {
+	Disks() []DiskStatus
+}
+
+type disk struct {
+	store *FSStore
+	state DiskState
+	err   error
+}
+
+// MultiStore spreads chunks over the stores of several data directories, usually on different disks.
+// A new chunk goes to the online directory with the most free space, a replaced chunk stays in its directory
+// unless the directory stopped receiving chunks.
+//
+// A directory whose write fails becomes read-only, one whose read fails or that can't be loaded goes offline.
+// So a failing disk costs the server the chunks on that disk, which the front server repairs from their
+// replicas, but not the chunks on the other disks. States are kept until the server restarts.
+type MultiStore struct {
+	disks     []*disk
+	diskSpace func(dir string) (free, total int64, err error)
+	onFailure func(dir string, state DiskState, err error)
+
+	loadOnce sync.Once
+	loadErr  error
+
+	// mu guards the states of the disks
+	mu    sync.RWMutex
+	locks chunkLocks
+}
+
+type MultiStoreOption func(*MultiStore)
+
+// WithDiskSpace measures the file systems of the directories with fn instead of DiskSpace.
+func WithDiskSpace(fn func(dir string) (free, total int64, err error)) MultiStoreOption {
+	return func(s *MultiStore) {
+		s.diskSpace = fn
+	}
+}
+
+// OnDiskFailure calls fn when a directory becomes read-only or goes offline, with the error that caused it.
+func OnDiskFailure(fn func(dir string, state DiskState, err error)) MultiStoreOption {
+	return func(s *MultiStore) {
+		s.onFailure = fn
+	}
+}
+
+func NewMultiStore(stores []*FSStore, opts ...MultiStoreOption) *MultiStore {
+	s := &MultiStore{diskSpace: DiskSpace}
+	for _, store := range stores {
+		s.disks = append(s.disks, &disk{store: store, state: DiskOnline})
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Load loads the stores of all directories. A directory that fails to load goes offline,
+// Load only fails if all of them do.
+func (s *MultiStore) Load() error {
+	s.loadOnce.Do(func() {
+		s.loadErr = s.load()
+	})
+	return s.loadErr
+}
+
+func (s *MultiStore) load() error {
+	var errs []error
+	for _, d := range s.disks {
+		if err := d.store.Load(); err != nil {
+			s.fail(d, DiskOffline, err)
+			errs = append(errs, fmt.Errorf("%s: %w", d.store.Dir(), err))
+		}
+	}
+	if len(errs) == len(s.disks) {
+		return errors.Join(errs...)
+	}
+	s.removeDuplicates()
+	return nil
+}
+
+// removeDuplicates keeps the newest copy of chunks found in several directories. Copies are left behind
+// by a crash while a chunk moved to another directory, or by a directory that was offline while its chunks
+// were stored again.
+func (s *MultiStore) removeDuplicates() {
+	type found struct {
+		disk    *disk
+		modTime time.Time
+	}
+	newest := make(map[ChunkID]found)
+	for _, d := range s.available() {
+		chunks, err := d.store.List()
+		if err != nil {
+			continue
+		}
+		for _, chunk := range chunks {
+			other, ok := newest[chunk.ID]
+			if !ok {
+				newest[chunk.ID] = found{disk: d, modTime: chunk.ModTime}
+				continue
+			}
+			stale := d
+			if chunk.ModTime.After(other.modTime) {
+				stale = other.disk
+				newest[chunk.ID] = found{disk: d, modTime: chunk.ModTime}
+			}
+			if err := stale.store.Delete(chunk.ID); err != nil {
+				s.check(stale, DiskReadOnly, err)
+			}
+		}
+	}
+}
+
+// Put stores the chunk in the directory of the chunk or in the directory with the most free space.
+// A directory whose write fails becomes read-only, unless reading r failed. The chunk isn't retried
+// elsewhere, as r is consumed, but the next attempt goes to another directory.
+func (s *MultiStore) Put(id ChunkID, r io.Reader, overwrite bool) (int64, error) {
+	if err := s.Load(); err != nil {
+		return 0, err
+	}
+	unlock := s.locks.lock(id)
+	defer unlock()
+
+	old, exists := s.find(id)
+	if exists && !overwrite {
+		return 0, ErrExists
+	}
+	d := old
+	if !exists || s.state(old) != DiskOnline {
+		if d = s.place(); d == nil {
+			return 0, errNoDisk
+		}
+	}
+
+	src := &sourceReader{Reader: r}
+	n, err := d.store.Put(id, src, overwrite)
+	if err != nil {
+		if src.err == nil {
+			s.check(d, DiskReadOnly, err)
+		}
+		return n, err
+	}
+	if exists && old != d {
+		// The new copy wins on restart if this fails, as it is newer
+		if err := old.store.Delete(id); err != nil {
+			s.check(old, DiskReadOnly, err)
+		}
+	}
+	return n, nil
+}
+
+// Get opens the chunk. The directory goes offline if opening or reading the chunk fails.
+func (s *MultiStore) Get(id ChunkID) (Chunk, error) {
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+	d, ok := s.find(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	chunk, err := d.store.Get(id)
+	if err != nil {
+		s.check(d, DiskOffline, err)
+		return nil, err
+	}
+	return &diskChunk{Chunk: chunk, store: s, disk: d}, nil
+}
+
+// Delete removes the chunk, also from read-only directories, as deleting frees space on a full disk.
+func (s *MultiStore) Delete(id ChunkID) error {
+	if err := s.Load(); err != nil {
+		return err
+	}
+	unlock := s.locks.lock(id)
+	defer unlock()
+
+	d, ok := s.find(id)
+	if !ok {
+		return ErrNotFound
+	}
+	if err := d.store.Delete(id); err != nil {
+		s.check(d, DiskReadOnly, err)
+		return err
+	}
+	return nil
+}
+
+func (s *MultiStore) Stat(id ChunkID) (ChunkInfo, error) {
+	if err := s.Load(); err != nil {
+		return ChunkInfo{}, err
+	}
+	d, ok := s.find(id)
+	if !ok {
+		return ChunkInfo{}, ErrNotFound
+	}
+	return d.store.Stat(id)
+}
+
+// List describes the chunks of the directories that are not offline.
+func (s *MultiStore) List() ([]ChunkInfo, error) {
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+	chunks := []ChunkInfo{}
+	for _, d := range s.available() {
+		list, err := d.store.List()
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, list...)
+	}
+	sortChunks(chunks)
+	return chunks, nil
+}
+
+// Usage counts the chunks of the directories that are not offline, see FSStore.Usage.
+func (s *MultiStore) Usage() (int, int64, error) {
+	if err := s.Load(); err != nil {
+		return 0, 0, err
+	}
+	var chunks int
+	var bytes int64
+	for _, d := range s.available() {
+		n, size, err := d.store.Usage()
+		if err != nil {
+			return 0, 0, err
+		}
+		chunks += n
+		bytes += size
+	}
+	return chunks, bytes, nil
+}
+
+// ListPage describes a page of the chunks of the directories that are not offline, see Pager.
+func (s *MultiStore) ListPage(after ChunkID, prefix string, limit int) ([]ChunkInfo, bool, error) {
+	if err := s.Load(); err != nil {
+		return nil, false, err
+	}
+	chunks := []ChunkInfo{}
+	more := false
+	for _, d := range s.available() {
+		page, diskMore, err := d.store.ListPage(after, prefix, limit)
+		if err != nil {
+			return nil, false, err
+		}
+		chunks = append(chunks, page...)
+		more = more || diskMore
+	}
+	sortChunks(chunks)
+	if limit > 0 && len(chunks) > limit {
+		chunks, more = chunks[:limit], true
+	}
+	return chunks, more, nil
+}
+
+// Compact compacts the volumes of the online directories, see FSStore.Compact. A directory whose
+// compaction fails becomes read-only, the other directories are still compacted.
+func (s *MultiStore) Compact(minGarbage int) (CompactionStats, error) {
+	if err := s.Load(); err != nil {
+		return CompactionStats{}, err
+	}
+	var total CompactionStats
+	var errs []error
+	for _, d := range s.disks {
+		if s.state(d) != DiskOnline {
+			continue
+		}
+		stats, err := d.store.Compact(minGarbage)
+		total.Volumes += stats.Volumes
+		total.Chunks += stats.Chunks
+		total.ReclaimedBytes += stats.ReclaimedBytes
+		if err != nil {
+			s.check(d, DiskReadOnly, err)
+			errs = append(errs, fmt.Errorf("%s: %w", d.store.Dir(), err))
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// Scrub verifies the chunk, see FSStore.Scrub. The directory goes offline if reading the chunk fails.
+func (s *MultiStore) Scrub(id ChunkID) (int64, error) {
+	if err := s.Load(); err != nil {
+		return 0, err
+	}
+	unlock := s.locks.lock(id)
+	defer unlock()
+
+	d, ok := s.find(id)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	n, err := d.store.Scrub(id)
+	if err != nil {
+		s.check(d, DiskOffline, err)
+	}
+	return n, err
+}
+
+// Disks describes the directories in the order they were given.
+func (s *MultiStore) Disks() []DiskStatus {
+	statuses := make([]DiskStatus, 0, len(s.disks))
+	for _, d := range s.disks {
+		s.mu.RLock()
+		status := DiskStatus{Dir: d.store.Dir(), State: d.state, Err: d.err}
+		s.mu.RUnlock()
+
+		if status.State != DiskOffline {
+			if chunks, bytes, err := d.store.Usage(); err == nil {
+				status.Chunks, status.Bytes = chunks, bytes
+			}
+		}
+		status.FreeBytes, status.TotalBytes, _ = s.diskSpace(status.Dir)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// find returns the directory holding the chunk, ignoring offline directories.
+func (s *MultiStore) find(id ChunkID) (*disk, bool) {
+	for _, d := range s.available() {
+		if _, err := d.store.Stat(id); err == nil {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// available returns the directories that are not offline.
+func (s *MultiStore) available() []*disk {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	disks := make([]*disk, 0, len(s.disks))
+	for _, d := range s.disks {
+		if d.state != DiskOffline {
+			disks = append(disks, d)
+		}
+	}
+	return disks
+}
+
+// place returns the online directory with the most free space, the first one on a tie.
+// A directory whose free space can't be measured is only chosen if no other is online.
+func (s *MultiStore) place() *disk {
+	var best *disk
+	bestFree := int64(-1)
+	for _, d := range s.disks {
+		if s.state(d) != DiskOnline {
+			continue
+		}
+		free, _, err := s.diskSpace(d.store.Dir())
+		if err != nil {
+			free = 0
+		}
+		if free > bestFree {
+			best, bestFree = d, free
+		}
+	}
+	return best
+}
+
+func (s *MultiStore) state(d *disk) DiskState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return d.state
+}
+
+// check moves the directory to the state if err comes from the file system. Missing chunks,
+// checksum mismatches and invalid requests say nothing about the health of the disk.
+func (s *MultiStore) check(d *disk, state DiskState, err error) {
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	var errno syscall.Errno
+	if errors.As(err, &pathErr) || errors.As(err, &linkErr) || errors.As(err, &errno) {
+		s.fail(d, state, err)
+	}
+}
+
+// fail moves the directory to the state, unless it is already in a worse one.
+func (s *MultiStore) fail(d *disk, state DiskState, err error) {
+	s.mu.Lock()
+	if state.severity() <= d.state.severity() {
+		s.mu.Unlock()
+		return
+	}
+	d.state, d.err = state, err
+	s.mu.Unlock()
+
+	if s.onFailure != nil {
+		s.onFailure(d.store.Dir(), state, err)
+	}
+}
+
+// diskChunk takes its directory offline when reading fails.
+type diskChunk struct {
+	Chunk
+	store *MultiStore
+	disk  *disk
+}
+
+func (c *diskChunk) Read(p []byte) (int, error) {
+	n, err := c.Chunk.Read(p)
+	if err != nil && err != io.EOF {
+		c.store.check(c.disk, DiskOffline, err)
+	}
+	return n, err
+}
+
+// sourceReader remembers the read error of the uploaded chunk, so it isn't taken for a disk error.
+type sourceReader struct {
+	io.Reader
+	err error
+}
+
+func (r *sourceReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// chunkLocks serializes the writes of each chunk, so no chunk is stored in two directories at once.
+type chunkLocks struct {
+	mu    sync.Mutex
+	locks map[ChunkID]*chunkLock
+}
+
+type chunkLock struct {
+	sync.Mutex
+	holders int
+}
+
+// lock locks the chunk and returns the function unlocking it.
+func (l *chunkLocks) lock(id ChunkID) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[ChunkID]*chunkLock)
+	}
+	cl, ok := l.locks[id]
+	if !ok {
+		cl = &chunkLock{}
+		l.locks[id] = cl
+	}
+	cl.holders++
+	l.mu.Unlock()
+
+	cl.Lock()
+	return func() {
+		cl.Unlock()
+		l.mu.Lock()
+		if cl.holders--; cl.holders == 0 {
+			delete(l.locks, id)
+		}
+		l.mu.Unlock()
+	}
+}
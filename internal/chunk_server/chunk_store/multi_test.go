@@ -0,0 +1,196 @@
+package chunk_store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDiskSpace reports the free space of the directories from the map.
+func fakeDiskSpace(free map[string]int64) func(string) (int64, int64, error) {
+	return func(dir string) (int64, int64, error) {
+		return free[dir], 1 << 30, nil
+	}
+}
+
+type failure struct {
+	dir   string
+	state DiskState
+}
+
+func newTestMultiStore(t *testing.T, free map[string]int64, dirs ...string) (*MultiStore, *[]failure) {
+	t.Helper()
+	stores := make([]*FSStore, 0, len(dirs))
+	for _, dir := range dirs {
+		stores = append(stores, NewFSStore(dir))
+	}
+	var failures []failure
+	store := NewMultiStore(stores, WithDiskSpace(fakeDiskSpace(free)), OnDiskFailure(func(dir string, state DiskState, err error) {
+		failures = append(failures, failure{dir: dir, state: state})
+	}))
+	require.NoError(t, store.Load())
+	return store, &failures
+}
+
+func diskStates(store *MultiStore) []DiskState {
+	var states []DiskState
+	for _, disk := range store.Disks() {
+		states = append(states, disk.State)
+	}
+	return states
+}
+
+func TestMultiStore_PlacesByFreeSpace(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	free := map[string]int64{dir1: 100, dir2: 200}
+	store, _ := newTestMultiStore(t, free, dir1, dir2)
+
+	_, err := store.Put(testID1, strings.NewReader("first"), false)
+	require.NoError(t, err)
+	free[dir1] = 300
+	_, err = store.Put(testID2, strings.NewReader("second"), false)
+	require.NoError(t, err)
+
+	_, err = NewFSStore(dir2).Stat(testID1)
+	assert.NoError(t, err)
+	_, err = NewFSStore(dir1).Stat(testID2)
+	assert.NoError(t, err)
+
+	// A replaced chunk stays in its directory
+	_, err = store.Put(testID1, strings.NewReader("replaced"), true)
+	require.NoError(t, err)
+	assert.Equal(t, "replaced", chunkData(t, NewFSStore(dir2), testID1))
+	_, err = store.Put(testID1, strings.NewReader("again"), false)
+	assert.ErrorIs(t, err, ErrExists)
+
+	disks := store.Disks()
+	require.Len(t, disks, 2)
+	assert.Equal(t, DiskStatus{Dir: dir1, State: DiskOnline, Chunks: 1, Bytes: 6, FreeBytes: 300, TotalBytes: 1 << 30}, disks[0])
+	assert.Equal(t, DiskStatus{Dir: dir2, State: DiskOnline, Chunks: 1, Bytes: 8, FreeBytes: 200, TotalBytes: 1 << 30}, disks[1])
+}
+
+func TestMultiStore_WriteErrorMakesDirectoryReadOnly(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	store, failures := newTestMultiStore(t, map[string]int64{dir1: 200, dir2: 100}, dir1, dir2)
+	_, err := store.Put(testID1, strings.NewReader("kept"), false)
+	require.NoError(t, err)
+
+	// Reading the upload fails, the disk is fine
+	_, err = store.Put(testID2, iotest.ErrReader(errors.New("connection reset")), false)
+	require.Error(t, err)
+	assert.Equal(t, []DiskState{DiskOnline, DiskOnline}, diskStates(store))
+
+	// The chunk file is replaced by a directory, so writing it fails
+	chunkPath := NewFSStore(dir1).path(testID1)
+	require.NoError(t, os.Remove(chunkPath))
+	require.NoError(t, os.Mkdir(chunkPath, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chunkPath, "file"), nil, 0644))
+	_, err = store.Put(testID1, strings.NewReader("replaced"), true)
+	require.Error(t, err)
+	assert.Equal(t, []DiskState{DiskReadOnly, DiskOnline}, diskStates(store))
+	assert.Equal(t, []failure{{dir: dir1, state: DiskReadOnly}}, *failures)
+
+	// New chunks and replaced chunks go to the online directory
+	_, err = store.Put(testID2, strings.NewReader("new"), false)
+	require.NoError(t, err)
+	assert.Equal(t, "new", chunkData(t, NewFSStore(dir2), testID2))
+	require.NoError(t, os.Remove(filepath.Join(chunkPath, "file")))
+	_, err = store.Put(testID1, strings.NewReader("moved"), true)
+	require.NoError(t, err)
+	assert.Equal(t, "moved", chunkData(t, store, testID1))
+	assert.Equal(t, "moved", chunkData(t, NewFSStore(dir2), testID1))
+
+	chunks, err := store.List()
+	require.NoError(t, err)
+	assert.Len(t, chunks, 2)
+}
+
+func TestMultiStore_ReadErrorTakesDirectoryOffline(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	free := map[string]int64{dir1: 200, dir2: 100}
+	store, failures := newTestMultiStore(t, free, dir1, dir2)
+	_, err := store.Put(testID1, strings.NewReader("lost"), false)
+	require.NoError(t, err)
+	free[dir1], free[dir2] = 100, 200
+	_, err = store.Put(testID2, strings.NewReader("kept"), false)
+	require.NoError(t, err)
+
+	// The chunk file is replaced by a directory, so reading it fails
+	chunkPath := NewFSStore(dir1).path(testID1)
+	require.NoError(t, os.Remove(chunkPath))
+	require.NoError(t, os.Mkdir(chunkPath, 0755))
+	chunk, err := store.Get(testID1)
+	require.NoError(t, err)
+	_, err = chunk.Read(make([]byte, 10))
+	require.Error(t, err)
+	chunk.Close()
+	assert.Equal(t, []DiskState{DiskOffline, DiskOnline}, diskStates(store))
+	assert.Equal(t, []failure{{dir: dir1, state: DiskOffline}}, *failures)
+
+	// The chunks of the offline directory are gone, the others are served
+	_, err = store.Stat(testID1)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, "kept", chunkData(t, store, testID2))
+	chunks, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, testID2, chunks[0].ID)
+
+	// A lost chunk can be stored again
+	_, err = store.Put(testID1, strings.NewReader("repaired"), false)
+	require.NoError(t, err)
+	assert.Equal(t, "repaired", chunkData(t, store, testID1))
+}
+
+func TestMultiStore_Load(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	_, err := NewFSStore(dir1).Put(testID1, strings.NewReader("old"), false)
+	require.NoError(t, err)
+	_, err = NewFSStore(dir2).Put(testID1, strings.NewReader("new"), false)
+	require.NoError(t, err)
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(NewFSStore(dir1).path(testID1), past, past))
+
+	// A crash while the chunk moved between directories left two copies, the newest wins
+	store, _ := newTestMultiStore(t, nil, dir1, dir2)
+	assert.Equal(t, "new", chunkData(t, store, testID1))
+	_, err = os.Stat(NewFSStore(dir1).path(testID1))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// A directory that can't be loaded goes offline
+	broken := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(broken, nil, 0644))
+	store, failures := newTestMultiStore(t, nil, broken, dir2)
+	assert.Equal(t, []DiskState{DiskOffline, DiskOnline}, diskStates(store))
+	assert.Equal(t, []failure{{dir: broken, state: DiskOffline}}, *failures)
+	assert.Equal(t, "new", chunkData(t, store, testID1))
+
+	// Loading fails if no directory can be loaded
+	assert.Error(t, NewMultiStore([]*FSStore{NewFSStore(broken)}).Load())
+}
+
+func TestMultiStore_NoWritableDirectory(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := newTestMultiStore(t, nil, dir)
+	require.NoError(t, os.RemoveAll(dir))
+	require.NoError(t, os.WriteFile(dir, nil, 0644))
+
+	_, err := store.Put(testID1, strings.NewReader("test"), false)
+	require.Error(t, err)
+	_, err = store.Put(testID1, strings.NewReader("test"), false)
+	assert.ErrorIs(t, err, errNoDisk)
+}
+
+func TestDiskSpace(t *testing.T) {
+	free, total, err := DiskSpace(t.TempDir())
+	require.NoError(t, err)
+	assert.Positive(t, free)
+	assert.GreaterOrEqual(t, total, free)
+}
@@ -0,0 +1,146 @@
+package chunk_store
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// quarantineDir holds the corrupted chunks removed by Scrub, for an operator to inspect and delete.
+const quarantineDir = "quarantine"
+
+func (s *FSStore) writeChecksum(id ChunkID, checksum uint32) error {
+	if err := os.WriteFile(s.checksumPath(id), []byte(fmt.Sprintf("%08x", checksum)), 0644); err != nil {
+		return fmt.Errorf("failed to write checksum: %w", err)
+	}
+	return nil
+}
+
+// readChecksum returns the checksum of the chunk file, or false if it has none. A torn checksum file,
+// left by a crash, counts as missing.
+func (s *FSStore) readChecksum(id ChunkID) (uint32, bool, error) {
+	data, err := os.ReadFile(s.checksumPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, fmt.Errorf("failed to read checksum: %w", err)
+	}
+	checksum, err := strconv.ParseUint(string(data), 16, 32)
+	if err != nil || len(data) != 8 {
+		return 0, false, nil
+	}
+	return uint32(checksum), true, nil
+}
+
+// Scrub reads the chunk and compares it with its checksum, it returns the number of bytes read.
+// A corrupted chunk is moved to the quarantine directory and removed from the store, and ErrChecksum
+// is returned. A chunk file without a checksum, written by an older version, gets one.
+//
+// A chunk replaced or deleted while it is read is skipped.
+func (s *FSStore) Scrub(id ChunkID) (int64, error) {
+	if err := s.Load(); err != nil {
+		return 0, err
+	}
+	s.mu.RLock()
+	e, ok := s.index[id]
+	if ok && e.volume != nil {
+		// Volumes are only closed under the write lock, so compaction can't move the record while it is read
+		_, err := e.volume.read(e.offset, id, e.Size)
+		s.mu.RUnlock()
+		return s.scrubRecord(e, err)
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return 0, ErrNotFound
+	}
+
+	expected, hasChecksum, err := s.readChecksum(id)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Open(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrNotFound
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	checksum := crc32.NewIEEE()
+	n, err := io.Copy(checksum, f)
+	f.Close()
+	if err != nil {
+		return n, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.index[id]; !ok || !current.same(e) {
+		return n, nil
+	}
+	if !hasChecksum {
+		return n, s.writeChecksum(id, checksum.Sum32())
+	}
+	if checksum.Sum32() == expected && n == e.Size {
+		return n, nil
+	}
+	if err := s.quarantine(id, func(path string) error {
+		return os.Rename(s.path(id), path)
+	}); err != nil {
+		return n, err
+	}
+	if err := os.Remove(s.checksumPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return n, fmt.Errorf("failed to delete checksum: %w", err)
+	}
+	s.deleteEntry(id)
+	return n, fmt.Errorf("chunk %s: %w", id, ErrChecksum)
+}
+
+// scrubRecord quarantines the record of a chunk in a volume if reading it failed with ErrChecksum.
+func (s *FSStore) scrubRecord(e entry, err error) (int64, error) {
+	if !errors.Is(err, ErrChecksum) {
+		return e.Size, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.index[e.ID]; !ok || !current.same(e) {
+		return e.Size, nil
+	}
+	record, err := e.volume.readRecord(e.offset, e.ID, e.Size)
+	if err != nil {
+		return e.Size, err
+	}
+	if err := s.quarantine(e.ID, func(path string) error {
+		return os.WriteFile(path, record, 0644)
+	}); err != nil {
+		return e.Size, err
+	}
+	if err := s.remove(e); err != nil {
+		return e.Size, err
+	}
+	s.deleteEntry(e.ID)
+	return e.Size, fmt.Errorf("chunk %s: %w", e.ID, ErrChecksum)
+}
+
+// quarantine calls move to put the chunk at its path in the quarantine directory. The time is part
+// of the name, so a chunk corrupted again after its repair doesn't replace the earlier copy.
+func (s *FSStore) quarantine(id ChunkID, move func(path string) error) error {
+	dir := filepath.Join(s.dir, quarantineDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s.%d", id, time.Now().UnixNano()))
+	if err := move(path); err != nil {
+		return fmt.Errorf("failed to quarantine chunk %s: %w", id, err)
+	}
+	return nil
+}
+
+// same reports whether both entries describe the same copy of the chunk.
+func (e entry) same(other entry) bool {
+	return e.volume == other.volume && e.offset == other.offset && e.Size == other.Size && e.ModTime.Equal(other.ModTime)
+}
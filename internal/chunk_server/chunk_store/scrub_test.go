@@ -0,0 +1,180 @@
+package chunk_store
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func quarantined(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, quarantineDir, "*"))
+	require.NoError(t, err)
+	return files
+}
+
+// corrupt flips a byte of the first occurrence of old in the file.
+func corrupt(t *testing.T, path string, old string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	i := bytes.Index(data, []byte(old))
+	require.GreaterOrEqual(t, i, 0)
+	data[i] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0644))
+}
+
+func TestFSStore_ScrubFile(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir)
+	_, err := store.Put(testID1, strings.NewReader("intact content"), false)
+	require.NoError(t, err)
+	_, err = store.Put(testID2, strings.NewReader("rotten content"), false)
+	require.NoError(t, err)
+
+	n, err := store.Scrub(testID1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(14), n)
+
+	corrupt(t, store.path(testID2), "rotten")
+	_, err = store.Scrub(testID2)
+	assert.ErrorIs(t, err, ErrChecksum)
+	_, err = store.Stat(testID2)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = os.Stat(store.checksumPath(testID2))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	require.Len(t, quarantined(t, dir), 1)
+	assert.True(t, strings.HasPrefix(filepath.Base(quarantined(t, dir)[0]), testID2.String()+"."))
+
+	// The quarantine directory isn't loaded, and the chunk can be stored again
+	restarted := NewFSStore(dir)
+	chunks, err := restarted.List()
+	require.NoError(t, err)
+	assert.Len(t, chunks, 1)
+	_, err = restarted.Put(testID2, strings.NewReader("repaired"), false)
+	require.NoError(t, err)
+	_, err = restarted.Scrub(testID2)
+	assert.NoError(t, err)
+}
+
+func TestFSStore_ScrubRecordsMissingChecksum(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir)
+	_, err := store.Put(testID1, strings.NewReader("test content"), false)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(store.checksumPath(testID1)))
+
+	_, err = store.Scrub(testID1)
+	require.NoError(t, err)
+	_, err = os.Stat(store.checksumPath(testID1))
+	require.NoError(t, err)
+
+	corrupt(t, store.path(testID1), "test")
+	_, err = store.Scrub(testID1)
+	assert.ErrorIs(t, err, ErrChecksum)
+}
+
+func TestFSStore_ScrubVolume(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir, WithVolumes(1<<10, 1<<20))
+	_, err := store.Put(testID1, strings.NewReader("intact content"), false)
+	require.NoError(t, err)
+	_, err = store.Put(testID2, strings.NewReader("rotten content"), false)
+	require.NoError(t, err)
+
+	_, err = store.Scrub(testID1)
+	require.NoError(t, err)
+	corrupt(t, volumeFiles(t, dir)[0], "rotten")
+	_, err = store.Scrub(testID2)
+	assert.ErrorIs(t, err, ErrChecksum)
+	require.Len(t, quarantined(t, dir), 1)
+
+	for _, s := range []ChunkStore{store, NewFSStore(dir, WithVolumes(1<<10, 1<<20))} {
+		_, err = s.Stat(testID2)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Equal(t, "intact content", chunkData(t, s, testID1))
+	}
+}
+
+func TestFSStore_ScrubAndInspectDuringCompaction(t *testing.T) {
+	store := NewFSStore(t.TempDir(), WithVolumes(1<<10, 1<<10))
+	ids := make([]ChunkID, 12)
+	for i := range ids {
+		ids[i] = ChunkID{UUID: testUUID, Index: i}
+		_, err := store.Put(ids[i], strings.NewReader(strings.Repeat(fmt.Sprint(i%10), 250)), false)
+		require.NoError(t, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for round := 0; round < 20; round++ {
+			// Replaced chunks leave dead records, so every round moves the live ones
+			for _, id := range ids[:6] {
+				_, err := store.Put(id, strings.NewReader(strings.Repeat(fmt.Sprint(id.Index%10), 250)), true)
+				assert.NoError(t, err)
+			}
+			_, err := store.Compact(1)
+			assert.NoError(t, err)
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		for _, id := range ids {
+			_, err := store.Scrub(id)
+			require.NoError(t, err)
+			_, err = store.Inspect(id)
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestFSStore_ChecksumFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir, WithVolumes(100, 1<<20))
+	large := strings.Repeat("l", 100)
+	_, err := store.Put(testID1, strings.NewReader(large), false)
+	require.NoError(t, err)
+	_, err = os.Stat(store.checksumPath(testID1))
+	require.NoError(t, err)
+
+	// Moving the chunk into a volume removes the checksum file
+	_, err = store.Put(testID1, strings.NewReader("small"), true)
+	require.NoError(t, err)
+	_, err = os.Stat(store.checksumPath(testID1))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// A checksum file without its chunk is removed on load
+	_, err = store.Put(testID2, strings.NewReader(large), false)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(store.path(testID2)))
+	_, err = NewFSStore(dir, WithVolumes(100, 1<<20)).List()
+	require.NoError(t, err)
+	_, err = os.Stat(store.checksumPath(testID2))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestMultiStore_Scrub(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	store, _ := newTestMultiStore(t, map[string]int64{dir1: 100, dir2: 200}, dir1, dir2)
+	_, err := store.Put(testID1, strings.NewReader("rotten content"), false)
+	require.NoError(t, err)
+
+	corrupt(t, NewFSStore(dir2).path(testID1), "rotten")
+	_, err = store.Scrub(testID1)
+	assert.ErrorIs(t, err, ErrChecksum)
+	// Corruption is a problem of the chunk, not of the disk
+	assert.Equal(t, []DiskState{DiskOnline, DiskOnline}, diskStates(store))
+	_, err = store.Scrub(testID1)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,91 @@
+package chunk_store
+
+import "sort"
+
+// maxBlockSize is the number of IDs a block of sortedIDs holds before it is split in two.
+const maxBlockSize = 1024
+
+// sortedIDs is a set of chunk IDs kept in order, so a page of chunks can be found without sorting all of them.
+// The IDs are split into sorted blocks, which keeps inserts and deletes cheap with millions of chunks.
+// It is not safe for concurrent use.
+type sortedIDs struct {
+	blocks [][]ChunkID
+}
+
+// block returns the index of the first block whose last ID is not before id, or the number of blocks.
+func (s *sortedIDs) block(id ChunkID) int {
+	return sort.Search(len(s.blocks), func(i int) bool {
+		block := s.blocks[i]
+		return !block[len(block)-1].Less(id)
+	})
+}
+
+// add inserts id unless it is in the set already.
+func (s *sortedIDs) add(id ChunkID) {
+	if len(s.blocks) == 0 {
+		s.blocks = [][]ChunkID{{id}}
+		return
+	}
+	b := s.block(id)
+	if b == len(s.blocks) {
+		// After every ID, append to the last block
+		b--
+	}
+	block := s.blocks[b]
+	i := sort.Search(len(block), func(i int) bool { return !block[i].Less(id) })
+	if i < len(block) && block[i] == id {
+		return
+	}
+	block = append(block, ChunkID{})
+	copy(block[i+1:], block[i:])
+	block[i] = id
+	s.blocks[b] = block
+
+	if len(block) > maxBlockSize {
+		half := len(block) / 2
+		second := append([]ChunkID(nil), block[half:]...)
+		s.blocks[b] = block[:half:half]
+		s.blocks = append(s.blocks, nil)
+		copy(s.blocks[b+2:], s.blocks[b+1:])
+		s.blocks[b+1] = second
+	}
+}
+
+// remove deletes id if it is in the set.
+func (s *sortedIDs) remove(id ChunkID) {
+	b := s.block(id)
+	if b == len(s.blocks) {
+		return
+	}
+	block := s.blocks[b]
+	i := sort.Search(len(block), func(i int) bool { return !block[i].Less(id) })
+	if i == len(block) || block[i] != id {
+		return
+	}
+	block = append(block[:i], block[i+1:]...)
+	if len(block) == 0 {
+		s.blocks = append(s.blocks[:b], s.blocks[b+1:]...)
+		return
+	}
+	s.blocks[b] = block
+}
+
+// ascend calls fn with the IDs following after in order, until fn returns false.
+func (s *sortedIDs) ascend(after ChunkID, fn func(id ChunkID) bool) {
+	b := sort.Search(len(s.blocks), func(i int) bool {
+		block := s.blocks[i]
+		return after.Less(block[len(block)-1])
+	})
+	if b == len(s.blocks) {
+		return
+	}
+	first := s.blocks[b]
+	i := sort.Search(len(first), func(i int) bool { return after.Less(first[i]) })
+	for _, block := range append([][]ChunkID{first[i:]}, s.blocks[b+1:]...) {
+		for _, id := range block {
+			if !fn(id) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package chunk_store
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortedIDs(t *testing.T) {
+	var s sortedIDs
+	want := make(map[ChunkID]struct{})
+	uuids := []string{"a", "b", "c", "d"}
+	rng := rand.New(rand.NewSource(1))
+	// Enough IDs to split blocks, and enough removals to empty some of them
+	for i := 0; i < 20*maxBlockSize; i++ {
+		id := ChunkID{UUID: uuids[rng.Intn(len(uuids))], Index: rng.Intn(3 * maxBlockSize)}
+		if rng.Intn(3) == 0 {
+			s.remove(id)
+			delete(want, id)
+		} else {
+			s.add(id)
+			want[id] = struct{}{}
+		}
+	}
+
+	var all []ChunkID
+	s.ascend(ChunkID{}, func(id ChunkID) bool {
+		all = append(all, id)
+		return true
+	})
+	assert.Len(t, all, len(want))
+	for i, id := range all {
+		if _, ok := want[id]; !ok {
+			t.Fatalf("unexpected ID %v", id)
+		}
+		if i > 0 && !all[i-1].Less(id) {
+			t.Fatalf("IDs out of order: %v before %v", all[i-1], id)
+		}
+	}
+
+	// Iteration starts after the given ID and stops when asked to
+	after := all[len(all)/2]
+	var page []ChunkID
+	s.ascend(after, func(id ChunkID) bool {
+		page = append(page, id)
+		return len(page) < 3
+	})
+	assert.Equal(t, all[len(all)/2+1:len(all)/2+4], page)
+}
@@ -0,0 +1,169 @@
+// Package chunk_store stores the chunks of a chunk server. Chunks are identified by the UUID of their file
+// and their index in the file.
+package chunk_store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	uuid2 "simple-s3-adventure/pkg/uuid"
+)
+
+var (
+	ErrNotFound     = errors.New("chunk not found")
+	ErrExists       = errors.New("chunk already exists")
+	ErrInvalidRange = errors.New("range is outside of the chunk")
+)
+
+// ChunkID identifies a chunk by the UUID of its file and its index in the file.
+type ChunkID struct {
+	UUID  string
+	Index int
+}
+
+// String returns the ID in the form <uuid>_<index>, which is also the name of the chunk file.
+func (id ChunkID) String() string {
+	return id.UUID + "_" + strconv.Itoa(id.Index)
+}
+
+// ParseChunkID parses the ID in the form returned by String.
+func ParseChunkID(s string) (ChunkID, error) {
+	uuid, index, ok := strings.Cut(s, "_")
+	if !ok {
+		return ChunkID{}, fmt.Errorf("invalid chunk ID %q", s)
+	}
+	return NewChunkID(uuid, index)
+}
+
+// NewChunkID validates the UUID and the index of a chunk.
+func NewChunkID(uuid string, index string) (ChunkID, error) {
+	if err := uuid2.Validate(uuid); err != nil {
+		return ChunkID{}, fmt.Errorf("invalid chunk UUID %q", uuid)
+	}
+	i, err := strconv.Atoi(index)
+	// Only the canonical form is accepted, so every chunk has a single name
+	if err != nil || i < 0 || strconv.Itoa(i) != index {
+		return ChunkID{}, fmt.Errorf("invalid chunk index %q", index)
+	}
+	return ChunkID{UUID: uuid, Index: i}, nil
+}
+
+// Less orders chunk IDs by UUID and then by index.
+func (id ChunkID) Less(other ChunkID) bool {
+	if id.UUID != other.UUID {
+		return id.UUID < other.UUID
+	}
+	return id.Index < other.Index
+}
+
+func sortChunks(chunks []ChunkInfo) {
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].ID.Less(chunks[j].ID)
+	})
+}
+
+// ChunkInfo describes a stored chunk.
+type ChunkInfo struct {
+	ID      ChunkID
+	Size    int64
+	ModTime time.Time
+}
+
+// Chunk is a chunk opened for reading. Ranges are read by seeking.
+type Chunk interface {
+	io.ReadSeekCloser
+	Info() ChunkInfo
+}
+
+// ChunkStore stores chunks. Implementations must be safe for concurrent use.
+type ChunkStore interface {
+	// Put stores the chunk read from r and returns its size. An existing chunk is replaced if overwrite is set,
+	// otherwise ErrExists is returned. Nothing is stored if reading r fails.
+	Put(id ChunkID, r io.Reader, overwrite bool) (int64, error)
+	// Get opens the chunk for reading. The caller must close it.
+	Get(id ChunkID) (Chunk, error)
+	// Delete removes the chunk.
+	Delete(id ChunkID) error
+	// Stat describes the chunk without opening it.
+	Stat(id ChunkID) (ChunkInfo, error)
+	// List describes all stored chunks, ordered by ID.
+	List() ([]ChunkInfo, error)
+}
+
+// Pager is implemented by stores that can list a page of their chunks without listing all of them.
+type Pager interface {
+	// ListPage describes up to limit chunks following after whose UUID starts with prefix, ordered by ID, and
+	// tells whether more follow. A zero limit lists all of them.
+	ListPage(after ChunkID, prefix string, limit int) ([]ChunkInfo, bool, error)
+}
+
+// ListPage lists a page of the chunks of the store, see Pager. A store that is not a Pager lists all its chunks.
+func ListPage(store ChunkStore, after ChunkID, prefix string, limit int) ([]ChunkInfo, bool, error) {
+	if pager, ok := store.(Pager); ok {
+		return pager.ListPage(after, prefix, limit)
+	}
+	chunks, err := store.List()
+	if err != nil {
+		return nil, false, err
+	}
+	start := pageStart(after, prefix)
+	first := sort.Search(len(chunks), func(i int) bool {
+		return start.Less(chunks[i].ID)
+	})
+	end := first
+	for end < len(chunks) && strings.HasPrefix(chunks[end].ID.UUID, prefix) {
+		if limit > 0 && end-first == limit {
+			return chunks[first:end], true, nil
+		}
+		end++
+	}
+	return chunks[first:end], false, nil
+}
+
+// pageStart returns the ID that a page of the chunks following after whose UUID starts with prefix follows.
+func pageStart(after ChunkID, prefix string) ChunkID {
+	if start := (ChunkID{UUID: prefix, Index: -1}); after.Less(start) {
+		return start
+	}
+	return after
+}
+
+// UsageReporter is implemented by stores that count their chunks and bytes as they change, so they can tell
+// them without listing all chunks.
+type UsageReporter interface {
+	// Usage returns the number of stored chunks and their total size.
+	Usage() (int, int64, error)
+}
+
+// GetRange opens length bytes of the chunk starting at offset. A zero length reads up to the end of the chunk.
+// It returns the number of bytes that can be read. The caller must close the reader.
+func GetRange(store ChunkStore, id ChunkID, offset, length int64) (io.ReadCloser, int64, error) {
+	chunk, err := store.Get(id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size := chunk.Info().Size
+	if length == 0 {
+		length = size - offset
+	}
+	if offset < 0 || length < 0 || offset+length > size {
+		chunk.Close()
+		return nil, 0, fmt.Errorf("%w: %d+%d, the chunk has %d bytes", ErrInvalidRange, offset, length, size)
+	}
+	if _, err := chunk.Seek(offset, io.SeekStart); err != nil {
+		chunk.Close()
+		return nil, 0, fmt.Errorf("failed to seek chunk: %w", err)
+	}
+	return &rangeReader{Reader: io.LimitReader(chunk, length), Closer: chunk}, length, nil
+}
+
+type rangeReader struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,250 @@
+package chunk_store
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testUUID = "123e4567-e89b-12d3-a456-426614174000"
+
+// Two chunks of the same file must not overwrite each other
+var (
+	testID1 = ChunkID{UUID: testUUID, Index: 0}
+	testID2 = ChunkID{UUID: testUUID, Index: 1}
+)
+
+// testStores returns every implementation, so they are tested against the same expectations.
+func testStores(t *testing.T) map[string]ChunkStore {
+	return map[string]ChunkStore{
+		"fs":      NewFSStore(t.TempDir()),
+		"volumes": NewFSStore(t.TempDir(), WithVolumes(1<<10, 4<<10)),
+		"memory":  NewMemoryStore(),
+		"multi":   NewMultiStore([]*FSStore{NewFSStore(t.TempDir()), NewFSStore(t.TempDir(), WithVolumes(1<<10, 4<<10))}),
+	}
+}
+
+func readAll(t *testing.T, r io.ReadCloser) string {
+	t.Helper()
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestChunkStore(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			n, err := store.Put(testID1, bytes.NewReader([]byte("test content")), false)
+			require.NoError(t, err)
+			assert.Equal(t, int64(12), n)
+			_, err = store.Put(testID2, bytes.NewReader([]byte("abc")), false)
+			require.NoError(t, err)
+
+			chunk, err := store.Get(testID1)
+			require.NoError(t, err)
+			assert.Equal(t, testID1, chunk.Info().ID)
+			assert.Equal(t, int64(12), chunk.Info().Size)
+			_, err = chunk.Seek(5, io.SeekStart)
+			require.NoError(t, err)
+			assert.Equal(t, "content", readAll(t, chunk))
+
+			info, err := store.Stat(testID2)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), info.Size)
+			assert.False(t, info.ModTime.IsZero())
+
+			chunks, err := store.List()
+			require.NoError(t, err)
+			require.Len(t, chunks, 2)
+			assert.Equal(t, testID1, chunks[0].ID)
+			assert.Equal(t, testID2, chunks[1].ID)
+
+			// Put replaces the chunk only if asked to
+			_, err = store.Put(testID2, bytes.NewReader([]byte("abcdef")), false)
+			assert.ErrorIs(t, err, ErrExists)
+			info, err = store.Stat(testID2)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), info.Size)
+
+			_, err = store.Put(testID2, bytes.NewReader([]byte("abcdef")), true)
+			require.NoError(t, err)
+			info, err = store.Stat(testID2)
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), info.Size)
+
+			require.NoError(t, store.Delete(testID1))
+			_, err = store.Get(testID1)
+			assert.ErrorIs(t, err, ErrNotFound)
+			_, err = store.Stat(testID1)
+			assert.ErrorIs(t, err, ErrNotFound)
+			assert.ErrorIs(t, store.Delete(testID1), ErrNotFound)
+
+			chunks, err = store.List()
+			require.NoError(t, err)
+			assert.Len(t, chunks, 1)
+		})
+	}
+}
+
+func TestChunkStore_FailedPut(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := store.Put(testID1, bytes.NewReader([]byte("old")), false)
+			require.NoError(t, err)
+
+			broken := io.MultiReader(bytes.NewReader([]byte("new")), iotest.ErrReader(errors.New("connection reset")))
+			_, err = store.Put(testID1, broken, true)
+			assert.Error(t, err)
+
+			chunk, err := store.Get(testID1)
+			require.NoError(t, err)
+			assert.Equal(t, "old", readAll(t, chunk))
+
+			_, err = store.Put(testID2, iotest.ErrReader(errors.New("connection reset")), false)
+			assert.Error(t, err)
+			_, err = store.Stat(testID2)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			chunks, err := store.List()
+			require.NoError(t, err)
+			assert.Len(t, chunks, 1)
+		})
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := store.Put(testID1, bytes.NewReader([]byte("test content")), false)
+			require.NoError(t, err)
+
+			r, n, err := GetRange(store, testID1, 5, 4)
+			require.NoError(t, err)
+			assert.Equal(t, int64(4), n)
+			assert.Equal(t, "cont", readAll(t, r))
+
+			r, n, err = GetRange(store, testID1, 5, 0)
+			require.NoError(t, err)
+			assert.Equal(t, int64(7), n)
+			assert.Equal(t, "content", readAll(t, r))
+
+			_, _, err = GetRange(store, testID1, 10, 3)
+			assert.ErrorIs(t, err, ErrInvalidRange)
+			_, _, err = GetRange(store, testID1, 13, 0)
+			assert.ErrorIs(t, err, ErrInvalidRange)
+			_, _, err = GetRange(store, testID2, 0, 0)
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestChunkStore_ListOrder(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, index := range []int{10, 2, 0} {
+				_, err := store.Put(ChunkID{UUID: testUUID, Index: index}, bytes.NewReader(nil), false)
+				require.NoError(t, err)
+			}
+
+			chunks, err := store.List()
+			require.NoError(t, err)
+			require.Len(t, chunks, 3)
+			assert.Equal(t, 0, chunks[0].ID.Index)
+			assert.Equal(t, 2, chunks[1].ID.Index)
+			assert.Equal(t, 10, chunks[2].ID.Index)
+		})
+	}
+}
+
+func TestChunkStore_ListPage(t *testing.T) {
+	const otherUUID = "223e4567-e89b-12d3-a456-426614174000"
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, uuid := range []string{testUUID, otherUUID} {
+				for index := 0; index < 5; index++ {
+					_, err := store.Put(ChunkID{UUID: uuid, Index: index}, bytes.NewReader([]byte("chunk")), false)
+					require.NoError(t, err)
+				}
+			}
+			require.NoError(t, store.Delete(ChunkID{UUID: testUUID, Index: 3}))
+
+			// Page through the chunks of a file
+			var ids []ChunkID
+			after := ChunkID{}
+			for {
+				chunks, more, err := ListPage(store, after, testUUID[:8], 2)
+				require.NoError(t, err)
+				require.LessOrEqual(t, len(chunks), 2)
+				for _, chunk := range chunks {
+					ids = append(ids, chunk.ID)
+					after = chunk.ID
+				}
+				if !more {
+					break
+				}
+			}
+			assert.Equal(t, []ChunkID{{testUUID, 0}, {testUUID, 1}, {testUUID, 2}, {testUUID, 4}}, ids)
+
+			chunks, more, err := ListPage(store, ChunkID{UUID: testUUID, Index: 4}, "", 0)
+			require.NoError(t, err)
+			assert.False(t, more)
+			require.Len(t, chunks, 5)
+			assert.Equal(t, ChunkID{UUID: otherUUID, Index: 0}, chunks[0].ID)
+			assert.Equal(t, int64(5), chunks[0].Size)
+		})
+	}
+}
+
+func TestChunkStore_Usage(t *testing.T) {
+	for name, store := range testStores(t) {
+		reporter, ok := store.(UsageReporter)
+		if !ok {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			_, err := store.Put(testID1, bytes.NewReader(make([]byte, 100)), false)
+			require.NoError(t, err)
+			_, err = store.Put(testID2, bytes.NewReader(make([]byte, 2000)), false)
+			require.NoError(t, err)
+			_, err = store.Put(testID1, bytes.NewReader(make([]byte, 300)), true)
+			require.NoError(t, err)
+			chunks, size, err := reporter.Usage()
+			require.NoError(t, err)
+			assert.Equal(t, 2, chunks)
+			assert.Equal(t, int64(2300), size)
+
+			require.NoError(t, store.Delete(testID2))
+			assert.Error(t, store.Delete(testID2))
+			chunks, size, err = reporter.Usage()
+			require.NoError(t, err)
+			assert.Equal(t, 1, chunks)
+			assert.Equal(t, int64(300), size)
+		})
+	}
+}
+
+func TestParseChunkID(t *testing.T) {
+	id, err := ParseChunkID(testUUID + "_12")
+	require.NoError(t, err)
+	assert.Equal(t, ChunkID{UUID: testUUID, Index: 12}, id)
+	assert.Equal(t, testUUID+"_12", id.String())
+
+	for _, s := range []string{
+		testUUID,
+		testUUID + "_",
+		testUUID + "_-1",
+		testUUID + "_01",
+		testUUID + "_1.123.tmp",
+		"node_id",
+		"invalid_1",
+	} {
+		_, err := ParseChunkID(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,286 @@
+package chunk_store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Volumes pack small chunks into large append-only files, so a small chunk doesn't cost an inode
+// and a seek of its own. A volume starts with volumeMagic, followed by records:
+//
+//	magic    uint32, recordMagic
+//	flags    uint8, flagTombstone marks the deletion of the chunk
+//	name     uint16, length of the chunk ID
+//	modTime  int64, Unix nanoseconds
+//	size     int64, length of the chunk data
+//	the chunk ID and the chunk data
+//	checksum uint32, CRC-32 (IEEE) of everything above
+//
+// Records are never changed. A chunk is replaced by appending a new record, a later record wins.
+// It is deleted by appending a tombstone to the volume of its record, the data of a tombstone is the offset
+// of the deleted record as an int64.
+const (
+	volumeDir         = "volumes"
+	volumeSuffix      = ".vol"
+	volumeMagic       = "S3VOLUM1"
+	recordMagic       = uint32(0x43484e4b)
+	flagTombstone     = byte(1)
+	recordHeaderSize  = 4 + 1 + 2 + 8 + 8
+	recordTrailerSize = 4
+)
+
+// ErrChecksum is returned when a chunk no longer matches its checksum.
+var ErrChecksum = errors.New("checksum mismatch")
+
+// volume is a volume file. Records are written and read with WriteAt and ReadAt, the owner serializes writes.
+type volume struct {
+	id   int
+	path string
+	file *os.File
+	// size is the offset of the next record
+	size int64
+	// live is the size of the records of stored chunks, the rest of the volume is garbage
+	live int64
+}
+
+func volumePath(dir string, id int) string {
+	return filepath.Join(dir, volumeDir, fmt.Sprintf("%08d%s", id, volumeSuffix))
+}
+
+// parseVolumeName returns the ID of the volume file.
+func parseVolumeName(name string) (int, bool) {
+	id, err := strconv.Atoi(strings.TrimSuffix(name, volumeSuffix))
+	return id, err == nil && id >= 0 && strings.HasSuffix(name, volumeSuffix)
+}
+
+func createVolume(dir string, id int) (*volume, error) {
+	if err := os.MkdirAll(filepath.Join(dir, volumeDir), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create volume directory: %w", err)
+	}
+	path := volumePath(dir, id)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create volume: %w", err)
+	}
+	if _, err := f.WriteAt([]byte(volumeMagic), 0); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write volume: %w", err)
+	}
+	return &volume{id: id, path: path, file: f, size: int64(len(volumeMagic))}, nil
+}
+
+func openVolume(dir string, id int) (*volume, error) {
+	path := volumePath(dir, id)
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open volume: %w", err)
+	}
+	magic := make([]byte, len(volumeMagic))
+	if _, err := f.ReadAt(magic, 0); err != nil || string(magic) != volumeMagic {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a volume", path)
+	}
+	return &volume{id: id, path: path, file: f, size: int64(len(volumeMagic))}, nil
+}
+
+type recordHeader struct {
+	flags   byte
+	nameLen int
+	modTime time.Time
+	size    int64
+}
+
+func (h recordHeader) recordSize() int64 {
+	return recordHeaderSize + int64(h.nameLen) + h.size + recordTrailerSize
+}
+
+func recordSize(id ChunkID, size int64) int64 {
+	return recordHeaderSize + int64(len(id.String())) + size + recordTrailerSize
+}
+
+func encodeRecord(id ChunkID, flags byte, modTime time.Time, data []byte) []byte {
+	name := id.String()
+	buf := make([]byte, 0, recordSize(id, int64(len(data))))
+	buf = binary.BigEndian.AppendUint32(buf, recordMagic)
+	buf = append(buf, flags)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(name)))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(modTime.UnixNano()))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(len(data)))
+	buf = append(buf, name...)
+	buf = append(buf, data...)
+	return binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+}
+
+func encodeTombstone(id ChunkID, offset int64) []byte {
+	return encodeRecord(id, flagTombstone, time.Now(), binary.BigEndian.AppendUint64(nil, uint64(offset)))
+}
+
+func decodeRecordHeader(b []byte) (recordHeader, bool) {
+	if binary.BigEndian.Uint32(b) != recordMagic {
+		return recordHeader{}, false
+	}
+	h := recordHeader{
+		flags:   b[4],
+		nameLen: int(binary.BigEndian.Uint16(b[5:])),
+		modTime: time.Unix(0, int64(binary.BigEndian.Uint64(b[7:]))),
+		size:    int64(binary.BigEndian.Uint64(b[15:])),
+	}
+	return h, h.size >= 0
+}
+
+// scan calls fn for every record in the order they were written. A torn record at the end, left by a crash
+// while appending, is cut off, so the next record is appended right after the last complete one.
+func (v *volume) scan(fn func(id ChunkID, h recordHeader, offset int64) error) error {
+	info, err := v.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat volume: %w", err)
+	}
+	end := info.Size()
+
+	offset := int64(len(volumeMagic))
+	header := make([]byte, recordHeaderSize)
+	for offset < end {
+		if _, err := v.file.ReadAt(header, offset); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read volume: %w", err)
+		}
+		h, ok := decodeRecordHeader(header)
+		if !ok || offset+h.recordSize() > end {
+			break
+		}
+		name := make([]byte, h.nameLen)
+		if _, err := v.file.ReadAt(name, offset+recordHeaderSize); err != nil {
+			return fmt.Errorf("failed to read volume: %w", err)
+		}
+		id, err := ParseChunkID(string(name))
+		if err != nil {
+			break
+		}
+		if err := fn(id, h, offset); err != nil {
+			return err
+		}
+		offset += h.recordSize()
+	}
+
+	if offset < end {
+		if err := v.file.Truncate(offset); err != nil {
+			return fmt.Errorf("failed to truncate volume: %w", err)
+		}
+	}
+	v.size = offset
+	return nil
+}
+
+// read returns the data of the record and verifies its checksum.
+func (v *volume) read(offset int64, id ChunkID, size int64) ([]byte, error) {
+	buf, err := v.readRecord(offset, id, size)
+	if err != nil {
+		return nil, err
+	}
+	n := len(buf) - recordTrailerSize
+	if crc32.ChecksumIEEE(buf[:n]) != binary.BigEndian.Uint32(buf[n:]) {
+		return nil, fmt.Errorf("chunk %s in volume %d: %w", id, v.id, ErrChecksum)
+	}
+	return buf[recordHeaderSize+len(id.String()) : n], nil
+}
+
+// readRecord returns the whole record without verifying it.
+func (v *volume) readRecord(offset int64, id ChunkID, size int64) ([]byte, error) {
+	buf := make([]byte, recordSize(id, size))
+	if _, err := v.file.ReadAt(buf, offset); err != nil {
+		return nil, fmt.Errorf("failed to read volume: %w", err)
+	}
+	return buf, nil
+}
+
+// append writes the record at the end of the volume and returns its offset.
+// A failed write is overwritten by the next record.
+func (v *volume) append(record []byte) (int64, error) {
+	offset := v.size
+	if _, err := v.file.WriteAt(record, offset); err != nil {
+		return 0, fmt.Errorf("failed to write volume: %w", err)
+	}
+	v.size += int64(len(record))
+	return offset, nil
+}
+
+// garbage returns the percentage of the volume taken by deleted and replaced chunks and by tombstones.
+func (v *volume) garbage() int64 {
+	return (v.size - int64(len(volumeMagic)) - v.live) * 100 / v.size
+}
+
+// loadVolumes adds the chunks in volumes to the index. Volumes are read in the order they were created,
+// so a later record of a chunk replaces an earlier one.
+func (s *FSStore) loadVolumes() error {
+	entries, err := os.ReadDir(filepath.Join(s.dir, volumeDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read volume directory: %w", err)
+	}
+
+	for _, dirEntry := range entries {
+		id, ok := parseVolumeName(dirEntry.Name())
+		if !ok || !dirEntry.Type().IsRegular() {
+			continue
+		}
+		vol, err := openVolume(s.dir, id)
+		if err != nil {
+			return err
+		}
+		s.volumes[id] = vol
+		s.active = vol
+		s.nextVolume = id + 1
+
+		err = vol.scan(func(chunkID ChunkID, h recordHeader, offset int64) error {
+			old, exists := s.index[chunkID]
+			if h.flags&flagTombstone != 0 {
+				data, err := vol.read(offset, chunkID, h.size)
+				if err != nil {
+					return err
+				}
+				if exists && old.volume == vol && len(data) == 8 && int64(binary.BigEndian.Uint64(data)) == old.offset {
+					vol.live -= recordSize(chunkID, old.Size)
+					s.deleteEntry(chunkID)
+				}
+				return nil
+			}
+			if exists {
+				old.volume.live -= recordSize(chunkID, old.Size)
+			}
+			vol.live += h.recordSize()
+			s.setEntry(entry{ChunkInfo: ChunkInfo{ID: chunkID, Size: h.size, ModTime: h.modTime}, volume: vol, offset: offset})
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to load volume %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// activeVolume returns the volume new records are appended to, starting a new one if the record doesn't fit.
+// The caller must hold the lock.
+func (s *FSStore) activeVolume(recordSize int64) (*volume, error) {
+	if s.active != nil && (s.active.size+recordSize <= s.volumeSize || s.active.size == int64(len(volumeMagic))) {
+		return s.active, nil
+	}
+	vol, err := createVolume(s.dir, s.nextVolume)
+	if err != nil {
+		return nil, err
+	}
+	s.volumes[vol.id] = vol
+	s.active = vol
+	s.nextVolume++
+	return vol, nil
+}
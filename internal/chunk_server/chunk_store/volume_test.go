@@ -0,0 +1,189 @@
+package chunk_store
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func volumeFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, volumeDir, "*"+volumeSuffix))
+	require.NoError(t, err)
+	return files
+}
+
+func chunkData(t *testing.T, store ChunkStore, id ChunkID) string {
+	t.Helper()
+	chunk, err := store.Get(id)
+	require.NoError(t, err)
+	return readAll(t, chunk)
+}
+
+func TestVolumes_SmallAndLargeChunks(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir, WithVolumes(100, 1<<20))
+	small := strings.Repeat("s", 99)
+	large := strings.Repeat("l", 100)
+
+	_, err := store.Put(testID1, strings.NewReader(small), false)
+	require.NoError(t, err)
+	_, err = store.Put(testID2, strings.NewReader(large), false)
+	require.NoError(t, err)
+
+	_, err = os.Stat(store.path(testID1))
+	assert.ErrorIs(t, err, os.ErrNotExist, "small chunks are stored in a volume")
+	_, err = os.Stat(store.path(testID2))
+	assert.NoError(t, err)
+	assert.Len(t, volumeFiles(t, dir), 1)
+
+	_, err = store.Put(testID1, strings.NewReader("x"), false)
+	assert.ErrorIs(t, err, ErrExists)
+
+	// Replacing moves the chunk between its file and the volume
+	_, err = store.Put(testID1, strings.NewReader(large), true)
+	require.NoError(t, err)
+	_, err = store.Put(testID2, strings.NewReader(small), true)
+	require.NoError(t, err)
+	_, err = os.Stat(store.path(testID2))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	for _, s := range []ChunkStore{store, NewFSStore(dir, WithVolumes(100, 1<<20))} {
+		assert.Equal(t, large, chunkData(t, s, testID1))
+		assert.Equal(t, small, chunkData(t, s, testID2))
+		chunks, err := s.List()
+		require.NoError(t, err)
+		require.Len(t, chunks, 2)
+		assert.Equal(t, int64(100), chunks[0].Size)
+		assert.Equal(t, int64(99), chunks[1].Size)
+	}
+}
+
+func TestVolumes_Restart(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir, WithVolumes(1<<10, 1<<20))
+	ids := make([]ChunkID, 5)
+	for i := range ids {
+		ids[i] = ChunkID{UUID: testUUID, Index: i}
+		_, err := store.Put(ids[i], strings.NewReader(fmt.Sprintf("chunk %d", i)), false)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.Delete(ids[1]))
+	_, err := store.Put(ids[2], strings.NewReader("replaced"), true)
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(ids[3]))
+	_, err = store.Put(ids[3], strings.NewReader("put again"), false)
+	require.NoError(t, err)
+
+	restarted := NewFSStore(dir, WithVolumes(1<<10, 1<<20))
+	chunks, err := restarted.List()
+	require.NoError(t, err)
+	require.Len(t, chunks, 4)
+	_, err = restarted.Stat(ids[1])
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, "chunk 0", chunkData(t, restarted, ids[0]))
+	assert.Equal(t, "replaced", chunkData(t, restarted, ids[2]))
+	assert.Equal(t, "put again", chunkData(t, restarted, ids[3]))
+	assert.Equal(t, "chunk 4", chunkData(t, restarted, ids[4]))
+
+	info, err := restarted.Stat(ids[4])
+	require.NoError(t, err)
+	expected, err := store.Stat(ids[4])
+	require.NoError(t, err)
+	assert.True(t, expected.ModTime.Equal(info.ModTime))
+}
+
+func TestVolumes_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir, WithVolumes(1<<10, 1<<10))
+	for i := 0; i < 10; i++ {
+		_, err := store.Put(ChunkID{UUID: testUUID, Index: i}, bytes.NewReader(make([]byte, 250)), false)
+		require.NoError(t, err)
+	}
+	// Three records fit in a volume
+	assert.Len(t, volumeFiles(t, dir), 4)
+}
+
+func TestVolumes_Compact(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir, WithVolumes(1<<10, 1<<10))
+	for i := 0; i < 12; i++ {
+		_, err := store.Put(ChunkID{UUID: testUUID, Index: i}, strings.NewReader(strings.Repeat(fmt.Sprint(i%10), 250)), false)
+		require.NoError(t, err)
+	}
+	require.Len(t, volumeFiles(t, dir), 4)
+	// Keep one chunk of every volume but the active one
+	for _, i := range []int{0, 1, 3, 5, 7, 8} {
+		require.NoError(t, store.Delete(ChunkID{UUID: testUUID, Index: i}))
+	}
+
+	stats, err := store.Compact(90)
+	require.NoError(t, err)
+	assert.Equal(t, CompactionStats{}, stats)
+
+	stats, err = store.Compact(50)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Volumes)
+	assert.Equal(t, 3, stats.Chunks)
+	assert.Positive(t, stats.ReclaimedBytes)
+	// The active volume was full, the copies went to a new one
+	assert.Len(t, volumeFiles(t, dir), 2)
+
+	for _, s := range []ChunkStore{store, NewFSStore(dir, WithVolumes(1<<10, 1<<10))} {
+		chunks, err := s.List()
+		require.NoError(t, err)
+		require.Len(t, chunks, 6)
+		for _, chunk := range chunks {
+			assert.Equal(t, strings.Repeat(fmt.Sprint(chunk.ID.Index%10), 250), chunkData(t, s, chunk.ID))
+		}
+	}
+}
+
+func TestVolumes_TornRecord(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir, WithVolumes(1<<10, 1<<20))
+	_, err := store.Put(testID1, strings.NewReader("complete"), false)
+	require.NoError(t, err)
+
+	// A crash in the middle of appending a record
+	record := encodeRecord(testID2, 0, time.Now(), []byte("torn"))
+	f, err := os.OpenFile(volumeFiles(t, dir)[0], os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write(record[:len(record)-3])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	restarted := NewFSStore(dir, WithVolumes(1<<10, 1<<20))
+	_, err = restarted.Stat(testID2)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = restarted.Put(testID2, strings.NewReader("appended"), false)
+	require.NoError(t, err)
+
+	restarted = NewFSStore(dir, WithVolumes(1<<10, 1<<20))
+	assert.Equal(t, "complete", chunkData(t, restarted, testID1))
+	assert.Equal(t, "appended", chunkData(t, restarted, testID2))
+}
+
+func TestVolumes_Checksum(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFSStore(dir, WithVolumes(1<<10, 1<<20))
+	_, err := store.Put(testID1, strings.NewReader("test content"), false)
+	require.NoError(t, err)
+
+	path := volumeFiles(t, dir)[0]
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	i := bytes.Index(data, []byte("content"))
+	data[i] = 'C'
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	_, err = NewFSStore(dir, WithVolumes(1<<10, 1<<20)).Get(testID1)
+	assert.ErrorIs(t, err, ErrChecksum)
+}
@@ -0,0 +1,116 @@
+// Package metrics defines the Prometheus metrics of the chunk server.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "s3"
+	subsystem = "chunk_server"
+)
+
+var (
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by handler and status code.",
+	}, []string{"handler", "code"})
+
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by handler and status code.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"handler", "code"})
+
+	ReadBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "read_bytes_total",
+		Help:      "Bytes of chunks read from disk.",
+	})
+
+	WrittenBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "written_bytes_total",
+		Help:      "Bytes of chunks written to disk.",
+	})
+
+	DiskErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "disk_errors_total",
+		Help:      "Disk I/O errors by operation.",
+	}, []string{"op"})
+
+	DiskFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "disk_failures_total",
+		Help:      "Data directories taken out of service by the state they moved to.",
+	}, []string{"state"})
+
+	CompactedVolumes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "compacted_volumes_total",
+		Help:      "Volumes removed by compaction.",
+	})
+
+	CompactionReclaimedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "compaction_reclaimed_bytes_total",
+		Help:      "Disk space freed by compaction of volumes.",
+	})
+
+	ScrubbedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "scrubbed_bytes_total",
+		Help:      "Bytes of chunks verified by the scrubber.",
+	})
+
+	CorruptChunks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "corrupt_chunks_total",
+		Help:      "Chunks that didn't match their checksum and were quarantined.",
+	})
+
+	Mode = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "mode",
+		Help:      "Whether the chunk server is in the mode: read_write, read_only or maintenance.",
+	}, []string{"mode"})
+
+	Registered = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "registered",
+		Help:      "Whether the chunk server is registered with the front server.",
+	})
+
+	RegistrationFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "registration_failures_total",
+		Help:      "Failed attempts to register with the front server.",
+	})
+)
+
+// InstrumentHandler counts the requests of the handler and measures their latency by status code.
+func InstrumentHandler(name string, handler http.HandlerFunc) http.Handler {
+	labels := prometheus.Labels{"handler": name}
+	return promhttp.InstrumentHandlerCounter(RequestsTotal.MustCurryWith(labels),
+		promhttp.InstrumentHandlerDuration(RequestDuration.MustCurryWith(labels), handler))
+}
@@ -1,64 +1,265 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	"simple-s3-adventure/internal/chunk_server/metrics"
+)
+
+var (
+	// ErrFileNotFound is returned when the requested chunk is not stored on the server.
+	ErrFileNotFound = errors.New("file not found")
+	// ErrFileExists is returned when the chunk is already stored and overwriting was not requested.
+	ErrFileExists = chunk_store.ErrExists
+	// ErrInvalidRange is returned when the requested range is outside of the chunk.
+	ErrInvalidRange = chunk_store.ErrInvalidRange
 )
 
 type ChunkService struct {
 	Config *ServerConfig
 	Logger *slog.Logger
+	Store  chunk_store.ChunkStore
+
+	nodeID       string
+	mode         atomic.Pointer[Mode]
+	modeMu       sync.Mutex
+	onModeChange func(Mode)
+	stopping     atomic.Bool
 }
 
-func NewChunkService(config *ServerConfig, logger *slog.Logger) *ChunkService {
-	return &ChunkService{
+type ChunkServiceOption func(*ChunkService)
+
+// WithStore stores chunks in the given store instead of the upload directory.
+func WithStore(store chunk_store.ChunkStore) ChunkServiceOption {
+	return func(cs *ChunkService) {
+		cs.Store = store
+	}
+}
+
+// WithNodeID sets the node ID the chunk service reports in the handshake with the front server.
+func WithNodeID(nodeID string) ChunkServiceOption {
+	return func(cs *ChunkService) {
+		cs.nodeID = nodeID
+	}
+}
+
+// NewStore returns the store spreading chunks over the data directories, or over the upload directory
+// if none are configured, with the volumes configured by config. Failing directories are logged.
+func NewStore(config *ServerConfig, logger *slog.Logger) *chunk_store.MultiStore {
+	dirs := config.DataDirs
+	if len(dirs) == 0 {
+		dirs = []string{config.UploadDir}
+	}
+	stores := make([]*chunk_store.FSStore, 0, len(dirs))
+	for _, dir := range dirs {
+		stores = append(stores, chunk_store.NewFSStore(dir, chunk_store.WithVolumes(config.SmallChunkSize, config.VolumeSize)))
+	}
+	return chunk_store.NewMultiStore(stores, chunk_store.OnDiskFailure(func(dir string, state chunk_store.DiskState, err error) {
+		metrics.DiskFailures.WithLabelValues(string(state)).Inc()
+		logger.Error("Data directory failed", slog.String("dir", dir), slog.String("state", string(state)), slog.Any("error", err))
+	}))
+}
+
+func NewChunkService(config *ServerConfig, logger *slog.Logger, opts ...ChunkServiceOption) *ChunkService {
+	cs := &ChunkService{
 		Config: config,
 		Logger: logger,
 	}
+	for _, opt := range opts {
+		opt(cs)
+	}
+	if cs.Store == nil {
+		cs.Store = NewStore(config, logger)
+	}
+	mode := ModeReadWrite
+	if config.Mode != "" {
+		mode = config.Mode
+	}
+	cs.mode.Store(&mode)
+	setModeMetric(mode)
+	return cs
 }
 
-func (cs *ChunkService) SaveUploadedFile(r io.Reader, uuid string) error {
-	filePath := filepath.Join(cs.Config.UploadDir, uuid)
-	dst, err := os.Create(filePath)
-	if err != nil {
-		cs.Logger.Error("Failed to create file on server", slog.Any("error", err))
-		return fmt.Errorf("failed to create file on server")
-	}
-	defer dst.Close()
+// NodeID returns the persistent identity of the chunk server, empty if it wasn't set.
+func (cs *ChunkService) NodeID() string {
+	return cs.nodeID
+}
 
-	if _, err := io.Copy(dst, r); err != nil {
+// SaveUploadedFile stores the chunk. An existing chunk is only replaced if overwrite is set.
+// It returns ErrWriteRejected unless the chunk server is in read-write mode.
+func (cs *ChunkService) SaveUploadedFile(r io.Reader, id chunk_store.ChunkID, overwrite bool) error {
+	if err := cs.rejectWrite(Mode.AcceptsPuts); err != nil {
+		return err
+	}
+	n, err := cs.Store.Put(id, r, overwrite)
+	metrics.WrittenBytes.Add(float64(n))
+	if errors.Is(err, chunk_store.ErrExists) {
+		return ErrFileExists
+	} else if err != nil {
+		metrics.DiskErrors.WithLabelValues("write").Inc()
 		cs.Logger.Error("Failed to save uploaded file", slog.Any("error", err))
 		return fmt.Errorf("failed to save uploaded file")
 	}
 
-	cs.Logger.Info("File uploaded", slog.String("uuid", uuid))
+	cs.Logger.Info("File uploaded", slog.String("chunk", id.String()))
 	return nil
 }
 
-func (cs *ChunkService) CopyFileToResponse(uuid string, w http.ResponseWriter) error {
-	exists, err := fileExists(cs.Config.UploadDir, uuid)
+// CopyFileToResponse writes the file to the response. Range requests are supported, so clients can resume
+// interrupted downloads, and Content-Length is always set, so clients can detect truncated responses.
+func (cs *ChunkService) CopyFileToResponse(id chunk_store.ChunkID, w http.ResponseWriter, r *http.Request) error {
+	chunk, err := cs.Store.Get(id)
 	if err != nil {
-		cs.Logger.Error("Failed to check file existence", slog.Any("error", err))
-		return fmt.Errorf("failed to check file existence")
+		return cs.storeError("read", "Failed to open file", err)
 	}
-	if !exists {
-		return fmt.Errorf("file not found")
+	defer chunk.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", chunk.Info().ModTime, &countingReadSeeker{ReadSeeker: chunk})
+	return nil
+}
+
+// OpenRange opens length bytes of the chunk starting at offset, a zero length reads up to the end of the chunk.
+// It returns the number of bytes that can be read. Bytes read from the returned reader are counted as read
+// from disk. The caller must close the reader.
+func (cs *ChunkService) OpenRange(id chunk_store.ChunkID, offset, length int64) (io.ReadCloser, int64, error) {
+	r, size, err := chunk_store.GetRange(cs.Store, id, offset, length)
+	if errors.Is(err, chunk_store.ErrInvalidRange) {
+		return nil, 0, err
+	} else if err != nil {
+		return nil, 0, cs.storeError("read", "Failed to open file", err)
 	}
+	return &countingReadCloser{ReadCloser: r}, size, nil
+}
 
-	f, err := openFile(cs.Config.UploadDir, uuid)
-	if err != nil {
-		cs.Logger.Error("Failed to open file", slog.Any("error", err))
+// DeleteFile deletes the chunk. It returns ErrWriteRejected in maintenance mode.
+func (cs *ChunkService) DeleteFile(id chunk_store.ChunkID) error {
+	if err := cs.rejectWrite(Mode.AcceptsDeletes); err != nil {
 		return err
 	}
-	defer f.Close()
-
-	if _, err := io.Copy(w, f); err != nil {
-		cs.Logger.Error("Failed to copy file", slog.Any("error", err))
-		return fmt.Errorf("failed to copy file")
+	if err := cs.Store.Delete(id); err != nil {
+		return cs.storeError("delete", "Failed to delete file", err)
 	}
 	return nil
 }
+
+// StatFile returns the size of the chunk.
+func (cs *ChunkService) StatFile(id chunk_store.ChunkID) (int64, error) {
+	info, err := cs.Store.Stat(id)
+	if err != nil {
+		return 0, cs.storeError("read", "Failed to stat file", err)
+	}
+	return info.Size, nil
+}
+
+// ListOptions selects a page of the stored chunks.
+type ListOptions struct {
+	// Prefix keeps the chunks whose UUID starts with it
+	Prefix string
+	// After skips the chunks up to and including it, the zero value starts from the first chunk
+	After chunk_store.ChunkID
+	// Limit is the maximal number of chunks, zero means unlimited
+	Limit int
+}
+
+// ListFiles describes a page of the stored chunks, ordered by ID. It also tells whether more chunks follow.
+func (cs *ChunkService) ListFiles(opts ListOptions) ([]chunk_store.ChunkInfo, bool, error) {
+	chunks, more, err := chunk_store.ListPage(cs.Store, opts.After, opts.Prefix, opts.Limit)
+	if err != nil {
+		return nil, false, cs.storeError("read", "Failed to list files", err)
+	}
+	return chunks, more, nil
+}
+
+// InspectFile describes the chunk with its checksum and location, if the store can tell them.
+func (cs *ChunkService) InspectFile(id chunk_store.ChunkID) (chunk_store.ChunkDetails, error) {
+	var (
+		details chunk_store.ChunkDetails
+		err     error
+	)
+	if inspector, ok := cs.Store.(chunk_store.Inspector); ok {
+		details, err = inspector.Inspect(id)
+	} else {
+		details.ChunkInfo, err = cs.Store.Stat(id)
+	}
+	if err != nil {
+		return chunk_store.ChunkDetails{}, cs.storeError("read", "Failed to inspect file", err)
+	}
+	return details, nil
+}
+
+// StorageStats counts the stored chunks and their total size.
+func (cs *ChunkService) StorageStats() (StorageStats, error) {
+	return GetStorageStats(cs.Store)
+}
+
+// Disks describes the data directories of the store. A store without data directories is described
+// as the upload directory.
+func (cs *ChunkService) Disks() []chunk_store.DiskStatus {
+	if reporter, ok := cs.Store.(chunk_store.DiskReporter); ok {
+		return reporter.Disks()
+	}
+	disk := chunk_store.DiskStatus{Dir: cs.Config.UploadDir, State: chunk_store.DiskOnline}
+	if stats, err := cs.StorageStats(); err == nil {
+		disk.Chunks, disk.Bytes = stats.Chunks, stats.Bytes
+	}
+	disk.FreeBytes, disk.TotalBytes, _ = chunk_store.DiskSpace(cs.Config.UploadDir)
+	return []chunk_store.DiskStatus{disk}
+}
+
+// FreeBytes returns the space available for new chunks, on the data directories that are online.
+func FreeBytes(disks []chunk_store.DiskStatus) int64 {
+	var free int64
+	for _, disk := range disks {
+		if disk.State == chunk_store.DiskOnline {
+			free += disk.FreeBytes
+		}
+	}
+	return free
+}
+
+// storeError converts an error of the store to ErrFileNotFound, or counts and logs it.
+func (cs *ChunkService) storeError(operation string, message string, err error) error {
+	if errors.Is(err, chunk_store.ErrNotFound) {
+		return ErrFileNotFound
+	}
+	metrics.DiskErrors.WithLabelValues(operation).Inc()
+	cs.Logger.Error(message, slog.Any("error", err))
+	return err
+}
+
+// countingReadSeeker counts bytes read from disk.
+type countingReadSeeker struct {
+	io.ReadSeeker
+}
+
+func (c *countingReadSeeker) Read(p []byte) (int, error) {
+	n, err := c.ReadSeeker.Read(p)
+	countRead(n, err)
+	return n, err
+}
+
+// countingReadCloser counts bytes read from disk.
+type countingReadCloser struct {
+	io.ReadCloser
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	countRead(n, err)
+	return n, err
+}
+
+func countRead(n int, err error) {
+	metrics.ReadBytes.Add(float64(n))
+	if err != nil && err != io.EOF {
+		metrics.DiskErrors.WithLabelValues("read").Inc()
+	}
+}
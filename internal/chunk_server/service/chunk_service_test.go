@@ -2,16 +2,22 @@ package service
 
 import (
 	"bytes"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+var testID = chunk_store.ChunkID{UUID: "123e4567-e89b-12d3-a456-426614174000", Index: 0}
+
 func TestSaveUploadedFile(t *testing.T) {
 	tempDir := t.TempDir()
 	config := &ServerConfig{UploadDir: tempDir}
@@ -21,13 +27,15 @@ func TestSaveUploadedFile(t *testing.T) {
 
 	fileContent := []byte("test content")
 	fileReader := bytes.NewReader(fileContent)
-	uuid := "test-uuid"
+	id := chunk_store.ChunkID{UUID: testID.UUID, Index: 1}
 
-	err := cs.SaveUploadedFile(fileReader, uuid)
+	err := cs.SaveUploadedFile(fileReader, id, false)
 	require.NoError(t, err)
 
-	savedFilePath := filepath.Join(tempDir, uuid)
-	savedFileContent, err := os.ReadFile(savedFilePath)
+	savedFilePaths, err := filepath.Glob(filepath.Join(tempDir, "*", "*", id.String()))
+	require.NoError(t, err)
+	require.Len(t, savedFilePaths, 1)
+	savedFileContent, err := os.ReadFile(savedFilePaths[0])
 	require.NoError(t, err)
 	assert.Equal(t, fileContent, savedFileContent)
 }
@@ -40,15 +48,38 @@ func TestCopyFileToResponse(t *testing.T) {
 	cs := NewChunkService(config, logger)
 
 	fileContent := []byte("test content")
-	filePath := filepath.Join(tempDir, "test-uuid")
-	require.NoError(t, os.WriteFile(filePath, fileContent, 0644))
+	_, err := cs.Store.Put(testID, bytes.NewReader(fileContent), false)
+	require.NoError(t, err)
 
 	w := &fakeResponseWriter{
 		header: http.Header{},
 	}
-	err := cs.CopyFileToResponse("test-uuid", w)
+	err = cs.CopyFileToResponse(testID, w, httptest.NewRequest(http.MethodGet, "/get?uuid=test-uuid", nil))
 	require.NoError(t, err)
 	assert.Equal(t, fileContent, w.body.Bytes())
+	assert.Equal(t, "12", w.header.Get("Content-Length"))
+}
+
+func TestCopyFileToResponse_Range(t *testing.T) {
+	tempDir := t.TempDir()
+	config := &ServerConfig{UploadDir: tempDir}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	cs := NewChunkService(config, logger)
+	_, err := cs.Store.Put(testID, bytes.NewReader([]byte("test content")), false)
+	require.NoError(t, err)
+
+	w := &fakeResponseWriter{
+		header: http.Header{},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/get?uuid=test-uuid", nil)
+	r.Header.Set("Range", "bytes=5-")
+
+	err = cs.CopyFileToResponse(testID, w, r)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPartialContent, w.status)
+	assert.Equal(t, "content", w.body.String())
+	assert.Equal(t, "7", w.header.Get("Content-Length"))
 }
 
 func TestCopyFileToResponse_FileNotFound(t *testing.T) {
@@ -61,7 +92,7 @@ func TestCopyFileToResponse_FileNotFound(t *testing.T) {
 		header: http.Header{},
 	}
 
-	err := cs.CopyFileToResponse("non-existent-uuid", w)
+	err := cs.CopyFileToResponse(chunk_store.ChunkID{UUID: "non-existent-uuid"}, w, httptest.NewRequest(http.MethodGet, "/get?uuid=non-existent-uuid", nil))
 	require.Error(t, err)
 	assert.Equal(t, "file not found", err.Error())
 }
@@ -76,10 +107,63 @@ func TestCopyFileToResponse_ErrorOpeningFile(t *testing.T) {
 		header: http.Header{},
 	}
 
-	err := cs.CopyFileToResponse("test-uuid", w)
+	err := cs.CopyFileToResponse(testID, w, httptest.NewRequest(http.MethodGet, "/get?uuid=test-uuid", nil))
 	require.Error(t, err)
 }
 
+func TestChunkService_Store(t *testing.T) {
+	config := &ServerConfig{UploadDir: t.TempDir()}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	store := chunk_store.NewMemoryStore()
+
+	cs := NewChunkService(config, logger, WithStore(store))
+	require.NoError(t, cs.SaveUploadedFile(bytes.NewReader([]byte("test content")), testID, false))
+	assert.ErrorIs(t, cs.SaveUploadedFile(bytes.NewReader([]byte("other content")), testID, false), ErrFileExists)
+
+	w := &fakeResponseWriter{
+		header: http.Header{},
+	}
+	require.NoError(t, cs.CopyFileToResponse(testID, w, httptest.NewRequest(http.MethodGet, "/get?uuid=test-uuid", nil)))
+	assert.Equal(t, "test content", w.body.String())
+
+	r, n, err := cs.OpenRange(testID, 5, 0)
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	r.Close()
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), n)
+	assert.Equal(t, "content", string(data))
+
+	_, _, err = cs.OpenRange(testID, 5, 10)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+
+	size, err := cs.StatFile(testID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), size)
+
+	chunks, more, err := cs.ListFiles(ListOptions{Prefix: testID.UUID, Limit: 1})
+	require.NoError(t, err)
+	assert.False(t, more)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, testID, chunks[0].ID)
+
+	// The memory store has no checksums and no location
+	details, err := cs.InspectFile(testID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), details.Size)
+	assert.False(t, details.HasChecksum)
+
+	require.NoError(t, cs.DeleteFile(testID))
+	assert.ErrorIs(t, cs.DeleteFile(testID), ErrFileNotFound)
+	_, err = cs.StatFile(testID)
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	// Nothing has been written to the upload directory
+	entries, err := os.ReadDir(config.UploadDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
 // fakeResponseWriter is a simple fake implementation of http.ResponseWriter for testing
 type fakeResponseWriter struct {
 	header http.Header
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	"simple-s3-adventure/internal/chunk_server/metrics"
+)
+
+// RunCompaction compacts the volumes of the store every interval until the context is done.
+// Volumes in which deleted chunks take at least minGarbage percent of the space are rewritten.
+// A compaction is skipped while paused returns true.
+func RunCompaction(ctx context.Context, store *chunk_store.MultiStore, interval time.Duration, minGarbage int, paused func() bool, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if paused() {
+			continue
+		}
+
+		start := time.Now()
+		stats, err := store.Compact(minGarbage)
+		metrics.CompactedVolumes.Add(float64(stats.Volumes))
+		metrics.CompactionReclaimedBytes.Add(float64(stats.ReclaimedBytes))
+		if err != nil {
+			metrics.DiskErrors.WithLabelValues("compact").Inc()
+			logger.Error("Failed to compact volumes", slog.Any("error", err))
+			continue
+		}
+		if stats.Volumes > 0 {
+			logger.Info("Compacted volumes",
+				slog.Int("volumes", stats.Volumes),
+				slog.Int("chunks", stats.Chunks),
+				slog.Int64("reclaimed_bytes", stats.ReclaimedBytes),
+				slog.Duration("duration", time.Since(start)))
+		}
+	}
+}
@@ -7,28 +7,93 @@ import (
 	"simple-s3-adventure/pkg/config"
 	"simple-s3-adventure/pkg/logger"
 	"strconv"
+	"time"
+)
+
+const (
+	defaultSmallChunkSize     = 64 << 10
+	defaultVolumeSize         = 1 << 30
+	defaultCompactionInterval = time.Hour
+	defaultCompactionGarbage  = 50
+	defaultScrubInterval      = 24 * time.Hour
+	defaultScrubBandwidth     = 10 << 20 // 10 MB/s
+	defaultShutdownTimeout    = 30 * time.Second
 )
 
 type ServerConfig struct {
-	Port               string
-	UploadDir          string
+	Port string
+	// UploadDir holds the node ID, and the chunks if DataDirs is empty
+	UploadDir string
+	// DataDirs are the directories chunks are spread over, usually one per disk
+	DataDirs           []string
 	FrontServerAddress string
 	MaxUploadSize      int64
+	// GRPCPort is the port of the gRPC chunk API, the API is disabled if it is empty
+	GRPCPort string
+	// SmallChunkSize is the size below which chunks are packed into volumes, zero disables volumes
+	SmallChunkSize int64
+	// VolumeSize is the size at which a new volume is started
+	VolumeSize int64
+	// CompactionInterval is the time between compactions of volumes, zero disables compaction
+	CompactionInterval time.Duration
+	// CompactionGarbage is the percentage of deleted data at which a volume is compacted
+	CompactionGarbage int
+	// ScrubInterval is the time between the end of a scrub and the start of the next one, zero disables scrubbing
+	ScrubInterval time.Duration
+	// ScrubBandwidth is the maximum number of bytes per second read by the scrubber, zero means unlimited
+	ScrubBandwidth int64
+	// Mode is the mode the chunk server starts in, read-write if it is empty
+	Mode Mode
+	// ShutdownTimeout is how long requests in flight are waited for when the chunk server is stopped
+	ShutdownTimeout time.Duration
+	// ClusterToken authenticates the chunk server to the front server, it must match the token of the front server
+	ClusterToken string
+	// InsecureRegistration lets the chunk server start without a cluster token
+	InsecureRegistration bool
 }
 
 func NewServerConfig() *ServerConfig {
+	// DATA_DIRS replaces UPLOAD_DIR, the node ID is kept in the first data directory
+	dataDirs := config.GetEnvList("DATA_DIRS", []string{config.GetEnvString("UPLOAD_DIR", "tmp")})
 	cfg := &ServerConfig{
 		Port:               config.GetEnvString("PORT", "12090"),
-		UploadDir:          config.GetEnvString("UPLOAD_DIR", "tmp"),
+		UploadDir:          dataDirs[0],
+		DataDirs:           dataDirs,
 		FrontServerAddress: config.GetEnvString("FRONT_SERVER_ADDRESS", "http://front-server:13090"),
 		MaxUploadSize:      config.GetEnvInt64("MAX_UPLOAD_SIZE", 10<<20),
+		GRPCPort:           config.GetEnvString("GRPC_PORT", ""),
+		SmallChunkSize:     config.GetEnvInt64("SMALL_CHUNK_SIZE", defaultSmallChunkSize),
+		VolumeSize:         config.GetEnvInt64("VOLUME_SIZE", defaultVolumeSize),
+		CompactionInterval: time.Duration(config.GetEnvInt("COMPACTION_INTERVAL_SEC", int(defaultCompactionInterval/time.Second))) * time.Second,
+		CompactionGarbage:  config.GetEnvInt("COMPACTION_GARBAGE_PERCENT", defaultCompactionGarbage),
+		ScrubInterval:      time.Duration(config.GetEnvInt("SCRUB_INTERVAL_SEC", int(defaultScrubInterval/time.Second))) * time.Second,
+		ScrubBandwidth:     config.GetEnvInt64("SCRUB_BANDWIDTH", defaultScrubBandwidth),
+		ShutdownTimeout:    time.Duration(config.GetEnvInt("SHUTDOWN_TIMEOUT_SEC", int(defaultShutdownTimeout/time.Second))) * time.Second,
+		ClusterToken:       config.GetEnvString("CLUSTER_TOKEN", ""),
+
+		InsecureRegistration: config.GetEnvString("INSECURE_REGISTRATION", "") == "true",
+	}
+
+	mode, err := ParseMode(config.GetEnvString("SERVER_MODE", string(ModeReadWrite)))
+	if err != nil {
+		lg := logger.GetLogger()
+		lg.Error("Invalid server mode", slog.Any("error", err))
+		os.Exit(1)
 	}
+	cfg.Mode = mode
 
 	if err := validatePort(cfg.Port); err != nil {
 		lg := logger.GetLogger()
 		lg.Error("Invalid port number", slog.String("port", cfg.Port))
 		os.Exit(1)
 	}
+	if cfg.GRPCPort != "" {
+		if err := validatePort(cfg.GRPCPort); err != nil {
+			lg := logger.GetLogger()
+			lg.Error("Invalid gRPC port number", slog.String("port", cfg.GRPCPort))
+			os.Exit(1)
+		}
+	}
 
 	return cfg
 }
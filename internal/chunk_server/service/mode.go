@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"simple-s3-adventure/internal/chunk_server/metrics"
+)
+
+// Mode tells which writes the chunk server accepts. Chunks are served in every mode.
+type Mode string
+
+const (
+	// ModeReadWrite is the normal mode.
+	ModeReadWrite Mode = "read_write"
+	// ModeReadOnly rejects new chunks. Chunks are still deleted, since that only frees space.
+	ModeReadOnly Mode = "read_only"
+	// ModeMaintenance rejects new chunks and deletes, and pauses compaction and scrubbing,
+	// so nothing is written to the data directories, e.g. while a disk is replaced.
+	ModeMaintenance Mode = "maintenance"
+)
+
+// Modes lists the modes from the least to the most restrictive.
+var Modes = []Mode{ModeReadWrite, ModeReadOnly, ModeMaintenance}
+
+// ErrWriteRejected is returned for a write the mode of the chunk server doesn't accept.
+var ErrWriteRejected = errors.New("write rejected")
+
+// ParseMode returns the mode with the given name.
+func ParseMode(s string) (Mode, error) {
+	for _, mode := range Modes {
+		if string(mode) == s {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("invalid mode %q", s)
+}
+
+// AcceptsPuts reports whether new chunks are stored in the mode.
+func (m Mode) AcceptsPuts() bool {
+	return m == ModeReadWrite
+}
+
+// AcceptsDeletes reports whether chunks are deleted in the mode.
+func (m Mode) AcceptsDeletes() bool {
+	return m != ModeMaintenance
+}
+
+// WithModeListener calls fn with the new mode whenever the mode of the chunk server changes. Changes are
+// serialized, so fn must not block.
+func WithModeListener(fn func(Mode)) ChunkServiceOption {
+	return func(cs *ChunkService) {
+		cs.onModeChange = fn
+	}
+}
+
+// Mode returns the current mode of the chunk server.
+func (cs *ChunkService) Mode() Mode {
+	if mode := cs.mode.Load(); mode != nil {
+		return *mode
+	}
+	return ModeReadWrite
+}
+
+// SetMode switches the chunk server to the mode. It returns false if the server already was in that mode.
+// Writes that already passed the mode check are completed.
+func (cs *ChunkService) SetMode(mode Mode) bool {
+	cs.modeMu.Lock()
+	defer cs.modeMu.Unlock()
+
+	old := cs.Mode()
+	if old == mode {
+		return false
+	}
+	cs.mode.Store(&mode)
+	setModeMetric(mode)
+	cs.Logger.Info("Chunk server mode changed", slog.String("from", string(old)), slog.String("to", string(mode)))
+	if cs.onModeChange != nil {
+		cs.onModeChange(mode)
+	}
+	return true
+}
+
+// rejectWrite returns ErrWriteRejected if the chunk server is shutting down or the current mode doesn't
+// allow the write.
+func (cs *ChunkService) rejectWrite(allowed func(Mode) bool) error {
+	if cs.Stopping() {
+		return fmt.Errorf("%w: %w", ErrWriteRejected, ErrShuttingDown)
+	}
+	if mode := cs.Mode(); !allowed(mode) {
+		return fmt.Errorf("%w: chunk server is in %s mode", ErrWriteRejected, mode)
+	}
+	return nil
+}
+
+func setModeMetric(mode Mode) {
+	for _, m := range Modes {
+		value := 0.0
+		if m == mode {
+			value = 1
+		}
+		metrics.Mode.WithLabelValues(string(m)).Set(value)
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"testing"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range Modes {
+		parsed, err := ParseMode(string(mode))
+		require.NoError(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+	_, err := ParseMode("offline")
+	assert.Error(t, err)
+}
+
+func TestChunkService_Mode(t *testing.T) {
+	config := &ServerConfig{UploadDir: t.TempDir(), Mode: ModeReadOnly}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	var changes []Mode
+	cs := NewChunkService(config, logger, WithStore(chunk_store.NewMemoryStore()), WithModeListener(func(mode Mode) {
+		changes = append(changes, mode)
+	}))
+	other := chunk_store.ChunkID{UUID: testID.UUID, Index: 1}
+
+	// The configured mode is not a change
+	assert.Equal(t, ModeReadOnly, cs.Mode())
+	assert.ErrorIs(t, cs.SaveUploadedFile(bytes.NewReader([]byte("test content")), testID, false), ErrWriteRejected)
+
+	require.True(t, cs.SetMode(ModeReadWrite))
+	require.NoError(t, cs.SaveUploadedFile(bytes.NewReader([]byte("test content")), testID, false))
+	require.NoError(t, cs.SaveUploadedFile(bytes.NewReader([]byte("other content")), other, false))
+
+	// Read-only still deletes
+	require.True(t, cs.SetMode(ModeReadOnly))
+	assert.False(t, cs.SetMode(ModeReadOnly))
+	require.NoError(t, cs.DeleteFile(other))
+
+	// Maintenance rejects all writes, but serves chunks
+	require.True(t, cs.SetMode(ModeMaintenance))
+	assert.ErrorIs(t, cs.SaveUploadedFile(bytes.NewReader([]byte("other content")), other, false), ErrWriteRejected)
+	assert.ErrorIs(t, cs.DeleteFile(testID), ErrWriteRejected)
+	size, err := cs.StatFile(testID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), size)
+
+	assert.Equal(t, []Mode{ModeReadWrite, ModeReadOnly, ModeMaintenance}, changes)
+}
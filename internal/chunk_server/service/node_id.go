@@ -0,0 +1,49 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	uuid2 "simple-s3-adventure/pkg/uuid"
+)
+
+// nodeIDFile is the name of the file in the upload directory that stores the node ID.
+const nodeIDFile = "node_id"
+
+// LoadOrCreateNodeID returns the persistent ID of the chunk server stored in the upload directory.
+// The ID is generated on the first start. It identifies the node independently of its hostname and port,
+// so the front server recognizes the node and its data when it comes back at a new address.
+func LoadOrCreateNodeID(uploadDir string) (string, error) {
+	if err := CreateUploadDir(uploadDir); err != nil {
+		return "", err
+	}
+
+	filePath := filepath.Join(uploadDir, nodeIDFile)
+	data, err := os.ReadFile(filePath)
+	if err == nil {
+		id := strings.TrimSpace(string(data))
+		if err := uuid2.Validate(id); err != nil {
+			return "", fmt.Errorf("invalid node ID in %s", filePath)
+		}
+		return id, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read node ID: %w", err)
+	}
+
+	id := uuid.New().String()
+	// Write to a temporary file first, so a crash never leaves a partially written ID
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(id+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to write node ID: %w", err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return "", fmt.Errorf("failed to write node ID: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	uuid2 "simple-s3-adventure/pkg/uuid"
+)
+
+func TestLoadOrCreateNodeID(t *testing.T) {
+	uploadDir := filepath.Join(t.TempDir(), "uploads")
+
+	id, err := LoadOrCreateNodeID(uploadDir)
+	require.NoError(t, err)
+	assert.NoError(t, uuid2.Validate(id))
+
+	// The ID survives restarts
+	again, err := LoadOrCreateNodeID(uploadDir)
+	require.NoError(t, err)
+	assert.Equal(t, id, again)
+
+	_, err = os.Stat(filepath.Join(uploadDir, nodeIDFile+".tmp"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLoadOrCreateNodeID_Invalid(t *testing.T) {
+	uploadDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(uploadDir, nodeIDFile), []byte("garbage"), 0644))
+
+	_, err := LoadOrCreateNodeID(uploadDir)
+	assert.Error(t, err)
+}
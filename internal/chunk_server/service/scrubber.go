@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+	"simple-s3-adventure/internal/chunk_server/metrics"
+)
+
+// ScrubStats describes a pass of the scrubber over all chunks.
+type ScrubStats struct {
+	// Chunks is the number of verified chunks
+	Chunks int
+	// Bytes is the number of bytes read
+	Bytes int64
+	// Corrupted is the number of chunks that didn't match their checksum
+	Corrupted int
+	// Unreported are the corrupted chunks that couldn't be reported to the front server
+	Unreported []chunk_store.ChunkID
+}
+
+// ErrUnknownChunk is returned by a ReportFunc when the front server doesn't know the reported chunk, e.g. because
+// its file was deleted. Reporting it again can't succeed, so the report is dropped.
+var ErrUnknownChunk = errors.New("chunk is unknown to the front server")
+
+// ReportFunc tells the front server that a corrupted chunk was quarantined.
+type ReportFunc func(ctx context.Context, id chunk_store.ChunkID) error
+
+// RunScrubber verifies all chunks of the store, waits interval and starts over, until the context is done.
+// The first pass starts right away, so a server that is restarted more often than interval is still scrubbed.
+// A pass is skipped while paused returns true. Corrupted chunks that couldn't be reported are reported again
+// before every pass, as they are no longer in the store to be found by the next one.
+func RunScrubber(ctx context.Context, store *chunk_store.MultiStore, interval time.Duration, bandwidth int64, report ReportFunc, paused func() bool, logger *slog.Logger) {
+	var unreported []chunk_store.ChunkID
+	for {
+		unreported = reportAgain(ctx, unreported, report, logger)
+		if paused() {
+			logger.Info("Skipped scrub while paused")
+		} else {
+			start := time.Now()
+			stats, err := Scrub(ctx, store, bandwidth, report, logger)
+			unreported = append(unreported, stats.Unreported...)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				logger.Error("Failed to scrub chunks", slog.Any("error", err))
+			} else {
+				logger.Info("Scrubbed chunks",
+					slog.Int("chunks", stats.Chunks),
+					slog.Int64("bytes", stats.Bytes),
+					slog.Int("corrupted", stats.Corrupted),
+					slog.Duration("duration", time.Since(start)))
+			}
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Scrub verifies every chunk of the store once, reading at most bandwidth bytes per second, zero means
+// unlimited. Corrupted chunks are quarantined by the store and passed to report, so the front server can copy
+// them again from another replica. Until then, the chunk server answers that the chunk is not found.
+func Scrub(ctx context.Context, store *chunk_store.MultiStore, bandwidth int64, report ReportFunc, logger *slog.Logger) (ScrubStats, error) {
+	chunks, err := store.List()
+	if err != nil {
+		return ScrubStats{}, err
+	}
+
+	var stats ScrubStats
+	start := time.Now()
+	for _, chunk := range chunks {
+		n, err := store.Scrub(chunk.ID)
+		stats.Bytes += n
+		metrics.ScrubbedBytes.Add(float64(n))
+		switch {
+		case errors.Is(err, chunk_store.ErrNotFound):
+			// Deleted since the chunks were listed
+			continue
+		case errors.Is(err, chunk_store.ErrChecksum):
+			stats.Corrupted++
+			metrics.CorruptChunks.Inc()
+			logger.Error("Quarantined corrupted chunk", slog.String("chunk", chunk.ID.String()), slog.Any("error", err))
+			if !reportCorrupted(ctx, chunk.ID, report, logger) {
+				stats.Unreported = append(stats.Unreported, chunk.ID)
+			}
+		case err != nil:
+			metrics.DiskErrors.WithLabelValues("scrub").Inc()
+			logger.Error("Failed to scrub chunk", slog.String("chunk", chunk.ID.String()), slog.Any("error", err))
+		}
+		stats.Chunks++
+
+		if err := throttle(ctx, start, stats.Bytes, bandwidth); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// reportAgain reports the chunks that couldn't be reported before and returns those that still can't.
+func reportAgain(ctx context.Context, unreported []chunk_store.ChunkID, report ReportFunc, logger *slog.Logger) []chunk_store.ChunkID {
+	var failed []chunk_store.ChunkID
+	for _, id := range unreported {
+		if !reportCorrupted(ctx, id, report, logger) {
+			failed = append(failed, id)
+			continue
+		}
+		logger.Info("Reported corrupted chunk", slog.String("chunk", id.String()))
+	}
+	return failed
+}
+
+// reportCorrupted reports a corrupted chunk and returns false if it should be reported again later.
+func reportCorrupted(ctx context.Context, id chunk_store.ChunkID, report ReportFunc, logger *slog.Logger) bool {
+	err := report(ctx, id)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrUnknownChunk):
+		logger.Warn("Dropped report of corrupted chunk unknown to the front server", slog.String("chunk", id.String()), slog.Any("error", err))
+		return true
+	default:
+		logger.Error("Failed to report corrupted chunk", slog.String("chunk", id.String()), slog.Any("error", err))
+		return false
+	}
+}
+
+// throttle waits until reading read bytes since start keeps to the bandwidth.
+func throttle(ctx context.Context, start time.Time, read int64, bandwidth int64) error {
+	if bandwidth <= 0 {
+		return ctx.Err()
+	}
+	expected := time.Duration(float64(read) / float64(bandwidth) * float64(time.Second))
+	wait := expected - time.Since(start)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrub(t *testing.T) {
+	config := &ServerConfig{UploadDir: t.TempDir()}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	store := NewStore(config, logger)
+	rotten := chunk_store.ChunkID{UUID: testID.UUID, Index: 1}
+	_, err := store.Put(testID, strings.NewReader(strings.Repeat("a", 50)), false)
+	require.NoError(t, err)
+	_, err = store.Put(rotten, strings.NewReader(strings.Repeat("b", 50)), false)
+	require.NoError(t, err)
+
+	paths, err := filepath.Glob(filepath.Join(config.UploadDir, "*", "*", rotten.String()))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	require.NoError(t, os.WriteFile(paths[0], []byte(strings.Repeat("c", 50)), 0644))
+
+	var reported []chunk_store.ChunkID
+	report := func(_ context.Context, id chunk_store.ChunkID) error {
+		reported = append(reported, id)
+		return nil
+	}
+	start := time.Now()
+	stats, err := Scrub(context.Background(), store, 1000, report, logger)
+	require.NoError(t, err)
+	assert.Equal(t, ScrubStats{Chunks: 2, Bytes: 100, Corrupted: 1}, stats)
+	assert.Equal(t, []chunk_store.ChunkID{rotten}, reported)
+	// 100 bytes at 1000 bytes per second
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	_, err = store.Stat(rotten)
+	assert.ErrorIs(t, err, chunk_store.ErrNotFound)
+}
+
+func TestScrub_Cancelled(t *testing.T) {
+	config := &ServerConfig{UploadDir: t.TempDir()}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	store := NewStore(config, logger)
+	_, err := store.Put(testID, strings.NewReader(strings.Repeat("a", 50)), false)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Scrub(ctx, store, 1, nil, logger)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRunScrubber_ReportsAgain(t *testing.T) {
+	config := &ServerConfig{UploadDir: t.TempDir()}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	store := NewStore(config, logger)
+	_, err := store.Put(testID, strings.NewReader(strings.Repeat("a", 50)), false)
+	require.NoError(t, err)
+	paths, err := filepath.Glob(filepath.Join(config.UploadDir, "*", "*", testID.String()))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	require.NoError(t, os.WriteFile(paths[0], []byte(strings.Repeat("c", 50)), 0644))
+
+	// The front server is down during the first pass
+	reported := make(chan chunk_store.ChunkID, 3)
+	var attempts int
+	report := func(_ context.Context, id chunk_store.ChunkID) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("front server is down")
+		}
+		reported <- id
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunScrubber(ctx, store, 10*time.Millisecond, 0, report, func() bool { return false }, logger)
+	}()
+
+	select {
+	case id := <-reported:
+		assert.Equal(t, testID, id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the corrupted chunk was not reported again")
+	}
+	cancel()
+	<-done
+	assert.Empty(t, reported, "a reported chunk is not reported again")
+}
+
+func TestRunScrubber_DropsUnknownChunk(t *testing.T) {
+	config := &ServerConfig{UploadDir: t.TempDir()}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	store := NewStore(config, logger)
+	_, err := store.Put(testID, strings.NewReader(strings.Repeat("a", 50)), false)
+	require.NoError(t, err)
+	paths, err := filepath.Glob(filepath.Join(config.UploadDir, "*", "*", testID.String()))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	require.NoError(t, os.WriteFile(paths[0], []byte(strings.Repeat("c", 50)), 0644))
+
+	// The file of the chunk was deleted, the front server answers 404
+	var attempts int
+	report := func(_ context.Context, id chunk_store.ChunkID) error {
+		attempts++
+		return fmt.Errorf("%w: received HTTP status: 404", ErrUnknownChunk)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var passes int
+	paused := func() bool {
+		if passes++; passes == 3 {
+			cancel()
+		}
+		return false
+	}
+	RunScrubber(ctx, store, time.Millisecond, 0, report, paused, logger)
+
+	assert.GreaterOrEqual(t, passes, 3)
+	assert.Equal(t, 1, attempts, "a chunk unknown to the front server is not reported again")
+}
@@ -0,0 +1,24 @@
+package service
+
+import "errors"
+
+// ErrShuttingDown is wrapped by ErrWriteRejected once the chunk server stopped accepting writes.
+var ErrShuttingDown = errors.New("chunk server is shutting down")
+
+// StopWrites makes the chunk service reject new chunks and deletes, whatever its mode, so the chunk server
+// can finish the writes in flight and shut down. Reads are still served.
+func (cs *ChunkService) StopWrites() {
+	if !cs.stopping.Swap(true) {
+		cs.Logger.Info("Stopped accepting writes")
+	}
+}
+
+// Stopping reports whether StopWrites was called.
+func (cs *ChunkService) Stopping() bool {
+	return cs.stopping.Load()
+}
+
+// AcceptsPuts reports whether a new chunk would be stored now.
+func (cs *ChunkService) AcceptsPuts() bool {
+	return !cs.Stopping() && cs.Mode().AcceptsPuts()
+}
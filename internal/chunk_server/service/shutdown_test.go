@@ -0,0 +1,41 @@
+package service
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"testing"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkService_StopWrites(t *testing.T) {
+	config := &ServerConfig{UploadDir: t.TempDir()}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	cs := NewChunkService(config, logger, WithStore(chunk_store.NewMemoryStore()))
+	other := chunk_store.ChunkID{UUID: testID.UUID, Index: 1}
+
+	require.NoError(t, cs.SaveUploadedFile(bytes.NewReader([]byte("test content")), testID, false))
+	assert.True(t, cs.AcceptsPuts())
+
+	cs.StopWrites()
+	assert.True(t, cs.Stopping())
+	assert.False(t, cs.AcceptsPuts())
+
+	err := cs.SaveUploadedFile(bytes.NewReader([]byte("other content")), other, false)
+	assert.ErrorIs(t, err, ErrWriteRejected)
+	assert.ErrorIs(t, err, ErrShuttingDown)
+	assert.ErrorIs(t, cs.DeleteFile(testID), ErrShuttingDown)
+
+	// Switching the mode doesn't accept writes again
+	require.True(t, cs.SetMode(ModeReadOnly))
+	require.True(t, cs.SetMode(ModeReadWrite))
+	assert.False(t, cs.AcceptsPuts())
+
+	size, err := cs.StatFile(testID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), size)
+}
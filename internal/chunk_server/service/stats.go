@@ -0,0 +1,32 @@
+package service
+
+import (
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+)
+
+// StorageStats describes the stored chunks.
+type StorageStats struct {
+	Chunks int
+	Bytes  int64
+}
+
+// GetStorageStats counts the chunks in the store and their total size. Stores that count them as they change
+// aren't listed.
+func GetStorageStats(store chunk_store.ChunkStore) (StorageStats, error) {
+	if reporter, ok := store.(chunk_store.UsageReporter); ok {
+		chunks, bytes, err := reporter.Usage()
+		return StorageStats{Chunks: chunks, Bytes: bytes}, err
+	}
+
+	chunks, err := store.List()
+	if err != nil {
+		return StorageStats{}, err
+	}
+
+	var stats StorageStats
+	for _, chunk := range chunks {
+		stats.Chunks++
+		stats.Bytes += chunk.Size
+	}
+	return stats, nil
+}
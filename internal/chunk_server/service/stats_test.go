@@ -0,0 +1,27 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"simple-s3-adventure/internal/chunk_server/chunk_store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStorageStats(t *testing.T) {
+	uploadDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(uploadDir, "6f1b2c1e-8a4b-4c1e-9b8a-1f2e3d4c5b6a_0"), []byte("12345"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(uploadDir, "0d9c8b7a-6f5e-4d3c-2b1a-0f9e8d7c6b5a_1"), []byte("123"), 0644))
+	_, err := LoadOrCreateNodeID(uploadDir)
+	require.NoError(t, err)
+
+	stats, err := GetStorageStats(chunk_store.NewFSStore(uploadDir))
+	require.NoError(t, err)
+	assert.Equal(t, StorageStats{Chunks: 2, Bytes: 8}, stats)
+
+	_, err = GetStorageStats(chunk_store.NewFSStore(filepath.Join(uploadDir, nodeIDFile)))
+	assert.Error(t, err)
+}
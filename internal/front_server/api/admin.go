@@ -0,0 +1,208 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/rebalance_service"
+	"simple-s3-adventure/internal/front_server/registry_service"
+	"simple-s3-adventure/pkg/config"
+	"simple-s3-adventure/pkg/logger"
+)
+
+const (
+	defaultRebalanceInterval    = 30 * time.Second
+	defaultRebalanceBandwidth   = 10 << 20 // 10 MB/s
+	defaultRebalanceThreshold   = 64 << 20 // 64 MB
+	defaultRebalanceDeleteDelay = time.Minute
+	// The allocation map is only kept in memory, so after a restart every chunk would look orphaned
+	defaultGCInterval    = 0
+	defaultGCGracePeriod = 24 * time.Hour
+)
+
+func rebalancerConfig() rebalance_service.Config {
+	return rebalance_service.Config{
+		Interval:       time.Duration(config.GetEnvInt("REBALANCE_INTERVAL_SEC", int(defaultRebalanceInterval/time.Second))) * time.Second,
+		BandwidthLimit: config.GetEnvInt64("REBALANCE_BANDWIDTH", defaultRebalanceBandwidth),
+		Threshold:      config.GetEnvInt64("REBALANCE_THRESHOLD", defaultRebalanceThreshold),
+		DeleteDelay:    time.Duration(config.GetEnvInt("REBALANCE_DELETE_DELAY_SEC", int(defaultRebalanceDeleteDelay/time.Second))) * time.Second,
+		GCInterval:     time.Duration(config.GetEnvInt("GC_INTERVAL_SEC", int(defaultGCInterval/time.Second))) * time.Second,
+		GCGracePeriod:  time.Duration(config.GetEnvInt("GC_GRACE_PERIOD_SEC", int(defaultGCGracePeriod/time.Second))) * time.Second,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		httpError(w, "Failed to encode response", http.StatusInternalServerError, err)
+	}
+}
+
+// RebalanceStatusHandler returns the state of the rebalancer.
+func (f *FrontServer) RebalanceStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, f.rebalancer.Status())
+}
+
+// RebalancePauseHandler pauses the rebalancer. The migration in progress is completed.
+func (f *FrontServer) RebalancePauseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	f.rebalancer.Pause()
+	writeJSON(w, f.rebalancer.Status())
+}
+
+// RebalanceResumeHandler resumes the rebalancer.
+func (f *FrontServer) RebalanceResumeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	f.rebalancer.Resume()
+	writeJSON(w, f.rebalancer.Status())
+}
+
+func chunkServerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, registry_service.ErrChunkServerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, registry_service.ErrChunkServerNotRemovable):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ChunkServersHandler lists registered chunk servers (GET) or removes a drained chunk server (DELETE ?url=...).
+func (f *FrontServer) ChunkServersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, f.service.ListChunkServers())
+	case http.MethodDelete:
+		if err := f.rebalancer.RemoveChunkServer(r.FormValue("url")); err != nil {
+			http.Error(w, err.Error(), chunkServerErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// DrainHandler starts draining a chunk server (PUT), reports the progress (GET) or cancels draining (DELETE).
+// The chunk server is identified by the "url" parameter.
+func (f *FrontServer) DrainHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		status rebalance_service.DrainStatus
+		err    error
+	)
+
+	url := r.FormValue("url")
+	switch r.Method {
+	case http.MethodGet:
+		status, err = f.rebalancer.DrainStatus(url)
+	case http.MethodPut:
+		status, err = f.rebalancer.Drain(url)
+	case http.MethodDelete:
+		status, err = f.rebalancer.CancelDrain(url)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), chunkServerErrorStatus(err))
+		return
+	}
+	writeJSON(w, status)
+}
+
+// ObjectHandler returns the chunk layout of the file given by the "uuid" parameter.
+func (f *FrontServer) ObjectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	layout, err := f.service.ObjectLayout(r.URL.Query().Get("uuid"))
+	if errors.Is(err, registry_service.ErrFileNotFound) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		httpError(w, "Failed to get object layout", http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, layout)
+}
+
+// VerifyHandler reads every replica of every chunk of the file given by the "uuid" parameter
+// and reports whether they are intact.
+func (f *FrontServer) VerifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, err := f.rebalancer.Verify(r.Context(), r.URL.Query().Get("uuid"))
+	if errors.Is(err, registry_service.ErrFileNotFound) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		httpError(w, "Failed to verify object", http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, report)
+}
+
+// ScrubHandler starts a scrub (PUT, with repair=true to repair damaged replicas) or reports its status (GET).
+func (f *FrontServer) ScrubHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, f.rebalancer.ScrubStatus())
+	case http.MethodPut:
+		status, err := f.rebalancer.StartScrub(r.FormValue("repair") == "true")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			logger.GetLogger().Error("Failed to encode response", slog.Any("error", err))
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// GCHandler starts a garbage collection of orphan chunks (PUT, with dry_run=true to only report them)
+// or reports its status (GET).
+func (f *FrontServer) GCHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, f.rebalancer.GCStatus())
+	case http.MethodPut:
+		status, err := f.rebalancer.StartGC(r.FormValue("dry_run") == "true")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			logger.GetLogger().Error("Failed to encode response", slog.Any("error", err))
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
@@ -1,13 +1,49 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
 	"net/http"
+	"path"
+	"simple-s3-adventure/internal/front_server/download_service"
+	"simple-s3-adventure/internal/front_server/front_service"
+	"simple-s3-adventure/internal/front_server/metrics"
+	"simple-s3-adventure/pkg/config"
+	"simple-s3-adventure/pkg/logger"
 	uuid2 "simple-s3-adventure/pkg/uuid"
 	"strconv"
+	"time"
 )
 
+const (
+	defaultHedgePercentile = 95
+	defaultHedgeMinDelay   = 10 * time.Millisecond
+	defaultHedgeMaxDelay   = time.Second
+	defaultReadAhead       = 4 << 20 // 4 MB
+)
+
+// downloadOptions configures downloads. Hedged reads are enabled unless HEDGE_PERCENTILE is 0.
+func downloadOptions() []front_service.FrontServiceOption {
+	opts := []front_service.FrontServiceOption{
+		front_service.WithReadAhead(config.GetEnvInt64("DOWNLOAD_READ_AHEAD", defaultReadAhead)),
+	}
+
+	percentile := config.GetEnvInt("HEDGE_PERCENTILE", defaultHedgePercentile)
+	if percentile > 0 {
+		opts = append(opts, front_service.WithHedging(download_service.HedgeConfig{
+			Percentile: float64(percentile),
+			MinDelay:   time.Duration(config.GetEnvInt("HEDGE_MIN_DELAY_MS", int(defaultHedgeMinDelay/time.Millisecond))) * time.Millisecond,
+			MaxDelay:   time.Duration(config.GetEnvInt("HEDGE_MAX_DELAY_MS", int(defaultHedgeMaxDelay/time.Millisecond))) * time.Millisecond,
+		}))
+	}
+	return opts
+}
+
+// GetHandler sends the file (GET) or only its headers (HEAD). A single byte range can be requested with the Range header.
 func (f *FrontServer) GetHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
@@ -18,14 +54,73 @@ func (f *FrontServer) GetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	size, err := f.service.CopyChunks(uuid, w)
+	object, err := f.service.Stat(uuid)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	offset, length, partial, err := parseRange(r.Header.Get("Range"), object.Size)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", object.Size))
+		http.Error(w, "Range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("Content-Disposition", contentDisposition(object.Name))
+	if !object.CreatedAt.IsZero() {
+		header.Set("Last-Modified", object.CreatedAt.Format(http.TimeFormat))
+	}
+	status := http.StatusOK
+	if partial {
+		status = http.StatusPartialContent
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, object.Size))
+	}
+
+	if r.Method == http.MethodHead {
+		if !partial {
+			length = object.Size
+		}
+		header.Set("Content-Length", strconv.FormatInt(length, 10))
+		w.WriteHeader(status)
+		return
+	}
+
+	// All chunk servers must respond before the headers are sent, so errors can still be reported to the client
+	var download *download_service.DownloadService
+	if partial {
+		download, err = f.service.OpenRange(r.Context(), uuid, offset, length)
+	} else {
+		download, err = f.service.OpenDownload(r.Context(), uuid)
+	}
+	if errors.Is(err, front_service.ErrFileNotFound) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Error copying chunks", http.StatusInternalServerError)
+		httpError(w, "Failed to fetch file from chunk servers", http.StatusBadGateway, err)
 		return
 	}
+	defer download.Close()
+
+	header.Set("Content-Length", strconv.FormatInt(download.Size(), 10))
+	w.WriteHeader(status)
 
-	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", "attachment")
-	w.WriteHeader(http.StatusOK)
+	// The response is truncated if streaming fails, the client detects it by Content-Length
+	n, err := download.WriteTo(w)
+	metrics.DownloadedBytes.Add(float64(n))
+	if err != nil {
+		logger.GetLogger().Error("Failed to send file", slog.String("uuid", uuid), slog.Any("error", err))
+	}
+}
+
+// contentDisposition suggests the base name of the file as the name to save it under.
+func contentDisposition(name string) string {
+	if base := path.Base(name); name != "" && base != "/" && base != "." {
+		return mime.FormatMediaType("attachment", map[string]string{"filename": base})
+	}
+	return "attachment"
 }
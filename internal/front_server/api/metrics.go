@@ -0,0 +1,82 @@
+package api
+
+import (
+	"simple-s3-adventure/internal/front_server/front_service"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// clusterCollector exports the state of the cluster known to the front server at scrape time.
+type clusterCollector struct {
+	service *front_service.FrontService
+
+	chunkServers        *prometheus.Desc
+	chunkServerLiveness *prometheus.Desc
+	chunkServerBytes    *prometheus.Desc
+	chunkServerChunks   *prometheus.Desc
+	objects             *prometheus.Desc
+	hedgeRequests       *prometheus.Desc
+	hedgedRequests      *prometheus.Desc
+	hedgeWins           *prometheus.Desc
+}
+
+func newClusterCollector(service *front_service.FrontService) *clusterCollector {
+	return &clusterCollector{
+		service: service,
+		chunkServers: prometheus.NewDesc("s3_front_chunk_servers",
+			"Number of registered chunk servers by state.", []string{"state"}, nil),
+		chunkServerLiveness: prometheus.NewDesc("s3_front_chunk_servers_by_liveness",
+			"Number of registered chunk servers that answer heartbeats (alive) or missed them (offline).", []string{"liveness"}, nil),
+		chunkServerBytes: prometheus.NewDesc("s3_front_chunk_server_stored_bytes",
+			"Bytes stored on a chunk server according to the registry.", []string{"server"}, nil),
+		chunkServerChunks: prometheus.NewDesc("s3_front_chunk_server_chunks",
+			"Number of chunks stored on a chunk server according to the allocation map.", []string{"server"}, nil),
+		objects: prometheus.NewDesc("s3_front_objects",
+			"Number of stored files.", nil, nil),
+		hedgeRequests: prometheus.NewDesc("s3_front_hedge_eligible_requests_total",
+			"Chunk requests to replicated chunks that could be hedged.", nil, nil),
+		hedgedRequests: prometheus.NewDesc("s3_front_hedged_requests_total",
+			"Chunk requests sent to a second replica because the first one was slow.", nil, nil),
+		hedgeWins: prometheus.NewDesc("s3_front_hedge_wins_total",
+			"Hedged chunk requests where the second replica answered first.", nil, nil),
+	}
+}
+
+func (c *clusterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.chunkServers
+	ch <- c.chunkServerLiveness
+	ch <- c.chunkServerBytes
+	ch <- c.chunkServerChunks
+	ch <- c.objects
+	ch <- c.hedgeRequests
+	ch <- c.hedgedRequests
+	ch <- c.hedgeWins
+}
+
+func (c *clusterCollector) Collect(ch chan<- prometheus.Metric) {
+	states := map[string]int{"active": 0, "draining": 0, "removable": 0}
+	liveness := map[string]int{"alive": 0, "offline": 0}
+	for _, server := range c.service.ListChunkServers() {
+		states[server.State]++
+		if server.Offline {
+			liveness["offline"]++
+		} else {
+			liveness["alive"]++
+		}
+		ch <- prometheus.MustNewConstMetric(c.chunkServerBytes, prometheus.GaugeValue, float64(server.Bytes), server.Address)
+		ch <- prometheus.MustNewConstMetric(c.chunkServerChunks, prometheus.GaugeValue, float64(server.Chunks), server.Address)
+	}
+	for state, count := range states {
+		ch <- prometheus.MustNewConstMetric(c.chunkServers, prometheus.GaugeValue, float64(count), state)
+	}
+	for status, count := range liveness {
+		ch <- prometheus.MustNewConstMetric(c.chunkServerLiveness, prometheus.GaugeValue, float64(count), status)
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.objects, prometheus.GaugeValue, float64(c.service.ObjectCount()))
+
+	hedge := c.service.HedgeStats()
+	ch <- prometheus.MustNewConstMetric(c.hedgeRequests, prometheus.CounterValue, float64(hedge.Requests))
+	ch <- prometheus.MustNewConstMetric(c.hedgedRequests, prometheus.CounterValue, float64(hedge.Hedged))
+	ch <- prometheus.MustNewConstMetric(c.hedgeWins, prometheus.CounterValue, float64(hedge.HedgeWins))
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"simple-s3-adventure/internal/front_server/front_service"
+	uuid2 "simple-s3-adventure/pkg/uuid"
+)
+
+// StatHandler returns the metadata of a file.
+func (f *FrontServer) StatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	uuid := r.URL.Query().Get("uuid")
+	if err := uuid2.Validate(uuid); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	object, err := f.service.Stat(uuid)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, object)
+}
+
+// ListHandler returns the files whose name starts with the prefix parameter.
+func (f *FrontServer) ListHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, f.service.List(r.URL.Query().Get("prefix")))
+}
+
+// DeleteHandler deletes a file.
+func (f *FrontServer) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	uuid := r.URL.Query().Get("uuid")
+	if err := uuid2.Validate(uuid); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Chunks are deleted even if the client goes away, otherwise they would be left on chunk servers
+	err := f.service.DeleteFile(context.WithoutCancel(r.Context()), uuid)
+	if errors.Is(err, front_service.ErrFileNotFound) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		httpError(w, "Failed to delete file", http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
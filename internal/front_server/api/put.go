@@ -9,13 +9,15 @@ import (
 )
 
 const (
-	defaultNumParts      = 6
-	defaultMaxUploadSize = 10 << 20 // 10 MB
+	defaultNumParts          = 6
+	defaultMaxUploadSize     = 10 << 20 // 10 MB
+	defaultReplicationFactor = 1
 )
 
 var (
-	numParts      = config.GetEnvInt("NUM_PARTS", defaultNumParts)
-	maxUploadSize = config.GetEnvInt64("MAX_UPLOAD_SIZE", defaultMaxUploadSize)
+	numParts          = config.GetEnvInt("NUM_PARTS", defaultNumParts)
+	maxUploadSize     = config.GetEnvInt64("MAX_UPLOAD_SIZE", defaultMaxUploadSize)
+	replicationFactor = config.GetEnvInt("REPLICATION_FACTOR", defaultReplicationFactor)
 )
 
 type putResponse struct {
@@ -35,7 +37,7 @@ func (f *FrontServer) PutHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fileUUID, err := f.service.UploadFile(r, maxUploadSize, numParts)
+	fileUUID, err := f.service.UploadFile(r, maxUploadSize, numParts, replicationFactor)
 	if err != nil {
 		httpError(w, "Failed to upload file", http.StatusInternalServerError, err)
 		return
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// parseRange parses a Range header with a single byte range and returns the requested part of a file of the given size.
+// ok is false if the whole file should be sent: there is no Range header or it has several ranges.
+func parseRange(header string, size int64) (offset, length int64, ok bool, err error) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, errRangeNotSatisfiable
+	}
+
+	if first == "" {
+		// The last bytes of the file
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false, errRangeNotSatisfiable
+		}
+		n = min(n, size)
+		return size - n, n, true, nil
+	}
+
+	offset, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || offset < 0 || offset >= size {
+		return 0, 0, false, errRangeNotSatisfiable
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < offset {
+			return 0, 0, false, errRangeNotSatisfiable
+		}
+		end = min(end, size-1)
+	}
+	return offset, end - offset + 1, true, nil
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header         string
+		offset, length int64
+		ok             bool
+		err            error
+	}{
+		{"", 0, 0, false, nil},
+		{"bytes=0-99", 0, 100, true, nil},
+		{"bytes=10-", 10, 90, true, nil},
+		{"bytes=10-19", 10, 10, true, nil},
+		{"bytes=90-200", 90, 10, true, nil},
+		{"bytes=-10", 90, 10, true, nil},
+		{"bytes=-200", 0, 100, true, nil},
+		{"bytes=0-1,5-6", 0, 0, false, nil},
+		{"items=0-1", 0, 0, false, nil},
+		{"bytes=100-", 0, 0, false, errRangeNotSatisfiable},
+		{"bytes=20-10", 0, 0, false, errRangeNotSatisfiable},
+		{"bytes=-0", 0, 0, false, errRangeNotSatisfiable},
+		{"bytes=abc", 0, 0, false, errRangeNotSatisfiable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			offset, length, ok, err := parseRange(tt.header, 100)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.offset, offset)
+			assert.Equal(t, tt.length, length)
+		})
+	}
+}
@@ -1,15 +1,44 @@
 package api
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
 
+	"simple-s3-adventure/internal/front_server/front_service"
+	"simple-s3-adventure/internal/front_server/rebalance_service"
+	"simple-s3-adventure/internal/front_server/registry_service"
+	"simple-s3-adventure/pkg/cluster"
 	"simple-s3-adventure/pkg/logger"
 )
 
+// authenticated rejects requests of chunk servers and admin requests without the cluster token with 401 Unauthorized.
+// Without a cluster token, requests are only accepted if insecure registration is enabled.
+func (f *FrontServer) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		insecure := f.clusterToken == "" && f.insecureRegistration
+		if !insecure && !cluster.ValidToken(r, f.clusterToken) {
+			logger.GetLogger().Warn("Rejected request without a valid cluster token",
+				slog.String("path", r.URL.Path),
+				slog.String("remote_address", r.RemoteAddr))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="cluster"`)
+			http.Error(w, "Invalid cluster token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RegisterChunkServerHandler registers the chunk server given by the "url" and "id" fields (PUT), or
+// deregisters it when it shuts down (DELETE).
 func (f *FrontServer) RegisterChunkServerHandler(w http.ResponseWriter, r *http.Request) {
 	lg := logger.GetLogger()
 
+	if r.Method == http.MethodDelete {
+		f.deregisterChunkServer(w, r)
+		return
+	}
 	if r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		lg.Error("Method not allowed", slog.String("method", r.Method))
@@ -17,11 +46,82 @@ func (f *FrontServer) RegisterChunkServerHandler(w http.ResponseWriter, r *http.
 	}
 
 	serverURL := r.FormValue("url")
-	err := f.service.RegisterChunkServer(serverURL)
+	nodeID := r.FormValue("id")
+	grpcAddress := r.FormValue("grpc_address")
+	reregistered, err := f.service.RegisterChunkServer(r.Context(), serverURL, nodeID, grpcAddress, r.FormValue("mode"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		lg.Warn("Rejected chunk server", slog.String("url", serverURL), slog.String("node_id", nodeID), slog.Any("error", err))
+		switch {
+		case errors.Is(err, front_service.ErrChunkServerUnreachable):
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		case errors.Is(err, registry_service.ErrChunkServerAlreadyRegistered), errors.Is(err, front_service.ErrNodeIDMismatch):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 	w.WriteHeader(http.StatusOK)
+	if reregistered {
+		w.Write([]byte("Chunk server re-registered successfully"))
+		return
+	}
 	w.Write([]byte("Chunk server registered successfully"))
 }
+
+func (f *FrontServer) deregisterChunkServer(w http.ResponseWriter, r *http.Request) {
+	err := f.service.DeregisterChunkServer(r.FormValue("url"), r.FormValue("id"))
+	switch {
+	case errors.Is(err, registry_service.ErrChunkServerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// ReportCorruptChunkHandler accepts the report of a chunk server that found a chunk corrupted and removed it.
+// The chunk is copied again to the chunk server from another replica in the background.
+func (f *FrontServer) ReportCorruptChunkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	index, err := strconv.Atoi(r.FormValue("index"))
+	if err != nil || index < 0 {
+		http.Error(w, "Invalid chunk index", http.StatusBadRequest)
+		return
+	}
+	err = f.rebalancer.RepairCorruptReplica(r.FormValue("url"), r.FormValue("uuid"), index)
+	switch {
+	case errors.Is(err, registry_service.ErrChunkServerNotFound), errors.Is(err, rebalance_service.ErrReplicaNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// ChunkServerModeHandler records the mode a chunk server reports when it is switched, given by the "url"
+// and "mode" parameters. Chunks are only placed on read-write chunk servers.
+func (f *FrontServer) ChunkServerModeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := f.service.SetChunkServerMode(r.FormValue("url"), r.FormValue("mode"))
+	switch {
+	case errors.Is(err, registry_service.ErrChunkServerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, registry_service.ErrInvalidChunkServerMode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"simple-s3-adventure/pkg/cluster"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticated(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   FrontServer
+		token    string
+		expected int
+	}{
+		{"valid token", FrontServer{clusterToken: "secret"}, "secret", http.StatusOK},
+		{"invalid token", FrontServer{clusterToken: "secret"}, "other", http.StatusUnauthorized},
+		{"missing token", FrontServer{clusterToken: "secret"}, "", http.StatusUnauthorized},
+		{"token checked despite insecure registration", FrontServer{clusterToken: "secret", insecureRegistration: true}, "", http.StatusUnauthorized},
+		{"no cluster token", FrontServer{}, "", http.StatusUnauthorized},
+		{"insecure registration", FrontServer{insecureRegistration: true}, "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.server.authenticated(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodPut, "/register_chunk_server", nil)
+			cluster.SetToken(req, tt.token)
+			w := httptest.NewRecorder()
+
+			handler(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+			if tt.expected == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestHandler_AdminRequiresToken(t *testing.T) {
+	handler := (&FrontServer{clusterToken: "secret"}).Handler()
+
+	for _, path := range []string{
+		"/admin/rebalance",
+		"/admin/rebalance/pause",
+		"/admin/rebalance/resume",
+		"/admin/chunk_servers",
+		"/admin/chunk_servers/drain",
+		"/admin/objects",
+		"/admin/objects/verify",
+		"/admin/scrub",
+		"/admin/gc",
+	} {
+		t.Run(path, func(t *testing.T) {
+			for _, token := range []string{"", "other"} {
+				req := httptest.NewRequest(http.MethodPut, path, nil)
+				cluster.SetToken(req, token)
+				w := httptest.NewRecorder()
+
+				handler.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusUnauthorized, w.Code, "token %q", token)
+			}
+		})
+	}
+}
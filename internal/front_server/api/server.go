@@ -11,60 +11,128 @@ import (
 	"syscall"
 	"time"
 
+	"simple-s3-adventure/internal/front_server/chunk_transport"
+	"simple-s3-adventure/internal/front_server/metrics"
+	"simple-s3-adventure/internal/front_server/rebalance_service"
 	"simple-s3-adventure/internal/front_server/registry_service"
+	"simple-s3-adventure/pkg/config"
 	"simple-s3-adventure/pkg/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const shutdownTimeout = 5 * time.Second
 
 type FrontServer struct {
-	service *front_service.FrontService
-	server  *http.Server
+	service    *front_service.FrontService
+	rebalancer *rebalance_service.Rebalancer
+	server     *http.Server
+	// clusterToken authenticates the requests of chunk servers
+	clusterToken string
+	// insecureRegistration accepts chunk servers without a token when no cluster token is set
+	insecureRegistration bool
 }
 
 func NewFrontServer() *FrontServer {
+	registry := registry_service.NewChunkServerRegistry()
+	allocationMap := registry_service.NewChunkAllocationMap()
+
 	return &FrontServer{
-		service: front_service.NewFrontService(
-			registry_service.NewChunkServerRegistry(),
-			registry_service.NewChunkAllocationMap()),
+		service:    front_service.NewFrontService(registry, allocationMap, append(downloadOptions(), transportOption())...),
+		rebalancer: rebalance_service.NewRebalancer(rebalancerConfig(), registry, allocationMap, &http.Client{}),
+
+		clusterToken:         config.GetEnvString("CLUSTER_TOKEN", ""),
+		insecureRegistration: config.GetEnvString("INSECURE_REGISTRATION", "") == "true",
 	}
 }
 
+// transportOption selects how chunks are moved to and from chunk servers, "http" or "grpc".
+// The rebalancer always uses HTTP.
+func transportOption() front_service.FrontServiceOption {
+	name := config.GetEnvString("CHUNK_TRANSPORT", chunk_transport.HTTP)
+	transport, err := chunk_transport.New(name)
+	if err != nil {
+		logger.GetLogger().Error("Invalid chunk transport", slog.String("transport", name), slog.Any("error", err))
+		os.Exit(1)
+	}
+	return front_service.WithTransport(transport)
+}
+
+// Handler returns the HTTP handler serving the API of the front server.
+func (f *FrontServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/register_chunk_server", metrics.InstrumentHandler("register_chunk_server", f.authenticated(f.RegisterChunkServerHandler)))
+	mux.Handle("/report_corrupt_chunk", metrics.InstrumentHandler("report_corrupt_chunk", f.authenticated(f.ReportCorruptChunkHandler)))
+	mux.Handle("/chunk_server_mode", metrics.InstrumentHandler("chunk_server_mode", f.authenticated(f.ChunkServerModeHandler)))
+	mux.Handle("/put", metrics.InstrumentHandler("put", f.PutHandler))
+	mux.Handle("/get", metrics.InstrumentHandler("get", f.GetHandler))
+	mux.Handle("/delete", metrics.InstrumentHandler("delete", f.DeleteHandler))
+	mux.Handle("/stat", metrics.InstrumentHandler("stat", f.StatHandler))
+	mux.Handle("/list", metrics.InstrumentHandler("list", f.ListHandler))
+	mux.Handle("/admin/rebalance", metrics.InstrumentHandler("admin_rebalance", f.authenticated(f.RebalanceStatusHandler)))
+	mux.Handle("/admin/rebalance/pause", metrics.InstrumentHandler("admin_rebalance_pause", f.authenticated(f.RebalancePauseHandler)))
+	mux.Handle("/admin/rebalance/resume", metrics.InstrumentHandler("admin_rebalance_resume", f.authenticated(f.RebalanceResumeHandler)))
+	mux.Handle("/admin/chunk_servers", metrics.InstrumentHandler("admin_chunk_servers", f.authenticated(f.ChunkServersHandler)))
+	mux.Handle("/admin/chunk_servers/drain", metrics.InstrumentHandler("admin_chunk_servers_drain", f.authenticated(f.DrainHandler)))
+	mux.Handle("/admin/objects", metrics.InstrumentHandler("admin_objects", f.authenticated(f.ObjectHandler)))
+	mux.Handle("/admin/objects/verify", metrics.InstrumentHandler("admin_objects_verify", f.authenticated(f.VerifyHandler)))
+	mux.Handle("/admin/scrub", metrics.InstrumentHandler("admin_scrub", f.authenticated(f.ScrubHandler)))
+	mux.Handle("/admin/gc", metrics.InstrumentHandler("admin_gc", f.authenticated(f.GCHandler)))
+	mux.Handle("/stats", metrics.InstrumentHandler("stats", f.StatsHandler))
+	mux.Handle("/metrics", promhttp.Handler())
+	return mux
+}
+
 // StartServer starts the HTTP server on the given port.
 func StartServer(ctx context.Context, port string) {
 	server := NewFrontServer()
 	lg := logger.GetLogger()
 
-	// Setting up handlers
-	http.HandleFunc("/register_chunk_server", server.RegisterChunkServerHandler)
-	http.HandleFunc("/put", server.PutHandler)
-	http.HandleFunc("/get", server.GetHandler)
+	prometheus.MustRegister(newClusterCollector(server.service))
+	if server.clusterToken == "" {
+		if !server.insecureRegistration {
+			lg.Error("CLUSTER_TOKEN is not set, set INSECURE_REGISTRATION=true to let any client register a chunk server and use the admin API")
+			os.Exit(1)
+		}
+		lg.Warn("CLUSTER_TOKEN is not set, any client can register a chunk server and use the admin API")
+	}
 
 	// Create the HTTP server
 	server.server = &http.Server{
 		Addr:    port,
-		Handler: nil,
+		Handler: server.Handler(),
 	}
 
 	// Starting the server
 	lg.Info("Starting front server", slog.String("port", port))
 	go func() {
 		if err := server.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			lg.Error("Could not start server: ", err)
+			lg.Error("Could not start server", slog.Any("error", err))
 		}
 	}()
 
+	rebalancerCtx, stopRebalancer := context.WithCancel(ctx)
+	rebalancerDone := make(chan struct{})
+	go func() {
+		defer close(rebalancerDone)
+		server.rebalancer.Run(rebalancerCtx)
+	}()
+
 	// Graceful shutdown
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	<-done
 
 	lg.Info("Shutting down front server")
+	stopRebalancer()
+	<-rebalancerDone
+
 	ctx, cancel := context.WithTimeout(ctx, shutdownTimeout)
 	defer cancel()
 
 	if err := server.server.Shutdown(ctx); err != nil {
-		lg.Error("Server shutdown failed:", err)
+		lg.Error("Server shutdown failed", slog.Any("error", err))
 	} else {
 		lg.Info("Server shutdown gracefully")
 	}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// StatsHandler returns the data distribution across chunk servers as JSON,
+// or as a table if format=text is given or the client accepts only plain text.
+func (f *FrontServer) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats := f.service.Stats()
+
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.HasPrefix(r.Header.Get("Accept"), "text/plain") {
+		format = "text"
+	}
+	switch format {
+	case "", "json":
+		writeJSON(w, stats)
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := stats.WriteText(w); err != nil {
+			httpError(w, "Failed to write response", http.StatusInternalServerError, err)
+		}
+	default:
+		http.Error(w, "Unknown format", http.StatusBadRequest)
+	}
+}
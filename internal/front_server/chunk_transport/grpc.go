@@ -0,0 +1,214 @@
+package chunk_transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"simple-s3-adventure/internal/chunk_rpc"
+	"simple-s3-adventure/internal/front_server/registry_service"
+
+	"github.com/cenkalti/backoff"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMessageSize is the amount of chunk data sent in a single PutChunk message.
+const grpcMessageSize = 64 << 10
+
+// GRPCTransport uses the gRPC API of chunk servers. A connection is kept for every chunk server.
+type GRPCTransport struct {
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+func NewGRPCTransport() *GRPCTransport {
+	return &GRPCTransport{conns: make(map[string]*grpc.ClientConn)}
+}
+
+// client returns the client of the chunk server, connecting to it lazily.
+func (t *GRPCTransport) client(server *registry_service.ChunkServer) (chunk_rpc.ChunkServiceClient, error) {
+	address := server.GRPCAddress()
+	if address == "" {
+		return nil, backoff.Permanent(fmt.Errorf("chunk server %s has no gRPC address", server.Address()))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	conn, ok := t.conns[address]
+	if !ok {
+		var err error
+		conn, err = grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, backoff.Permanent(fmt.Errorf("failed to create gRPC client for %s: %w", address, err))
+		}
+		t.conns[address] = conn
+	}
+	return chunk_rpc.NewChunkServiceClient(conn), nil
+}
+
+// Close closes the connections to all chunk servers.
+func (t *GRPCTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var errs []error
+	for address, conn := range t.conns {
+		errs = append(errs, conn.Close())
+		delete(t.conns, address)
+	}
+	return errors.Join(errs...)
+}
+
+// PutChunk streams the chunk in messages of up to 64 KB.
+func (t *GRPCTransport) PutChunk(ctx context.Context, server *registry_service.ChunkServer, uuid string, index int, r io.Reader, size int64, overwrite bool) error {
+	client, err := t.client(server)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.PutChunk(ctx)
+	if err != nil {
+		return grpcError(server, "PutChunk", err)
+	}
+
+	// The ID is sent even if the chunk is empty
+	msg := &chunk_rpc.PutChunkRequest{Uuid: uuid, Index: int32(index), Overwrite: overwrite}
+	buf := make([]byte, grpcMessageSize)
+	var sent int64
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 || msg.Uuid != "" {
+			msg.Data = buf[:n]
+			if err := stream.Send(msg); err != nil {
+				// The reason is reported by CloseAndRecv
+				break
+			}
+			sent += int64(n)
+			msg = &chunk_rpc.PutChunkRequest{}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to read chunk: %w", readErr)
+		}
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return grpcError(server, "PutChunk", err)
+	}
+	if sent != size || resp.GetSize() != size {
+		return backoff.Permanent(fmt.Errorf("stored %d bytes of the chunk on %s, expected %d", resp.GetSize(), server.Address(), size))
+	}
+	return nil
+}
+
+// GetChunk opens the stream and waits for its first message, which carries the number of bytes that follow.
+func (t *GRPCTransport) GetChunk(ctx context.Context, server *registry_service.ChunkServer, uuid string, index int, offset, length int64) (io.ReadCloser, int64, error) {
+	client, err := t.client(server)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if length < 0 {
+		length = 0
+	} else if length == 0 {
+		// Zero means up to the end of the chunk in the API
+		return io.NopCloser(eofReader{}), 0, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := client.GetChunk(ctx, &chunk_rpc.GetChunkRequest{Uuid: uuid, Index: int32(index), Offset: offset, Length: length})
+	if err != nil {
+		cancel()
+		return nil, 0, grpcError(server, "GetChunk", err)
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		cancel()
+		return nil, 0, grpcError(server, "GetChunk", err)
+	}
+	return &getChunkReader{stream: stream, data: first.GetData(), cancel: cancel}, first.GetSize(), nil
+}
+
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+// getChunkReader reads the data of a GetChunk stream.
+type getChunkReader struct {
+	stream chunk_rpc.ChunkService_GetChunkClient
+	data   []byte
+	cancel context.CancelFunc
+}
+
+func (r *getChunkReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		msg, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.data = msg.GetData()
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (r *getChunkReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+// DeleteChunk deletes the chunk.
+func (t *GRPCTransport) DeleteChunk(ctx context.Context, server *registry_service.ChunkServer, uuid string, index int) error {
+	client, err := t.client(server)
+	if err != nil {
+		return err
+	}
+	if _, err := client.DeleteChunk(ctx, &chunk_rpc.DeleteChunkRequest{Uuid: uuid, Index: int32(index)}); err != nil {
+		return grpcError(server, "DeleteChunk", err)
+	}
+	return nil
+}
+
+// Heartbeat returns the identity and the storage of the chunk server.
+func (t *GRPCTransport) Heartbeat(ctx context.Context, server *registry_service.ChunkServer) (Heartbeat, error) {
+	client, err := t.client(server)
+	if err != nil {
+		return Heartbeat{}, err
+	}
+	resp, err := client.Heartbeat(ctx, &chunk_rpc.HeartbeatRequest{})
+	if err != nil {
+		return Heartbeat{}, grpcError(server, "Heartbeat", err)
+	}
+	return Heartbeat{
+		NodeID:    resp.GetNodeId(),
+		Chunks:    resp.GetChunks(),
+		Bytes:     resp.GetBytes(),
+		FreeBytes: resp.GetFreeBytes(),
+	}, nil
+}
+
+// grpcError describes the failed call. Unavailable servers and server errors are transient, other errors are permanent.
+func grpcError(server *registry_service.ChunkServer, method string, err error) error {
+	err = fmt.Errorf("%s on %s failed: %w", method, server.GRPCAddress(), err)
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded, codes.Internal, codes.Unknown:
+		return err
+	default:
+		return backoff.Permanent(err)
+	}
+}
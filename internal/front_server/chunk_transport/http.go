@@ -0,0 +1,140 @@
+package chunk_transport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+
+	"github.com/cenkalti/backoff"
+)
+
+// HTTPTransport uses the REST API of chunk servers.
+type HTTPTransport struct {
+	httpClient *http.Client
+}
+
+// NewHTTPTransport returns a transport sending requests with the given client, http.DefaultClient if it is nil.
+func NewHTTPTransport(httpClient *http.Client) *HTTPTransport {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &HTTPTransport{httpClient: httpClient}
+}
+
+// PutChunk uploads the chunk as a multipart form.
+func (t *HTTPTransport) PutChunk(ctx context.Context, server *registry_service.ChunkServer, uuid string, index int, r io.Reader, size int64, overwrite bool) error {
+	var requestBody bytes.Buffer
+	writer := multipart.NewWriter(&requestBody)
+
+	if err := writer.WriteField("uuid", uuid); err != nil {
+		return backoff.Permanent(fmt.Errorf("failed to add UUID field: %w", err))
+	}
+	if err := writer.WriteField("index", strconv.Itoa(index)); err != nil {
+		return backoff.Permanent(fmt.Errorf("failed to add index field: %w", err))
+	}
+	if overwrite {
+		if err := writer.WriteField("overwrite", "true"); err != nil {
+			return backoff.Permanent(fmt.Errorf("failed to add overwrite field: %w", err))
+		}
+	}
+
+	part, err := writer.CreateFormFile("file", "file.txt")
+	if err != nil {
+		return backoff.Permanent(fmt.Errorf("failed to create form file: %w", err))
+	}
+
+	if n, err := io.Copy(part, r); err != nil {
+		return fmt.Errorf("failed to copy chunk to part: %w", err)
+	} else if n != size {
+		return backoff.Permanent(fmt.Errorf("read %d bytes of the chunk, expected %d", n, size))
+	}
+
+	if err := writer.Close(); err != nil {
+		return backoff.Permanent(fmt.Errorf("failed to close writer: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, server.Address()+"/put", &requestBody)
+	if err != nil {
+		return backoff.Permanent(fmt.Errorf("failed to create PUT request: %w", err))
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send PUT request to %s: %w", server.Address(), err)
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp, http.StatusOK, server)
+}
+
+// GetChunk sends a GET request, with a Range header unless the whole chunk is requested,
+// and validates the status of the response.
+func (t *HTTPTransport) GetChunk(ctx context.Context, server *registry_service.ChunkServer, uuid string, index int, offset, length int64) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, chunkURL(server, "/get", uuid, index), nil)
+	if err != nil {
+		return nil, 0, backoff.Permanent(fmt.Errorf("failed to create GET request: %w", err))
+	}
+
+	expectedStatus := http.StatusPartialContent
+	switch {
+	case length >= 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	default:
+		expectedStatus = http.StatusOK
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to send GET request to %s: %w", server.Address(), err)
+	}
+	if err := checkStatus(resp, expectedStatus, server); err != nil {
+		resp.Body.Close()
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// DeleteChunk sends a DELETE request.
+func (t *HTTPTransport) DeleteChunk(ctx context.Context, server *registry_service.ChunkServer, uuid string, index int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, chunkURL(server, "/delete", uuid, index), nil)
+	if err != nil {
+		return backoff.Permanent(fmt.Errorf("failed to create DELETE request: %w", err))
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send DELETE request to %s: %w", server.Address(), err)
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp, http.StatusOK, server)
+}
+
+// chunkURL returns the URL of the endpoint for the chunk.
+func chunkURL(server *registry_service.ChunkServer, path string, uuid string, index int) string {
+	params := url.Values{"uuid": {uuid}, "index": {strconv.Itoa(index)}}
+	return server.Address() + path + "?" + params.Encode()
+}
+
+// checkStatus returns an error if the response does not have the expected status.
+// Server errors are transient, other unexpected responses are permanent.
+func checkStatus(resp *http.Response, expected int, server *registry_service.ChunkServer) error {
+	if resp.StatusCode == expected {
+		return nil
+	}
+	err := fmt.Errorf("received HTTP status %d from %s", resp.StatusCode, server.Address())
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+	return backoff.Permanent(err)
+}
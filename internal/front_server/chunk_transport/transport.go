@@ -0,0 +1,59 @@
+// Package chunk_transport moves chunks between the front server and chunk servers over HTTP or gRPC.
+package chunk_transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+)
+
+const (
+	// HTTP is the REST API of chunk servers.
+	HTTP = "http"
+	// GRPC is the gRPC API of chunk servers, chunk servers must register with their gRPC address.
+	GRPC = "grpc"
+)
+
+// Transport stores, reads and deletes chunks on chunk servers. Chunks are identified by the UUID of their file
+// and their index in the file.
+//
+// A single call is a single attempt, the callers retry. Errors that can't be fixed by retrying,
+// like a missing chunk, are wrapped with backoff.Permanent.
+type Transport interface {
+	// PutChunk stores size bytes read from r as the chunk. An existing chunk is only replaced if overwrite is set,
+	// otherwise the chunk server rejects the chunk.
+	PutChunk(ctx context.Context, server *registry_service.ChunkServer, uuid string, index int, r io.Reader, size int64, overwrite bool) error
+	// GetChunk requests length bytes of the chunk starting at offset, a negative length reads up to the end
+	// of the chunk. It returns once the chunk server has accepted the request, together with the number
+	// of bytes the server is going to send. The caller must close the reader.
+	GetChunk(ctx context.Context, server *registry_service.ChunkServer, uuid string, index int, offset, length int64) (io.ReadCloser, int64, error)
+	// DeleteChunk deletes the chunk.
+	DeleteChunk(ctx context.Context, server *registry_service.ChunkServer, uuid string, index int) error
+}
+
+// Heartbeat describes a chunk server.
+type Heartbeat struct {
+	NodeID    string
+	Chunks    int64
+	Bytes     int64
+	FreeBytes int64
+}
+
+// Heartbeater is implemented by transports that can check whether a chunk server is reachable.
+type Heartbeater interface {
+	Heartbeat(ctx context.Context, server *registry_service.ChunkServer) (Heartbeat, error)
+}
+
+// New returns the transport with the given name, HTTP or GRPC.
+func New(name string) (Transport, error) {
+	switch name {
+	case HTTP:
+		return NewHTTPTransport(nil), nil
+	case GRPC:
+		return NewGRPCTransport(), nil
+	default:
+		return nil, fmt.Errorf("unknown chunk transport %q", name)
+	}
+}
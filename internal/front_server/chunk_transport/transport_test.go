@@ -0,0 +1,134 @@
+package chunk_transport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	chunkAPI "simple-s3-adventure/internal/chunk_server/api"
+	chunkService "simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/internal/front_server/registry_service"
+
+	"github.com/cenkalti/backoff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testUUID   = "123e4567-e89b-12d3-a456-426614174000"
+	testNodeID = "00000000-0000-4000-8000-000000000001"
+)
+
+// newTestChunkServer starts a chunk server serving both the HTTP and the gRPC API.
+func newTestChunkServer(t *testing.T) *registry_service.ChunkServer {
+	t.Helper()
+
+	config := &chunkService.ServerConfig{UploadDir: t.TempDir(), MaxUploadSize: 10 << 20}
+	httpServer := httptest.NewServer(chunkAPI.NewHandler(config))
+	t.Cleanup(httpServer.Close)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := chunkAPI.NewGRPCServer(config, testNodeID)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	server := registry_service.NewChunkServer(httpServer.URL)
+	server.SetGRPCAddress(lis.Addr().String())
+	return server
+}
+
+func isPermanent(err error) bool {
+	_, ok := err.(*backoff.PermanentError)
+	return ok
+}
+
+func readChunk(t *testing.T, transport Transport, server *registry_service.ChunkServer, index int, offset, length int64) []byte {
+	t.Helper()
+
+	body, size, err := transport.GetChunk(context.Background(), server, testUUID, index, offset, length)
+	require.NoError(t, err)
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), size)
+	return data
+}
+
+func TestTransports(t *testing.T) {
+	grpcTransport := NewGRPCTransport()
+	t.Cleanup(func() { grpcTransport.Close() })
+
+	transports := map[string]Transport{
+		HTTP: NewHTTPTransport(nil),
+		GRPC: grpcTransport,
+	}
+	for name, transport := range transports {
+		t.Run(name, func(t *testing.T) {
+			server := newTestChunkServer(t)
+			ctx := context.Background()
+			data := bytes.Repeat([]byte("0123456789"), 20000)
+
+			require.NoError(t, transport.PutChunk(ctx, server, testUUID, 1, bytes.NewReader(data), int64(len(data)), false))
+			require.NoError(t, transport.PutChunk(ctx, server, testUUID, 2, bytes.NewReader([]byte("other")), 5, false))
+
+			assert.Equal(t, data, readChunk(t, transport, server, 1, 0, -1))
+			assert.Equal(t, data[100:], readChunk(t, transport, server, 1, 100, -1))
+			assert.Equal(t, data[100:150], readChunk(t, transport, server, 1, 100, 50))
+			assert.Equal(t, []byte("other"), readChunk(t, transport, server, 2, 0, -1))
+
+			err := transport.PutChunk(ctx, server, testUUID, 2, bytes.NewReader([]byte("new")), 3, false)
+			assert.Error(t, err)
+			assert.True(t, isPermanent(err), "existing chunk must not be retried: %v", err)
+			require.NoError(t, transport.PutChunk(ctx, server, testUUID, 2, bytes.NewReader([]byte("new")), 3, true))
+			assert.Equal(t, []byte("new"), readChunk(t, transport, server, 2, 0, -1))
+
+			require.NoError(t, transport.DeleteChunk(ctx, server, testUUID, 1))
+
+			_, _, err = transport.GetChunk(ctx, server, testUUID, 1, 0, -1)
+			assert.Error(t, err)
+			assert.True(t, isPermanent(err), "missing chunk must not be retried: %v", err)
+
+			err = transport.DeleteChunk(ctx, server, testUUID, 1)
+			assert.Error(t, err)
+			assert.True(t, isPermanent(err), "missing chunk must not be retried: %v", err)
+		})
+	}
+}
+
+func TestGRPCTransport_Heartbeat(t *testing.T) {
+	transport := NewGRPCTransport()
+	t.Cleanup(func() { transport.Close() })
+	server := newTestChunkServer(t)
+
+	heartbeat, err := transport.Heartbeat(context.Background(), server)
+	require.NoError(t, err)
+	assert.Equal(t, testNodeID, heartbeat.NodeID)
+	assert.Equal(t, int64(0), heartbeat.Chunks)
+}
+
+func TestGRPCTransport_NoAddress(t *testing.T) {
+	transport := NewGRPCTransport()
+	server := registry_service.NewChunkServer("http://chunk-server:12090")
+
+	err := transport.DeleteChunk(context.Background(), server, testUUID, 0)
+	assert.ErrorContains(t, err, "has no gRPC address")
+	assert.True(t, isPermanent(err))
+}
+
+func TestNew(t *testing.T) {
+	transport, err := New(HTTP)
+	require.NoError(t, err)
+	assert.IsType(t, &HTTPTransport{}, transport)
+
+	transport, err = New(GRPC)
+	require.NoError(t, err)
+	assert.IsType(t, &GRPCTransport{}, transport)
+
+	_, err = New("smoke signals")
+	assert.Error(t, err)
+}
@@ -1,102 +1,463 @@
 package download_service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/chunk_transport"
 	"simple-s3-adventure/internal/front_server/registry_service"
 	"simple-s3-adventure/pkg/logger"
-	"sync"
+
+	"github.com/cenkalti/backoff"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultMaxRetries    = 5
+	defaultHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout   = 30 * time.Second
 )
 
+// DownloadService assembles a file from its chunks.
+//
+// All chunks are requested concurrently by Open, which validates the response of every chunk server.
+// Nothing is written to the client before Open succeeds, so errors can be reported with a proper status code.
+// With read-ahead, Open only requests the chunks within the budget and the others are requested when the
+// read-ahead reaches them, so their errors abort a download that already started.
+// Then WriteTo copies the chunks to the client in order. Transient failures are retried with backoff,
+// and a chunk download that breaks in the middle is resumed from the last received byte.
+// Replicated chunks are fetched from another replica when one fails or, with hedging, is slow.
 type DownloadService struct {
-	uuid         string
-	chunkServers []*registry_service.ChunkServer
-	readers      []*io.PipeReader
-	writers      []*io.PipeWriter
-	multErrs     []error
-	mu           sync.Mutex
-	wg           sync.WaitGroup
-	logger       *slog.Logger
+	uuid      string
+	parts     []part
+	transport chunk_transport.Transport
+	logger    *slog.Logger
+
+	maxRetries    uint64
+	headerTimeout time.Duration
+	idleTimeout   time.Duration
+	newBackOff    func() backoff.BackOff
+	hedge         *HedgePolicy
+
+	readAheadBudget int64
+
+	rangeSet    bool
+	rangeOffset int64
+	rangeLength int64
+
+	readers []*chunkReader
+	// opened is the number of readers opened by Open, the read-ahead opens the others
+	opened int
+	cancel context.CancelFunc
+}
+
+// part is a chunk of the file together with all its replicas.
+// Only the bytes from start to end of the chunk are downloaded.
+type part struct {
+	index      int
+	size       int64
+	start, end int64
+	replicas   []*registry_service.ChunkServer
 }
 
-func NewDownloadService(uuid string, chunkServers []*registry_service.ChunkServer) *DownloadService {
-	readers, writers := createPipes(len(chunkServers))
-	return &DownloadService{
-		uuid:         uuid,
-		chunkServers: chunkServers,
-		readers:      readers,
-		writers:      writers,
-		multErrs:     []error{},
-		logger:       logger.GetLogger(),
+type DownloadOption func(*DownloadService)
+
+// WithHedging enables hedged requests to replicated chunks.
+func WithHedging(policy *HedgePolicy) DownloadOption {
+	return func(d *DownloadService) {
+		d.hedge = policy
 	}
 }
 
-func (ccm *DownloadService) CopyChunks(w io.Writer) (int64, error) {
-	for i, server := range ccm.chunkServers {
-		ccm.wg.Add(1)
-		go ccm.fetchChunk(i, server.Address)
+// WithTransport fetches chunks with the given transport instead of the HTTP client.
+func WithTransport(transport chunk_transport.Transport) DownloadOption {
+	return func(d *DownloadService) {
+		d.transport = transport
 	}
+}
 
-	ccm.closeWritersAfterCompletion()
+// WithRange downloads length bytes of the file starting at offset instead of the whole file.
+// The range must be within the file.
+func WithRange(offset, length int64) DownloadOption {
+	return func(d *DownloadService) {
+		d.rangeSet = true
+		d.rangeOffset = offset
+		d.rangeLength = length
+	}
+}
 
-	if len(ccm.multErrs) != 0 {
-		ccm.logger.Error("Error fetching chunks", slog.Any("errors", ccm.multErrs))
-		return 0, ccm.multErrs[0]
+// NewDownloadService creates a download of the file stored in the given chunk locations,
+// which are ordered by chunk index and may contain several replicas of a chunk.
+func NewDownloadService(uuid string, chunks []registry_service.ChunkLocation, httpClient *http.Client, opts ...DownloadOption) *DownloadService {
+	var parts []part
+	for _, chunk := range chunks {
+		if len(parts) == 0 || parts[len(parts)-1].index != chunk.Index {
+			parts = append(parts, part{index: chunk.Index, size: chunk.Size, end: chunk.Size})
+		}
+		last := &parts[len(parts)-1]
+		last.replicas = append(last.replicas, chunk.Server)
 	}
 
-	return ccm.copyChunksToWriter(w)
+	d := &DownloadService{
+		uuid:          uuid,
+		parts:         parts,
+		transport:     chunk_transport.NewHTTPTransport(httpClient),
+		logger:        logger.GetLogger(),
+		maxRetries:    defaultMaxRetries,
+		headerTimeout: defaultHeaderTimeout,
+		idleTimeout:   defaultIdleTimeout,
+		newBackOff: func() backoff.BackOff {
+			return backoff.NewExponentialBackOff()
+		},
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.rangeSet {
+		d.parts = selectRange(d.parts, d.rangeOffset, d.rangeLength)
+	}
+	return d
 }
 
-func (ccm *DownloadService) fetchChunk(i int, server string) {
-	defer ccm.wg.Done()
-	defer ccm.writers[i].Close()
-
-	resp, err := http.Get(server + "/get?uuid=" + ccm.uuid)
-	if err != nil {
-		ccm.writers[i].CloseWithError(err)
-		ccm.mu.Lock()
-		ccm.multErrs = append(ccm.multErrs, err)
-		ccm.mu.Unlock()
-		return
+// selectRange keeps the parts overlapping the range and limits them to the bytes within the range.
+func selectRange(parts []part, offset, length int64) []part {
+	var selected []part
+	var partOffset int64
+	for _, p := range parts {
+		start := max(offset-partOffset, 0)
+		end := min(offset+length-partOffset, p.size)
+		partOffset += p.size
+		if start >= end {
+			continue
+		}
+		p.start, p.end = start, end
+		selected = append(selected, p)
 	}
-	defer resp.Body.Close()
+	return selected
+}
 
-	if _, err := io.Copy(ccm.writers[i], resp.Body); err != nil {
-		ccm.writers[i].CloseWithError(err)
-		ccm.mu.Lock()
-		ccm.multErrs = append(ccm.multErrs, err)
-		ccm.mu.Unlock()
+// Size returns the number of bytes of the download, the size of the file unless a range is requested.
+func (d *DownloadService) Size() int64 {
+	var size int64
+	for _, part := range d.parts {
+		size += part.end - part.start
 	}
+	return size
 }
 
-func (ccm *DownloadService) closeWritersAfterCompletion() {
-	go func() {
-		ccm.wg.Wait()
-		for _, writer := range ccm.writers {
-			writer.Close()
+// Open requests the chunks concurrently and waits until every chunk server has responded successfully.
+// With read-ahead, only the chunks within the budget are requested.
+func (d *DownloadService) Open(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	d.cancel = cancel
+
+	readers := make([]*chunkReader, len(d.parts))
+	d.opened = d.eagerParts()
+	var g errgroup.Group
+	for i, part := range d.parts {
+		readers[i] = &chunkReader{ctx: ctx, d: d, part: part, offset: part.start}
+		if i >= d.opened {
+			continue
 		}
-	}()
+		reader := readers[i]
+		g.Go(func() error {
+			if err := reader.open(); err != nil {
+				// There is no point in waiting for other chunks
+				cancel()
+				return err
+			}
+			return nil
+		})
+	}
+	d.readers = readers
+
+	if err := g.Wait(); err != nil {
+		d.logger.Error("Error fetching chunks", slog.String("uuid", d.uuid), slog.Any("error", err))
+		d.Close()
+		return err
+	}
+	return nil
 }
 
-func (ccm *DownloadService) copyChunksToWriter(w io.Writer) (int64, error) {
+// WriteTo copies the chunks to w in order. Open must be called first.
+func (d *DownloadService) WriteTo(w io.Writer) (int64, error) {
+	if d.readAheadBudget > 0 {
+		return d.writeToReadAhead(w)
+	}
+
 	var size int64
-	for _, reader := range ccm.readers {
+	for _, reader := range d.readers {
 		n, err := io.Copy(w, reader)
+		size += n
 		if err != nil {
-			ccm.logger.Error("Error copying chunks", slog.Any("error", err))
+			d.logger.Error("Error copying chunks", slog.String("uuid", d.uuid), slog.Any("error", err))
 			return size, err
 		}
-		size += n
+		reader.Close()
 	}
 	return size, nil
 }
 
-func createPipes(n int) ([]*io.PipeReader, []*io.PipeWriter) {
-	readers := make([]*io.PipeReader, n)
-	writers := make([]*io.PipeWriter, n)
-	for i := 0; i < n; i++ {
-		readers[i], writers[i] = io.Pipe()
+// Close releases connections to chunk servers.
+func (d *DownloadService) Close() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	for _, reader := range d.readers {
+		reader.Close()
+	}
+}
+
+// CopyChunks downloads the whole file to w.
+func (d *DownloadService) CopyChunks(w io.Writer) (int64, error) {
+	if err := d.Open(context.Background()); err != nil {
+		return 0, err
+	}
+	defer d.Close()
+
+	return d.WriteTo(w)
+}
+
+// chunkReader reads a chunk from a chunk server and transparently re-requests the rest of the chunk
+// if the connection breaks or stalls.
+type chunkReader struct {
+	ctx  context.Context
+	d    *DownloadService
+	part part
+
+	// server is the replica the body is read from
+	server        *registry_service.ChunkServer
+	body          io.ReadCloser
+	cancelAttempt context.CancelFunc
+	idleTimer     *time.Timer
+
+	// offset is the position in the chunk the next byte is read from
+	offset int64
+	// failures is the number of consecutive resumes without any progress
+	failures uint64
+	// nextReplica is the replica the next request starts from, so retries go to other replicas first
+	nextReplica int
+}
+
+// attempt is a request for the chunk to a single replica.
+type attempt struct {
+	server *registry_service.ChunkServer
+	hedged bool
+	start  time.Time
+	cancel context.CancelFunc
+	body   io.ReadCloser
+	err    error
+}
+
+// open requests the rest of the chunk, retrying transient failures.
+func (c *chunkReader) open() error {
+	retry := 0
+	bo := backoff.WithContext(backoff.WithMaxRetries(c.d.newBackOff(), c.d.maxRetries), c.ctx)
+	return backoff.RetryNotify(func() error {
+		retry++
+		return c.request()
+	}, bo, func(err error, next time.Duration) {
+		c.d.logger.Warn("Failed to fetch chunk",
+			slog.String("uuid", c.d.uuid),
+			slog.Int("chunk", c.part.index),
+			slog.Int("attempt", retry),
+			slog.Duration("retry_in", next),
+			slog.Any("error", err))
+	})
+}
+
+// request fetches the rest of the chunk from one of its replicas. If a replica fails, the next one is
+// requested right away. If the replica does not respond within the hedging delay, the next one is
+// requested too and the first successful response wins.
+func (c *chunkReader) request() error {
+	c.Close()
+
+	replicas := make([]*registry_service.ChunkServer, 0, len(c.part.replicas))
+	for i := range c.part.replicas {
+		replicas = append(replicas, c.part.replicas[(c.nextReplica+i)%len(c.part.replicas)])
+	}
+	c.nextReplica++
+
+	results := make(chan *attempt, len(replicas))
+	var attempts []*attempt
+	launch := func(hedged bool) {
+		ctx, cancel := context.WithCancel(c.ctx)
+		a := &attempt{server: replicas[len(attempts)], hedged: hedged, start: time.Now(), cancel: cancel}
+		attempts = append(attempts, a)
+		go func() {
+			a.body, a.err = c.send(ctx, cancel, a.server)
+			results <- a
+		}()
+	}
+
+	launch(false)
+	var hedgeTimer <-chan time.Time
+	if c.d.hedge != nil && len(replicas) > 1 {
+		c.d.hedge.requests.Add(1)
+		timer := time.NewTimer(c.d.hedge.Delay())
+		defer timer.Stop()
+		hedgeTimer = timer.C
+	}
+
+	var errs []error
+	permanent := true
+	for pending := 1; pending > 0; {
+		select {
+		case <-hedgeTimer:
+			hedgeTimer = nil
+			if len(attempts) < len(replicas) {
+				c.d.hedge.hedged.Add(1)
+				launch(true)
+				pending++
+			}
+
+		case a := <-results:
+			pending--
+			if a.err == nil {
+				if c.d.hedge != nil {
+					c.d.hedge.Observe(time.Since(a.start))
+					if a.hedged {
+						c.d.hedge.hedgeWins.Add(1)
+					}
+				}
+				c.cancelLosers(a, attempts, results, pending)
+
+				c.server = a.server
+				c.body = a.body
+				c.cancelAttempt = a.cancel
+				c.idleTimer = time.AfterFunc(c.d.idleTimeout, a.cancel)
+				return nil
+			}
+
+			var permanentErr *backoff.PermanentError
+			if errors.As(a.err, &permanentErr) {
+				errs = append(errs, permanentErr.Err)
+			} else {
+				errs = append(errs, a.err)
+				permanent = false
+			}
+
+			// Fail over to the next replica
+			if len(attempts) < len(replicas) && c.ctx.Err() == nil {
+				launch(false)
+				pending++
+			}
+		}
+	}
+
+	err := errors.Join(errs...)
+	if permanent {
+		return backoff.Permanent(err)
+	}
+	return err
+}
+
+// cancelLosers cancels the requests to other replicas and releases their responses.
+func (c *chunkReader) cancelLosers(winner *attempt, attempts []*attempt, results <-chan *attempt, pending int) {
+	for _, a := range attempts {
+		if a != winner {
+			a.cancel()
+		}
+	}
+	go func() {
+		for ; pending > 0; pending-- {
+			if a := <-results; a.body != nil {
+				a.body.Close()
+			}
+		}
+	}()
+}
+
+// send requests the rest of the chunk from the replica and validates the response.
+func (c *chunkReader) send(ctx context.Context, cancel context.CancelFunc, server *registry_service.ChunkServer) (io.ReadCloser, error) {
+	length := int64(-1)
+	if c.part.end < c.part.size {
+		length = c.part.end - c.offset
+	}
+
+	headerTimer := time.AfterFunc(c.d.headerTimeout, cancel)
+	body, size, err := c.d.transport.GetChunk(ctx, server, c.d.uuid, c.part.index, c.offset, length)
+	headerTimer.Stop()
+	if err != nil {
+		cancel()
+		if c.ctx.Err() != nil {
+			return nil, backoff.Permanent(c.ctx.Err())
+		}
+		return nil, c.wrapError(err)
+	}
+
+	if expected := c.part.end - c.offset; size != expected {
+		body.Close()
+		cancel()
+		return nil, backoff.Permanent(fmt.Errorf("chunk %d: received Content-Length %d from %s, expected %d",
+			c.part.index, size, server.Address(), expected))
+	}
+	return body, nil
+}
+
+// wrapError adds the chunk index to the error and keeps it permanent if it was.
+func (c *chunkReader) wrapError(err error) error {
+	var permanentErr *backoff.PermanentError
+	if errors.As(err, &permanentErr) {
+		return backoff.Permanent(fmt.Errorf("chunk %d: %w", c.part.index, permanentErr.Err))
+	}
+	return fmt.Errorf("chunk %d: %w", c.part.index, err)
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		remaining := c.part.end - c.offset
+		if remaining <= 0 {
+			return 0, io.EOF
+		}
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+
+		n, err := c.body.Read(p)
+		if n > 0 {
+			c.offset += int64(n)
+			c.failures = 0
+			c.idleTimer.Reset(c.d.idleTimeout)
+			return n, nil
+		}
+		if err == nil {
+			continue
+		}
+
+		// The connection was broken or stalled before the whole chunk was received
+		c.failures++
+		if c.failures > c.d.maxRetries {
+			return 0, fmt.Errorf("chunk %d: failed to read from %s: %w", c.part.index, c.server.Address(), err)
+		}
+		c.d.logger.Warn("Resuming chunk download",
+			slog.String("uuid", c.d.uuid),
+			slog.Int("chunk", c.part.index),
+			slog.String("server", c.server.Address()),
+			slog.Int64("offset", c.offset),
+			slog.Any("error", err))
+		if err := c.open(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (c *chunkReader) Close() {
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+		c.idleTimer = nil
+	}
+	if c.cancelAttempt != nil {
+		c.cancelAttempt()
+		c.cancelAttempt = nil
+	}
+	if c.body != nil {
+		c.body.Close()
+		c.body = nil
 	}
-	return readers, writers
 }
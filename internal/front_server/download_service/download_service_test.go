@@ -2,12 +2,19 @@ package download_service
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"simple-s3-adventure/internal/front_server/registry_service"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/cenkalti/backoff"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -30,10 +37,10 @@ func (suite *DownloadServiceSuite) SetupTest() {
 	}))
 
 	// Create ChunkCopyManager instance
-	chunkServers := []*registry_service.ChunkServer{
-		{Address: suite.server.URL},
+	chunks := []registry_service.ChunkLocation{
+		{Index: 0, Size: int64(len("chunk data")), Server: registry_service.NewChunkServer(suite.server.URL)},
 	}
-	suite.service = NewDownloadService("test-uuid", chunkServers)
+	suite.service = NewDownloadService("test-uuid", chunks, http.DefaultClient)
 }
 
 func (suite *DownloadServiceSuite) TearDownTest() {
@@ -52,3 +59,194 @@ func (suite *DownloadServiceSuite) TestCopyChunksSuccess() {
 func TestChunkCopyManagerSuite(t *testing.T) {
 	suite.Run(t, new(DownloadServiceSuite))
 }
+
+// newTestDownload creates a download of the given chunks served by the handlers, retrying without delay.
+func newTestDownload(t *testing.T, data []string, handlers ...http.HandlerFunc) *DownloadService {
+	chunks := make([]registry_service.ChunkLocation, len(handlers))
+	for i, handler := range handlers {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		chunks[i] = registry_service.ChunkLocation{
+			Index:  i,
+			Size:   int64(len(data[i])),
+			Server: registry_service.NewChunkServer(server.URL),
+		}
+	}
+
+	d := NewDownloadService("test-uuid", chunks, http.DefaultClient)
+	d.maxRetries = 3
+	d.newBackOff = func() backoff.BackOff { return &backoff.ZeroBackOff{} }
+	return d
+}
+
+func serveChunk(data string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(data))
+	}
+}
+
+func TestDownloadService_MultipleChunks(t *testing.T) {
+	data := []string{"first ", "second ", "third"}
+	d := newTestDownload(t, data, serveChunk(data[0]), serveChunk(data[1]), serveChunk(data[2]))
+	assert.Equal(t, int64(18), d.Size())
+
+	var buffer bytes.Buffer
+	n, err := d.CopyChunks(&buffer)
+	require.NoError(t, err)
+	assert.Equal(t, int64(18), n)
+	assert.Equal(t, "first second third", buffer.String())
+}
+
+func TestDownloadService_RetriesServerErrors(t *testing.T) {
+	var requests atomic.Int32
+	flaky := func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		serveChunk("chunk data")(w, r)
+	}
+	d := newTestDownload(t, []string{"chunk data"}, flaky)
+
+	var buffer bytes.Buffer
+	_, err := d.CopyChunks(&buffer)
+	require.NoError(t, err)
+	assert.Equal(t, "chunk data", buffer.String())
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestDownloadService_GivesUpAfterMaxRetries(t *testing.T) {
+	var requests atomic.Int32
+	failing := func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+	d := newTestDownload(t, []string{"chunk data"}, failing)
+
+	err := d.Open(context.Background())
+	assert.ErrorContains(t, err, "received HTTP status 500")
+	assert.Equal(t, int32(4), requests.Load())
+}
+
+func TestDownloadService_NotFoundIsPermanent(t *testing.T) {
+	var requests atomic.Int32
+	notFound := func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "File not found", http.StatusNotFound)
+	}
+	data := []string{"chunk data", "more data"}
+	d := newTestDownload(t, data, serveChunk(data[0]), notFound)
+
+	err := d.Open(context.Background())
+	assert.ErrorContains(t, err, "received HTTP status 404")
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestDownloadService_ContentLengthMismatch(t *testing.T) {
+	d := newTestDownload(t, []string{"chunk data"}, serveChunk("truncated"))
+
+	err := d.Open(context.Background())
+	assert.ErrorContains(t, err, "received Content-Length 9")
+}
+
+func TestDownloadService_ResumesBrokenDownload(t *testing.T) {
+	const data = "0123456789abcdefghij"
+	var mu sync.Mutex
+	var ranges []string
+	var requests atomic.Int32
+	broken := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		if requests.Add(1) == 1 {
+			// Promise the whole chunk but send only a part of it
+			w.Header().Set("Content-Length", "20")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(data[:8]))
+			return
+		}
+		serveChunk(data)(w, r)
+	}
+	d := newTestDownload(t, []string{data}, broken)
+
+	var buffer bytes.Buffer
+	n, err := d.CopyChunks(&buffer)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), n)
+	assert.Equal(t, data, buffer.String())
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"", "bytes=8-"}, ranges)
+}
+
+func TestDownloadService_ResumesStalledDownload(t *testing.T) {
+	const data = "0123456789"
+	var requests atomic.Int32
+	stalled := func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", "10")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(data[:4]))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		serveChunk(data)(w, r)
+	}
+	d := newTestDownload(t, []string{data}, stalled)
+	d.idleTimeout = 50 * time.Millisecond
+
+	var buffer bytes.Buffer
+	_, err := d.CopyChunks(&buffer)
+	require.NoError(t, err)
+	assert.Equal(t, data, buffer.String())
+}
+
+func TestSelectRange(t *testing.T) {
+	parts := []part{
+		{index: 0, size: 10, end: 10},
+		{index: 1, size: 10, end: 10},
+		{index: 2, size: 5, end: 5},
+	}
+
+	tests := []struct {
+		name           string
+		offset, length int64
+		expected       []part
+	}{
+		{"whole file", 0, 25, parts},
+		{"within a chunk", 12, 5, []part{{index: 1, size: 10, start: 2, end: 7}}},
+		{"across chunks", 8, 15, []part{
+			{index: 0, size: 10, start: 8, end: 10},
+			{index: 1, size: 10, start: 0, end: 10},
+			{index: 2, size: 5, start: 0, end: 3},
+		}},
+		{"tail", 20, 5, []part{{index: 2, size: 5, start: 0, end: 5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, selectRange(parts, tt.offset, tt.length))
+		})
+	}
+}
+
+func TestDownloadService_Range(t *testing.T) {
+	data := []string{"first ", "second ", "third"}
+	var ranges [3]string
+	handler := func(i int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ranges[i] = r.Header.Get("Range")
+			serveChunk(data[i])(w, r)
+		}
+	}
+	d := newTestDownload(t, data, handler(0), handler(1), handler(2))
+	d.parts = selectRange(d.parts, 2, 13)
+	assert.Equal(t, int64(13), d.Size())
+
+	var buffer bytes.Buffer
+	n, err := d.CopyChunks(&buffer)
+	require.NoError(t, err)
+	assert.Equal(t, int64(13), n)
+	assert.Equal(t, "rst second th", buffer.String())
+	assert.Equal(t, [3]string{"bytes=2-", "", "bytes=0-1"}, ranges)
+}
@@ -0,0 +1,92 @@
+package download_service
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// latencySamples is the number of recent chunk requests the hedging delay is computed from
+	latencySamples = 1000
+	// minLatencySamples is the number of samples required before the percentile is trusted
+	minLatencySamples = 20
+)
+
+// HedgeConfig configures hedged chunk requests.
+type HedgeConfig struct {
+	// Percentile of the time to first byte of recent chunk requests after which a second replica is requested
+	Percentile float64
+	// MinDelay and MaxDelay bound the hedging delay. MaxDelay is used until enough requests have been observed.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// HedgeStats is a snapshot of the hedging counters.
+type HedgeStats struct {
+	// Requests is the number of chunk requests that could be hedged, i.e. had more than one replica
+	Requests int64 `json:"requests"`
+	// Hedged is the number of requests that were sent to a second replica
+	Hedged int64 `json:"hedged"`
+	// HedgeWins is the number of hedged requests where the second replica answered first
+	HedgeWins int64 `json:"hedge_wins"`
+}
+
+// HedgePolicy decides when a chunk request is hedged. It is shared by all downloads,
+// so the delay adapts to the latency of the whole cluster.
+type HedgePolicy struct {
+	config HedgeConfig
+
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+
+	requests  atomic.Int64
+	hedged    atomic.Int64
+	hedgeWins atomic.Int64
+}
+
+func NewHedgePolicy(config HedgeConfig) *HedgePolicy {
+	return &HedgePolicy{
+		config:  config,
+		samples: make([]time.Duration, 0, latencySamples),
+	}
+}
+
+// Delay returns how long to wait for the first replica before requesting the second one.
+func (h *HedgePolicy) Delay() time.Duration {
+	h.mu.Lock()
+	if len(h.samples) < minLatencySamples {
+		h.mu.Unlock()
+		return h.config.MaxDelay
+	}
+	sorted := slices.Clone(h.samples)
+	h.mu.Unlock()
+
+	slices.Sort(sorted)
+	rank := int(h.config.Percentile / 100 * float64(len(sorted)-1))
+	rank = min(max(rank, 0), len(sorted)-1)
+	return min(max(sorted[rank], h.config.MinDelay), h.config.MaxDelay)
+}
+
+// Observe records the time to first byte of a chunk request.
+func (h *HedgePolicy) Observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.samples) < latencySamples {
+		h.samples = append(h.samples, latency)
+		return
+	}
+	h.samples[h.next] = latency
+	h.next = (h.next + 1) % latencySamples
+}
+
+func (h *HedgePolicy) Stats() HedgeStats {
+	return HedgeStats{
+		Requests:  h.requests.Load(),
+		Hedged:    h.hedged.Load(),
+		HedgeWins: h.hedgeWins.Load(),
+	}
+}
@@ -0,0 +1,104 @@
+package download_service
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+
+	"github.com/cenkalti/backoff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedgePolicy_Delay(t *testing.T) {
+	h := NewHedgePolicy(HedgeConfig{Percentile: 90, MinDelay: 5 * time.Millisecond, MaxDelay: time.Second})
+
+	// Not enough samples yet
+	h.Observe(time.Millisecond)
+	assert.Equal(t, time.Second, h.Delay())
+
+	for i := 1; i <= 100; i++ {
+		h.Observe(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, 90*time.Millisecond, h.Delay())
+
+	// The delay is bounded
+	for i := 0; i < latencySamples; i++ {
+		h.Observe(time.Microsecond)
+	}
+	assert.Equal(t, 5*time.Millisecond, h.Delay())
+}
+
+// newReplicatedDownload creates a download of a single chunk stored on replicas served by the handlers.
+func newReplicatedDownload(t *testing.T, data string, opts []DownloadOption, handlers ...http.HandlerFunc) *DownloadService {
+	var chunks []registry_service.ChunkLocation
+	for _, handler := range handlers {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		chunks = append(chunks, registry_service.ChunkLocation{
+			Size:   int64(len(data)),
+			Server: registry_service.NewChunkServer(server.URL),
+		})
+	}
+
+	d := NewDownloadService("test-uuid", chunks, http.DefaultClient, opts...)
+	d.maxRetries = 3
+	d.newBackOff = func() backoff.BackOff { return &backoff.ZeroBackOff{} }
+	return d
+}
+
+func TestDownloadService_HedgesSlowReplica(t *testing.T) {
+	const data = "chunk data"
+	cancelled := make(chan struct{})
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+			serveChunk(data)(w, r)
+		}
+	}
+	policy := NewHedgePolicy(HedgeConfig{Percentile: 95, MaxDelay: 20 * time.Millisecond})
+	d := newReplicatedDownload(t, data, []DownloadOption{WithHedging(policy)}, slow, serveChunk(data))
+
+	var buffer bytes.Buffer
+	_, err := d.CopyChunks(&buffer)
+	require.NoError(t, err)
+	assert.Equal(t, data, buffer.String())
+	assert.Equal(t, HedgeStats{Requests: 1, Hedged: 1, HedgeWins: 1}, policy.Stats())
+
+	// The slow request is cancelled
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow request was not cancelled")
+	}
+}
+
+func TestDownloadService_FastReplicaIsNotHedged(t *testing.T) {
+	const data = "chunk data"
+	policy := NewHedgePolicy(HedgeConfig{Percentile: 95, MaxDelay: time.Second})
+	d := newReplicatedDownload(t, data, []DownloadOption{WithHedging(policy)}, serveChunk(data), serveChunk(data))
+
+	var buffer bytes.Buffer
+	_, err := d.CopyChunks(&buffer)
+	require.NoError(t, err)
+	assert.Equal(t, HedgeStats{Requests: 1}, policy.Stats())
+}
+
+func TestDownloadService_FailsOverToReplica(t *testing.T) {
+	const data = "chunk data"
+	missing := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "File not found", http.StatusNotFound)
+	}
+	d := newReplicatedDownload(t, data, nil, missing, serveChunk(data))
+
+	var buffer bytes.Buffer
+	_, err := d.CopyChunks(&buffer)
+	require.NoError(t, err)
+	assert.Equal(t, data, buffer.String())
+}
@@ -0,0 +1,121 @@
+package download_service
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+)
+
+// readAheadBlockSize is the size of a single prefetched block
+const readAheadBlockSize = 64 << 10 // 64 KB
+
+// WithReadAhead prefetches upcoming chunks into memory while the client is reading,
+// so chunk servers are released without waiting for a slow client. A chunk is only requested
+// when the read-ahead reaches it, so later chunks don't keep connections open meanwhile.
+// The memory used by a download is limited by budget. At least two blocks are used,
+// one is being filled from a chunk server and another one is being written to the client.
+func WithReadAhead(budget int64) DownloadOption {
+	return func(d *DownloadService) {
+		d.readAheadBudget = budget
+	}
+}
+
+// eagerParts returns the number of parts requested by Open: all of them without read-ahead, otherwise
+// the parts starting within the budget, and at least the first one.
+func (d *DownloadService) eagerParts() int {
+	if d.readAheadBudget <= 0 {
+		return len(d.parts)
+	}
+	var offset int64
+	for i, part := range d.parts {
+		if i > 0 && offset >= d.readAheadBudget {
+			return i
+		}
+		offset += part.end - part.start
+	}
+	return len(d.parts)
+}
+
+// writeToReadAhead copies the chunks to w through a bounded queue of blocks filled by a background goroutine.
+func (d *DownloadService) writeToReadAhead(w io.Writer) (int64, error) {
+	blocks := make(chan []byte, max(d.readAheadBudget/readAheadBlockSize-2, 0))
+	free := make(chan []byte, cap(blocks)+2)
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+
+	go func() {
+		defer close(blocks)
+		done <- d.prefetch(blocks, free, stop)
+	}()
+
+	var size int64
+	var writeErr error
+	for block := range blocks {
+		n, err := w.Write(block)
+		size += int64(n)
+		if err != nil {
+			writeErr = err
+			break
+		}
+		// Return the block for reuse, the number of blocks never exceeds the budget
+		select {
+		case free <- block[:cap(block)]:
+		default:
+		}
+	}
+
+	if writeErr != nil {
+		// The client is gone, stop prefetching and wait for the goroutine to exit
+		close(stop)
+		d.cancel()
+		for range blocks {
+		}
+		<-done
+		d.logger.Error("Error copying chunks", slog.String("uuid", d.uuid), slog.Any("error", writeErr))
+		return size, writeErr
+	}
+
+	if err := <-done; err != nil {
+		d.logger.Error("Error copying chunks", slog.String("uuid", d.uuid), slog.Any("error", err))
+		return size, err
+	}
+	return size, nil
+}
+
+// prefetch reads the chunks in order and sends them to blocks until all chunks are read or stop is closed.
+func (d *DownloadService) prefetch(blocks chan<- []byte, free <-chan []byte, stop <-chan struct{}) error {
+	for i, reader := range d.readers {
+		if i >= d.opened {
+			// The read-ahead reached the chunk
+			if err := reader.open(); err != nil {
+				return err
+			}
+		}
+		for {
+			var block []byte
+			select {
+			case block = <-free:
+			default:
+				block = make([]byte, readAheadBlockSize)
+			}
+
+			n, err := io.ReadFull(reader, block)
+			if n > 0 {
+				select {
+				case blocks <- block[:n]:
+				case <-stop:
+					return nil
+				}
+			}
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				// The chunk is fully read, release the chunk server
+				reader.Close()
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package download_service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomChunks(n int, size int) []string {
+	rnd := rand.New(rand.NewSource(1))
+	data := make([]string, n)
+	for i := range data {
+		chunk := make([]byte, size)
+		rnd.Read(chunk)
+		data[i] = string(chunk)
+	}
+	return data
+}
+
+func TestDownloadService_ReadAhead(t *testing.T) {
+	data := randomChunks(3, 200_000)
+	d := newTestDownload(t, data, serveChunk(data[0]), serveChunk(data[1]), serveChunk(data[2]))
+	WithReadAhead(2 * readAheadBlockSize)(d)
+
+	var buffer bytes.Buffer
+	n, err := d.CopyChunks(&buffer)
+	require.NoError(t, err)
+	assert.Equal(t, int64(600_000), n)
+	assert.Equal(t, data[0]+data[1]+data[2], buffer.String())
+}
+
+// blockingWriter blocks the first write until released.
+type blockingWriter struct {
+	bytes.Buffer
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { <-w.release })
+	return w.Buffer.Write(p)
+}
+
+func TestDownloadService_ReadAheadReleasesChunkServers(t *testing.T) {
+	data := randomChunks(3, 100_000)
+	var served sync.WaitGroup
+	served.Add(len(data))
+	handler := func(chunk string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			defer served.Done()
+			serveChunk(chunk)(w, r)
+		}
+	}
+	d := newTestDownload(t, data, handler(data[0]), handler(data[1]), handler(data[2]))
+	WithReadAhead(1 << 20)(d)
+	require.NoError(t, d.Open(context.Background()))
+	defer d.Close()
+
+	w := &blockingWriter{release: make(chan struct{})}
+	result := make(chan error)
+	go func() {
+		_, err := d.WriteTo(w)
+		result <- err
+	}()
+
+	// All chunk servers finish while the client has not read anything
+	allServed := make(chan struct{})
+	go func() {
+		served.Wait()
+		close(allServed)
+	}()
+	select {
+	case <-allServed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("chunk servers are still busy with a slow client")
+	}
+
+	close(w.release)
+	require.NoError(t, <-result)
+	assert.Equal(t, data[0]+data[1]+data[2], w.String())
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("client disconnected")
+}
+
+func TestDownloadService_ReadAheadWriteError(t *testing.T) {
+	data := randomChunks(2, 300_000)
+	d := newTestDownload(t, data, serveChunk(data[0]), serveChunk(data[1]))
+	WithReadAhead(2 * readAheadBlockSize)(d)
+	require.NoError(t, d.Open(context.Background()))
+	defer d.Close()
+
+	_, err := d.WriteTo(failingWriter{})
+	assert.EqualError(t, err, "client disconnected")
+}
+
+func TestDownloadService_ReadAheadRequestsChunksLazily(t *testing.T) {
+	data := randomChunks(4, 100_000)
+	var requested [4]atomic.Int32
+	handler := func(i int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			requested[i].Add(1)
+			serveChunk(data[i])(w, r)
+		}
+	}
+	d := newTestDownload(t, data, handler(0), handler(1), handler(2), handler(3))
+	// The budget is smaller than the file
+	WithReadAhead(2 * readAheadBlockSize)(d)
+	require.NoError(t, d.Open(context.Background()))
+	defer d.Close()
+	// Only the chunks starting within the budget are requested up front
+	assert.Equal(t, []int32{1, 1, 0, 0}, requestCounts(requested[:]))
+
+	w := &blockingWriter{release: make(chan struct{})}
+	result := make(chan error)
+	go func() {
+		_, err := d.WriteTo(w)
+		result <- err
+	}()
+
+	// The read-ahead doesn't reach the last chunks while the client is stuck
+	assert.Never(t, func() bool {
+		return requested[2].Load() > 0 || requested[3].Load() > 0
+	}, 200*time.Millisecond, 10*time.Millisecond)
+
+	close(w.release)
+	require.NoError(t, <-result)
+	assert.Equal(t, data[0]+data[1]+data[2]+data[3], w.String())
+	assert.Equal(t, []int32{1, 1, 1, 1}, requestCounts(requested[:]))
+}
+
+func TestDownloadService_ReadAheadLazyChunkFails(t *testing.T) {
+	data := randomChunks(3, 100_000)
+	d := newTestDownload(t, data, serveChunk(data[0]), serveChunk(data[1]), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	WithReadAhead(2 * readAheadBlockSize)(d)
+	require.NoError(t, d.Open(context.Background()))
+	defer d.Close()
+
+	var buffer bytes.Buffer
+	n, err := d.WriteTo(&buffer)
+	assert.ErrorContains(t, err, "chunk 2")
+	assert.Equal(t, int64(200_000), n)
+}
+
+func requestCounts(requested []atomic.Int32) []int32 {
+	counts := make([]int32, len(requested))
+	for i := range requested {
+		counts[i] = requested[i].Load()
+	}
+	return counts
+}
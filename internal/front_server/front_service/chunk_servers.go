@@ -0,0 +1,34 @@
+package front_service
+
+// ChunkServerInfo describes a registered chunk server.
+type ChunkServerInfo struct {
+	ID          string `json:"id,omitempty"`
+	Address     string `json:"address"`
+	GRPCAddress string `json:"grpc_address,omitempty"`
+	State       string `json:"state"`
+	Mode        string `json:"mode"`
+	Offline     bool   `json:"offline"`
+	Bytes       int64  `json:"bytes"`
+	Chunks      int    `json:"chunks"`
+}
+
+// ListChunkServers returns all registered chunk servers in round-robin order.
+func (s *FrontService) ListChunkServers() []ChunkServerInfo {
+	counts := s.allocationMap.ChunkCounts()
+	servers := s.registry.ChunkServers()
+
+	infos := make([]ChunkServerInfo, len(servers))
+	for i, server := range servers {
+		infos[i] = ChunkServerInfo{
+			ID:          server.ID(),
+			Address:     server.Address(),
+			GRPCAddress: server.GRPCAddress(),
+			State:       server.State().String(),
+			Mode:        string(server.Mode()),
+			Offline:     server.Offline(),
+			Bytes:       server.Size(),
+			Chunks:      counts[server],
+		}
+	}
+	return infos
+}
@@ -0,0 +1,29 @@
+package front_service
+
+import (
+	"testing"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListChunkServers(t *testing.T) {
+	registry := registry_service.NewChunkServerRegistry()
+	allocationMap := registry_service.NewChunkAllocationMap()
+	require.NoError(t, registry.AddChunkServer("http://chunkserver1"))
+	require.NoError(t, registry.AddChunkServer("http://chunkserver2"))
+
+	servers := registry.ChunkServers()
+	allocationMap.AddChunk("file1", servers, []int64{10, 20})
+	registry.AdjustSizes(servers, []int64{10, 20}, 30)
+	_, err := registry.DrainChunkServer("http://chunkserver2")
+	require.NoError(t, err)
+
+	service := NewFrontService(registry, allocationMap)
+	assert.Equal(t, []ChunkServerInfo{
+		{Address: "http://chunkserver1", State: "active", Mode: "read_write", Bytes: 10, Chunks: 1},
+		{Address: "http://chunkserver2", State: "draining", Mode: "read_write", Bytes: 20, Chunks: 1},
+	}, service.ListChunkServers())
+}
@@ -0,0 +1,37 @@
+package front_service
+
+import (
+	"context"
+	"log/slog"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+	"simple-s3-adventure/internal/front_server/upload_service"
+)
+
+// DeleteFile removes the file and deletes its chunks from chunk servers.
+// The file is gone as soon as it is removed from the allocation map, so chunks that could not be deleted
+// are only logged: they just waste space on chunk servers.
+func (s *FrontService) DeleteFile(ctx context.Context, uuid string) error {
+	locations := s.allocationMap.RemoveFile(uuid)
+	if locations == nil {
+		return ErrFileNotFound
+	}
+
+	servers := make([]*registry_service.ChunkServer, len(locations))
+	sizes := make([]int64, len(locations))
+	chunks := make([]*upload_service.Chunk, len(locations))
+	var totalSize int64
+	for i, location := range locations {
+		servers[i] = location.Server
+		sizes[i] = -location.Size
+		chunks[i] = &upload_service.Chunk{Index: location.Index, Size: location.Size, Server: location.Server}
+		totalSize += location.Size
+	}
+	s.registry.AdjustSizes(servers, sizes, -totalSize)
+
+	uploadService := upload_service.NewUploadService(s.httpClient, s.registry, s.allocationMap, upload_service.WithTransport(s.transport))
+	if err := uploadService.DeleteFileChunks(ctx, uuid, chunks); err != nil {
+		s.logger.Warn("Failed to delete file chunks", slog.String("file_id", uuid), slog.Any("error", err))
+	}
+	return nil
+}
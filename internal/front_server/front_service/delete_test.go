@@ -0,0 +1,41 @@
+package front_service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteFile(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	chunkServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		deleted = append(deleted, r.Method+" "+r.URL.RequestURI())
+	}))
+	defer chunkServer.Close()
+
+	registry := registry_service.NewChunkServerRegistry()
+	server := registry_service.NewChunkServer(chunkServer.URL)
+	allocationMap := registry_service.NewChunkAllocationMap()
+	allocationMap.AddChunk("file1", []*registry_service.ChunkServer{server}, []int64{10})
+	registry.AdjustSizes([]*registry_service.ChunkServer{server}, []int64{10}, 10)
+
+	service := NewFrontService(registry, allocationMap)
+	require.NoError(t, service.DeleteFile(context.Background(), "file1"))
+
+	assert.Equal(t, []string{"DELETE /delete?index=0&uuid=file1"}, deleted)
+	assert.Nil(t, allocationMap.GetChunks("file1"))
+	assert.Equal(t, int64(0), server.Size())
+	assert.Equal(t, int64(0), registry.TotalSize())
+
+	assert.ErrorIs(t, service.DeleteFile(context.Background(), "file1"), ErrFileNotFound)
+}
@@ -1,12 +1,51 @@
 package front_service
 
 import (
+	"context"
 	"io"
 	"simple-s3-adventure/internal/front_server/download_service"
+	"simple-s3-adventure/internal/front_server/registry_service"
 )
 
+var ErrFileNotFound = registry_service.ErrFileNotFound
+
+// OpenDownload requests all chunks of the file from chunk servers. The caller must close the returned download.
+func (s *FrontService) OpenDownload(ctx context.Context, uuid string) (*download_service.DownloadService, error) {
+	return s.openDownload(ctx, uuid, nil)
+}
+
+// OpenRange requests length bytes of the file starting at offset from chunk servers, see OpenDownload.
+// The range must be within the file.
+func (s *FrontService) OpenRange(ctx context.Context, uuid string, offset, length int64) (*download_service.DownloadService, error) {
+	return s.openDownload(ctx, uuid, []download_service.DownloadOption{download_service.WithRange(offset, length)})
+}
+
+func (s *FrontService) openDownload(ctx context.Context, uuid string, opts []download_service.DownloadOption) (*download_service.DownloadService, error) {
+	chunks := s.allocationMap.GetChunks(uuid)
+	if chunks == nil {
+		return nil, ErrFileNotFound
+	}
+
+	opts = append(opts, download_service.WithTransport(s.transport))
+	if s.hedgePolicy != nil {
+		opts = append(opts, download_service.WithHedging(s.hedgePolicy))
+	}
+	if s.readAhead > 0 {
+		opts = append(opts, download_service.WithReadAhead(s.readAhead))
+	}
+	download := download_service.NewDownloadService(uuid, chunks, s.httpClient, opts...)
+	if err := download.Open(ctx); err != nil {
+		return nil, err
+	}
+	return download, nil
+}
+
 func (s *FrontService) CopyChunks(uuid string, w io.Writer) (int64, error) {
-	chunkServers := s.allocationMap.GetChunkServers(uuid)
-	srv := download_service.NewDownloadService(uuid, chunkServers)
-	return srv.CopyChunks(w)
+	download, err := s.OpenDownload(context.Background(), uuid)
+	if err != nil {
+		return 0, err
+	}
+	defer download.Close()
+
+	return download.WriteTo(w)
 }
@@ -2,6 +2,7 @@ package front_service_test
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -32,8 +33,8 @@ func (suite *FrontServiceSuite) SetupTest() {
 	// Create a mock registry and allocation map
 	registry := &registry_service.ChunkServerRegistry{}
 	allocationMap := registry_service.NewChunkAllocationMap()
-	chunkServer := registry_service.ChunkServer{Address: suite.server.URL}
-	allocationMap.AddChunk("test-uuid", []*registry_service.ChunkServer{&chunkServer})
+	chunkServer := registry_service.NewChunkServer(suite.server.URL)
+	allocationMap.AddChunk("test-uuid", []*registry_service.ChunkServer{chunkServer}, []int64{int64(len("chunk data"))})
 	suite.fs = front_service.NewFrontService(registry, allocationMap)
 }
 
@@ -50,6 +51,12 @@ func (suite *FrontServiceSuite) TestCopyChunks() {
 	assert.Equal(suite.T(), "chunk data", buffer.String())
 }
 
+func (suite *FrontServiceSuite) TestOpenDownloadUnknownFile() {
+	_, err := suite.fs.OpenDownload(context.Background(), "unknown-uuid")
+
+	assert.ErrorIs(suite.T(), err, front_service.ErrFileNotFound)
+}
+
 func TestFrontServiceSuite(t *testing.T) {
 	suite.Run(t, new(FrontServiceSuite))
 }
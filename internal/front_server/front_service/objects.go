@@ -0,0 +1,87 @@
+package front_service
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// ChunkLayout describes where the replicas of a chunk are stored.
+type ChunkLayout struct {
+	Index   int      `json:"index"`
+	Size    int64    `json:"size"`
+	Servers []string `json:"servers"`
+}
+
+// ObjectLayout describes how a file is split into chunks.
+type ObjectLayout struct {
+	UUID   string        `json:"uuid"`
+	Size   int64         `json:"size"`
+	Chunks []ChunkLayout `json:"chunks"`
+}
+
+// ObjectLayout returns the chunks of the file and the chunk servers storing them.
+func (s *FrontService) ObjectLayout(uuid string) (ObjectLayout, error) {
+	locations := s.allocationMap.GetChunks(uuid)
+	if locations == nil {
+		return ObjectLayout{}, ErrFileNotFound
+	}
+
+	layout := ObjectLayout{UUID: uuid}
+	for _, location := range locations {
+		if len(layout.Chunks) == 0 || layout.Chunks[len(layout.Chunks)-1].Index != location.Index {
+			layout.Chunks = append(layout.Chunks, ChunkLayout{Index: location.Index, Size: location.Size})
+			layout.Size += location.Size
+		}
+		chunk := &layout.Chunks[len(layout.Chunks)-1]
+		chunk.Servers = append(chunk.Servers, location.Server.Address())
+	}
+	return layout, nil
+}
+
+// ObjectInfo describes a stored file.
+type ObjectInfo struct {
+	UUID      string    `json:"uuid"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Stat returns the metadata of the file.
+func (s *FrontService) Stat(uuid string) (ObjectInfo, error) {
+	info, ok := s.allocationMap.GetFileInfo(uuid)
+	if !ok {
+		return ObjectInfo{}, ErrFileNotFound
+	}
+
+	var size int64
+	lastIndex := -1
+	for _, location := range s.allocationMap.GetChunks(uuid) {
+		if location.Index != lastIndex {
+			size += location.Size
+			lastIndex = location.Index
+		}
+	}
+	return ObjectInfo{UUID: uuid, Name: info.Name, Size: size, CreatedAt: info.CreatedAt}, nil
+}
+
+// List returns the files whose name starts with prefix, ordered by name.
+func (s *FrontService) List(prefix string) []ObjectInfo {
+	objects := []ObjectInfo{}
+	for _, uuid := range s.allocationMap.FileUUIDs() {
+		object, err := s.Stat(uuid)
+		// The file might have been deleted in the meantime
+		if err != nil || !strings.HasPrefix(object.Name, prefix) {
+			continue
+		}
+		objects = append(objects, object)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].Name != objects[j].Name {
+			return objects[i].Name < objects[j].Name
+		}
+		return objects[i].UUID < objects[j].UUID
+	})
+	return objects
+}
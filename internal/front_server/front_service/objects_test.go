@@ -0,0 +1,66 @@
+package front_service
+
+import (
+	"testing"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectLayout(t *testing.T) {
+	allocationMap := registry_service.NewChunkAllocationMap()
+	server1 := registry_service.NewChunkServer("http://chunkserver1")
+	server2 := registry_service.NewChunkServer("http://chunkserver2")
+	server3 := registry_service.NewChunkServer("http://chunkserver3")
+	allocationMap.AddChunkReplicas("file1", [][]*registry_service.ChunkServer{{server1, server2}, {server3, server1}}, []int64{10, 5})
+
+	service := NewFrontService(registry_service.NewChunkServerRegistry(), allocationMap)
+	layout, err := service.ObjectLayout("file1")
+	require.NoError(t, err)
+	assert.Equal(t, ObjectLayout{
+		UUID: "file1",
+		Size: 15,
+		Chunks: []ChunkLayout{
+			{Index: 0, Size: 10, Servers: []string{"http://chunkserver1", "http://chunkserver2"}},
+			{Index: 1, Size: 5, Servers: []string{"http://chunkserver3", "http://chunkserver1"}},
+		},
+	}, layout)
+
+	_, err = service.ObjectLayout("file2")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+}
+
+func TestStatAndList(t *testing.T) {
+	allocationMap := registry_service.NewChunkAllocationMap()
+	server1 := registry_service.NewChunkServer("http://chunkserver1")
+	server2 := registry_service.NewChunkServer("http://chunkserver2")
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	allocationMap.AddFile("file1", registry_service.FileInfo{Name: "docs/b.txt", CreatedAt: createdAt},
+		[][]*registry_service.ChunkServer{{server1, server2}, {server2, server1}}, []int64{10, 5})
+	allocationMap.AddFile("file2", registry_service.FileInfo{Name: "docs/a.txt", CreatedAt: createdAt},
+		[][]*registry_service.ChunkServer{{server1}}, []int64{7})
+	allocationMap.AddFile("file3", registry_service.FileInfo{Name: "images/c.png", CreatedAt: createdAt},
+		[][]*registry_service.ChunkServer{{server2}}, []int64{3})
+
+	service := NewFrontService(registry_service.NewChunkServerRegistry(), allocationMap)
+	info, err := service.Stat("file1")
+	require.NoError(t, err)
+	assert.Equal(t, ObjectInfo{UUID: "file1", Name: "docs/b.txt", Size: 15, CreatedAt: createdAt}, info)
+
+	_, err = service.Stat("file4")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	names := func(objects []ObjectInfo) []string {
+		var names []string
+		for _, object := range objects {
+			names = append(names, object.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"docs/a.txt", "docs/b.txt", "images/c.png"}, names(service.List("")))
+	assert.Equal(t, []string{"docs/a.txt", "docs/b.txt"}, names(service.List("docs/")))
+	assert.Empty(t, service.List("videos/"))
+}
@@ -5,15 +5,20 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"simple-s3-adventure/internal/front_server/chunker"
+	"simple-s3-adventure/internal/front_server/metrics"
+	"simple-s3-adventure/internal/front_server/registry_service"
 	"simple-s3-adventure/internal/front_server/upload_service"
 	"simple-s3-adventure/pkg/logger"
 
 	"github.com/google/uuid"
 )
 
-func (s *FrontService) UploadFile(r *http.Request, maxUploadSize int64, numParts int) (string, error) {
+// UploadFile splits the file into numParts chunks and stores every chunk on replicationFactor different chunk servers.
+// The file is named by the "name" form field, or by the name of the uploaded file if the field is empty.
+func (s *FrontService) UploadFile(r *http.Request, maxUploadSize int64, numParts int, replicationFactor int) (string, error) {
 	fileUUID := uuid.New().String()
 
 	err := r.ParseMultipartForm(maxUploadSize)
@@ -27,18 +32,28 @@ func (s *FrontService) UploadFile(r *http.Request, maxUploadSize int64, numParts
 	}
 	defer file.Close()
 
+	name := r.FormValue("name")
+	if name == "" {
+		name = header.Filename
+	}
+
 	lg := logger.GetLogger()
-	lg.Info("File uploading", slog.String("file_id", fileUUID), slog.Int64("file_size", header.Size))
+	lg.Info("File uploading", slog.String("file_id", fileUUID), slog.String("name", name), slog.Int64("file_size", header.Size))
 
 	offsets := chunker.ChunkOffsets(header.Size, numParts)
-	servers := s.registry.SelectUnderloadedChunkServers(numParts)
-	if len(servers) != numParts {
+	// A chunk server stores chunks by file UUID, so every replica of every chunk needs its own server
+	servers := s.registry.SelectUnderloadedChunkServers(numParts * replicationFactor)
+	if len(servers) != numParts*replicationFactor {
 		return "", fmt.Errorf("not enough chunk servers available")
 	}
 
-	uploadService := upload_service.NewUploadService(s.httpClient, s.registry, s.allocationMap)
+	uploadService := upload_service.NewUploadService(s.httpClient, s.registry, s.allocationMap, upload_service.WithTransport(s.transport))
 
-	chunks := upload_service.CreateChunks(header.Size, offsets, servers)
+	var chunks []*upload_service.Chunk
+	for replica := 0; replica < replicationFactor; replica++ {
+		replicaServers := servers[replica*numParts : (replica+1)*numParts]
+		chunks = append(chunks, upload_service.CreateChunks(header.Size, offsets, replicaServers)...)
+	}
 
 	ctx := context.Background()
 	if err := uploadService.ProcessFileChunks(ctx, file, fileUUID, chunks); err != nil {
@@ -54,14 +69,20 @@ func (s *FrontService) UploadFile(r *http.Request, maxUploadSize int64, numParts
 		return "", fmt.Errorf("failed to process chunk: %w", err)
 	}
 
-	s.allocationMap.AddChunk(fileUUID, servers)
+	chunkSizes := make([]int64, numParts)
+	replicas := make([][]*registry_service.ChunkServer, numParts)
+	incSizes := make([]int64, len(chunks))
+	for i, chunk := range chunks {
+		chunkSizes[chunk.Index] = chunk.Size
+		replicas[chunk.Index] = append(replicas[chunk.Index], chunk.Server)
+		incSizes[i] = chunk.Size
+	}
+	info := registry_service.FileInfo{Name: name, CreatedAt: time.Now().UTC()}
+	s.allocationMap.AddFile(fileUUID, info, replicas, chunkSizes)
 
 	// Update the size of the chunk servers
-	incSizes := make([]int64, len(servers))
-	for i := range servers {
-		incSizes[i] = upload_service.CalculateChunkSize(header.Size, offsets, i)
-	}
-	s.registry.AdjustSizes(servers, incSizes, header.Size)
+	s.registry.AdjustSizes(servers, incSizes, header.Size*int64(replicationFactor))
+	metrics.UploadedBytes.Add(float64(header.Size))
 
 	return fileUUID, nil
 }
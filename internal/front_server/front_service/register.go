@@ -1,26 +1,183 @@
 package front_service
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 	"net/url"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/chunk_transport"
+	"simple-s3-adventure/internal/front_server/registry_service"
+	"simple-s3-adventure/pkg/cluster"
+	"simple-s3-adventure/pkg/uuid"
+)
+
+const (
+	heartbeatTimeout = 5 * time.Second
+	handshakeTimeout = 5 * time.Second
 )
 
-func (s *FrontService) RegisterChunkServer(serverURL string) error {
+var (
+	// ErrChunkServerUnreachable is returned when a registering chunk server doesn't answer at its address.
+	ErrChunkServerUnreachable = errors.New("chunk server is not reachable")
+	// ErrUnsupportedProtocol is returned when a registering chunk server speaks another protocol version.
+	ErrUnsupportedProtocol = errors.New("unsupported protocol version")
+	// ErrNodeIDMismatch is returned when a registering chunk server reports another node ID than it registers with.
+	ErrNodeIDMismatch = errors.New("node ID mismatch")
+)
+
+// RegisterChunkServer adds the chunk server to the registry. A chunk server with a known node ID
+// is the same node coming back, possibly at a new address, and keeps its data.
+// The chunk server must answer a handshake at its URL with the same node ID and the protocol version of the
+// front server. If chunks are moved over gRPC, it must also provide its gRPC address and answer a heartbeat there.
+// The mode reported by the chunk server, read-write if it is empty, tells whether chunks are placed on it.
+func (s *FrontService) RegisterChunkServer(ctx context.Context, serverURL string, nodeID string, grpcAddress string, mode string) (reregistered bool, err error) {
 	if serverURL == "" {
-		return errors.New("URL not provided")
+		return false, errors.New("URL not provided")
 	}
 
 	parsedURL, err := url.ParseRequestURI(serverURL)
 	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
-		return errors.New("invalid URL")
+		return false, errors.New("invalid URL")
+	}
+
+	if nodeID != "" {
+		if err := uuid.Validate(nodeID); err != nil {
+			return false, errors.New("invalid node ID")
+		}
+	}
+
+	if grpcAddress != "" {
+		if _, _, err := net.SplitHostPort(grpcAddress); err != nil {
+			return false, errors.New("invalid gRPC address")
+		}
+	}
+
+	serverMode, err := registry_service.ParseChunkServerMode(mode)
+	if err != nil {
+		return false, err
+	}
+	if err := s.handshake(ctx, serverURL, nodeID); err != nil {
+		return false, err
 	}
+	if heartbeater, ok := s.transport.(chunk_transport.Heartbeater); ok {
+		if err := s.checkHeartbeat(ctx, heartbeater, serverURL, nodeID, grpcAddress); err != nil {
+			return false, err
+		}
+	}
+
+	s.logger.Info("Registering chunk server",
+		slog.String("url", serverURL),
+		slog.String("node_id", nodeID),
+		slog.String("grpc_address", grpcAddress),
+		slog.String("mode", string(serverMode)))
+	server, reregistered, err := s.registry.RegisterChunkServer(nodeID, serverURL)
+	if err != nil {
+		return false, err
+	}
+	server.SetGRPCAddress(grpcAddress)
+	s.registry.SetMode(server, serverMode)
+	if reregistered {
+		s.logger.Info("Chunk server re-registered", slog.String("url", serverURL), slog.String("node_id", nodeID))
+	}
+
+	return reregistered, nil
+}
 
-	s.logger.Info("Registering chunk server", slog.String("url", serverURL))
-	err = s.registry.AddChunkServer(serverURL)
+// DeregisterChunkServer marks the chunk server offline when it shuts down. Its chunks stay in the allocation
+// map, so a chunk server coming back with its data doesn't need to be rebalanced.
+func (s *FrontService) DeregisterChunkServer(serverURL string, nodeID string) error {
+	if _, err := s.registry.DeregisterChunkServer(nodeID, serverURL); err != nil {
+		return err
+	}
+	s.logger.Info("Chunk server deregistered", slog.String("url", serverURL), slog.String("node_id", nodeID))
+	return nil
+}
+
+// SetChunkServerMode records the mode reported by the chunk server. Chunks are only placed on read-write servers.
+func (s *FrontService) SetChunkServerMode(serverURL string, mode string) error {
+	if mode == "" {
+		return fmt.Errorf("%w %q", registry_service.ErrInvalidChunkServerMode, mode)
+	}
+	serverMode, err := registry_service.ParseChunkServerMode(mode)
 	if err != nil {
 		return err
 	}
+	server, err := s.registry.GetChunkServer(serverURL)
+	if err != nil {
+		return err
+	}
+	if server.Mode() != serverMode {
+		s.logger.Info("Chunk server mode changed",
+			slog.String("url", serverURL),
+			slog.String("from", string(server.Mode())),
+			slog.String("to", string(serverMode)))
+	}
+	s.registry.SetMode(server, serverMode)
+	return nil
+}
+
+// checkHeartbeat verifies that the chunk server is reachable at its gRPC address and reports the same node ID.
+func (s *FrontService) checkHeartbeat(ctx context.Context, heartbeater chunk_transport.Heartbeater, serverURL string, nodeID string, grpcAddress string) error {
+	if grpcAddress == "" {
+		return errors.New("gRPC address not provided")
+	}
+
+	server := registry_service.NewChunkServer(serverURL)
+	server.SetGRPCAddress(grpcAddress)
+
+	ctx, cancel := context.WithTimeout(ctx, heartbeatTimeout)
+	defer cancel()
+
+	heartbeat, err := heartbeater.Heartbeat(ctx, server)
+	if err != nil {
+		s.logger.Error("Chunk server heartbeat failed", slog.String("url", serverURL), slog.String("grpc_address", grpcAddress), slog.Any("error", err))
+		return fmt.Errorf("%w at %s", ErrChunkServerUnreachable, grpcAddress)
+	}
+	if heartbeat.NodeID != nodeID {
+		return fmt.Errorf("%w: chunk server at %s reports node ID %q", ErrNodeIDMismatch, grpcAddress, heartbeat.NodeID)
+	}
+	return nil
+}
+
+// handshake verifies that the chunk server is reachable at its URL, reports the same node ID and speaks the
+// protocol version of the front server. It fails for chunk servers older than the handshake.
+func (s *FrontService) handshake(ctx context.Context, serverURL string, nodeID string) error {
+	ctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
 
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serverURL+"/handshake", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create handshake request: %w", err)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		s.logger.Error("Chunk server handshake failed", slog.String("url", serverURL), slog.Any("error", err))
+		return fmt.Errorf("%w at %s", ErrChunkServerUnreachable, serverURL)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w at %s: handshake failed with HTTP status %d", ErrChunkServerUnreachable, serverURL, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%w: handshake with %s failed with HTTP status %d", ErrUnsupportedProtocol, serverURL, resp.StatusCode)
+	}
+	var handshake cluster.Handshake
+	if err := json.NewDecoder(resp.Body).Decode(&handshake); err != nil {
+		return fmt.Errorf("%w: invalid handshake from %s: %v", ErrUnsupportedProtocol, serverURL, err)
+	}
+	if handshake.ProtocolVersion != cluster.ProtocolVersion {
+		return fmt.Errorf("%w: chunk server at %s speaks version %d, the front server speaks version %d",
+			ErrUnsupportedProtocol, serverURL, handshake.ProtocolVersion, cluster.ProtocolVersion)
+	}
+	if handshake.NodeID != nodeID {
+		return fmt.Errorf("%w: chunk server at %s reports node ID %q", ErrNodeIDMismatch, serverURL, handshake.NodeID)
+	}
 	return nil
 }
@@ -1,54 +1,224 @@
 package front_service
 
 import (
-	"errors"
+	"context"
+	"encoding/json"
 	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"simple-s3-adventure/internal/front_server/registry_service"
 	"testing"
 
+	chunkAPI "simple-s3-adventure/internal/chunk_server/api"
+	chunkService "simple-s3-adventure/internal/chunk_server/service"
+	"simple-s3-adventure/internal/front_server/chunk_transport"
+	"simple-s3-adventure/pkg/cluster"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+const testNodeID = "123e4567-e89b-12d3-a456-426614174000"
+
+func newRegisterTestService() *FrontService {
+	return &FrontService{
+		logger:     slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+		registry:   registry_service.NewChunkServerRegistry(),
+		httpClient: &http.Client{},
+	}
+}
+
+// newChunkServer starts the HTTP API of a chunk server with the given node ID.
+func newChunkServer(t *testing.T, nodeID string) string {
+	t.Helper()
+	server := httptest.NewServer(chunkAPI.NewHandler(&chunkService.ServerConfig{UploadDir: t.TempDir()}, chunkService.WithNodeID(nodeID)))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// newHandshakeServer starts a server answering the handshake with the given status and response.
+func newHandshakeServer(t *testing.T, status int, handshake cluster.Handshake) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(handshake)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
 func TestRegisterChunkServer(t *testing.T) {
+	withoutID := newChunkServer(t, "")
+	withID := newChunkServer(t, testNodeID)
+	stopped := httptest.NewServer(http.NotFoundHandler())
+	stopped.Close()
+
 	tests := []struct {
-		name              string
-		serverURL         string
-		addChunkServerErr error
-		expectedErr       error
+		name        string
+		serverURL   string
+		nodeID      string
+		expectedErr string
+		errIs       error
 	}{
 		{
 			name:        "empty URL",
 			serverURL:   "",
-			expectedErr: errors.New("URL not provided"),
+			expectedErr: "URL not provided",
 		},
 		{
 			name:        "invalid URL",
 			serverURL:   "invalid-url",
-			expectedErr: errors.New("invalid URL"),
+			expectedErr: "invalid URL",
+		},
+		{
+			name:      "valid URL",
+			serverURL: withoutID,
+		},
+		{
+			name:      "valid node ID",
+			serverURL: withID,
+			nodeID:    testNodeID,
+		},
+		{
+			name:        "invalid node ID",
+			serverURL:   withID,
+			nodeID:      "node-1",
+			expectedErr: "invalid node ID",
+		},
+		{
+			name:      "another node ID",
+			serverURL: withID,
+			nodeID:    "00000000-0000-4000-8000-000000000001",
+			errIs:     ErrNodeIDMismatch,
+		},
+		{
+			name:      "unreachable",
+			serverURL: stopped.URL,
+			errIs:     ErrChunkServerUnreachable,
+		},
+		{
+			name:      "failing",
+			serverURL: newHandshakeServer(t, http.StatusInternalServerError, cluster.Handshake{}),
+			errIs:     ErrChunkServerUnreachable,
+		},
+		{
+			name:      "without handshake",
+			serverURL: newHandshakeServer(t, http.StatusNotFound, cluster.Handshake{}),
+			errIs:     ErrUnsupportedProtocol,
 		},
 		{
-			name:              "valid URL",
-			serverURL:         "http://example.com",
-			addChunkServerErr: nil,
-			expectedErr:       nil,
+			name:      "other protocol version",
+			serverURL: newHandshakeServer(t, http.StatusOK, cluster.Handshake{ProtocolVersion: cluster.ProtocolVersion + 1}),
+			errIs:     ErrUnsupportedProtocol,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service := &FrontService{
-				logger:   slog.New(slog.NewJSONHandler(os.Stdout, nil)),
-				registry: registry_service.NewChunkServerRegistry(),
-			}
+			service := newRegisterTestService()
 
-			err := service.RegisterChunkServer(tt.serverURL)
+			_, err := service.RegisterChunkServer(context.Background(), tt.serverURL, tt.nodeID, "", "")
 
-			if tt.expectedErr != nil {
-				assert.EqualError(t, err, tt.expectedErr.Error())
-			} else {
+			switch {
+			case tt.expectedErr != "":
+				assert.EqualError(t, err, tt.expectedErr)
+			case tt.errIs != nil:
+				assert.ErrorIs(t, err, tt.errIs)
+			default:
 				assert.NoError(t, err)
 			}
+			if err != nil {
+				assert.Empty(t, service.registry.ChunkServers())
+			}
 		})
 	}
 }
+
+func TestRegisterChunkServer_KnownNode(t *testing.T) {
+	service := newRegisterTestService()
+	first, moved := newChunkServer(t, testNodeID), newChunkServer(t, testNodeID)
+
+	reregistered, err := service.RegisterChunkServer(context.Background(), first, testNodeID, "", "")
+	assert.NoError(t, err)
+	assert.False(t, reregistered)
+
+	reregistered, err = service.RegisterChunkServer(context.Background(), moved, testNodeID, "", "")
+	assert.NoError(t, err)
+	assert.True(t, reregistered)
+	servers := service.registry.ChunkServers()
+	require.Len(t, servers, 1)
+	assert.Equal(t, moved, servers[0].Address())
+}
+
+func TestRegisterChunkServer_GRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	grpcServer := chunkAPI.NewGRPCServer(&chunkService.ServerConfig{UploadDir: t.TempDir()}, testNodeID)
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	transport := chunk_transport.NewGRPCTransport()
+	defer transport.Close()
+	service := newRegisterTestService()
+	service.transport = transport
+	ctx := context.Background()
+	serverURL := newChunkServer(t, testNodeID)
+
+	_, err = service.RegisterChunkServer(ctx, serverURL, testNodeID, "", "")
+	assert.EqualError(t, err, "gRPC address not provided")
+
+	// The gRPC address belongs to another node
+	otherID := "00000000-0000-4000-8000-000000000001"
+	_, err = service.RegisterChunkServer(ctx, newChunkServer(t, otherID), otherID, lis.Addr().String(), "")
+	assert.ErrorIs(t, err, ErrNodeIDMismatch)
+
+	_, err = service.RegisterChunkServer(ctx, serverURL, testNodeID, lis.Addr().String(), "")
+	assert.NoError(t, err)
+	servers := service.registry.ChunkServers()
+	assert.Len(t, servers, 1)
+	assert.Equal(t, lis.Addr().String(), servers[0].GRPCAddress())
+}
+
+func TestRegisterChunkServer_Mode(t *testing.T) {
+	service := newRegisterTestService()
+	ctx := context.Background()
+	serverURL := newChunkServer(t, "")
+
+	_, err := service.RegisterChunkServer(ctx, serverURL, "", "", "offline")
+	assert.ErrorIs(t, err, registry_service.ErrInvalidChunkServerMode)
+	assert.Empty(t, service.registry.ChunkServers())
+
+	_, err = service.RegisterChunkServer(ctx, serverURL, "", "", "read_only")
+	assert.NoError(t, err)
+	server, err := service.registry.GetChunkServer(serverURL)
+	assert.NoError(t, err)
+	assert.Equal(t, registry_service.ChunkServerReadOnly, server.Mode())
+
+	assert.NoError(t, service.SetChunkServerMode(serverURL, "maintenance"))
+	assert.Equal(t, registry_service.ChunkServerMaintenance, server.Mode())
+	assert.NoError(t, service.SetChunkServerMode(serverURL, "read_write"))
+	assert.True(t, server.AcceptsChunks())
+
+	assert.ErrorIs(t, service.SetChunkServerMode(serverURL, ""), registry_service.ErrInvalidChunkServerMode)
+	assert.ErrorIs(t, service.SetChunkServerMode("http://unknown:12090", "read_only"), registry_service.ErrChunkServerNotFound)
+}
+
+func TestDeregisterChunkServer(t *testing.T) {
+	service := newRegisterTestService()
+	ctx := context.Background()
+	serverURL := newChunkServer(t, testNodeID)
+
+	_, err := service.RegisterChunkServer(ctx, serverURL, testNodeID, "", "")
+	require.NoError(t, err)
+	assert.ErrorIs(t, service.DeregisterChunkServer("http://unknown:12090", testNodeID), registry_service.ErrChunkServerNotFound)
+
+	require.NoError(t, service.DeregisterChunkServer(serverURL, testNodeID))
+	assert.True(t, service.registry.ChunkServers()[0].Offline())
+
+	reregistered, err := service.RegisterChunkServer(ctx, serverURL, testNodeID, "", "")
+	require.NoError(t, err)
+	assert.True(t, reregistered)
+	assert.False(t, service.registry.ChunkServers()[0].Offline())
+}
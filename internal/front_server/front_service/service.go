@@ -3,6 +3,8 @@ package front_service
 import (
 	"log/slog"
 	"net/http"
+	"simple-s3-adventure/internal/front_server/chunk_transport"
+	"simple-s3-adventure/internal/front_server/download_service"
 	"simple-s3-adventure/internal/front_server/registry_service"
 	"simple-s3-adventure/pkg/logger"
 )
@@ -11,14 +13,58 @@ type FrontService struct {
 	registry      *registry_service.ChunkServerRegistry
 	allocationMap *registry_service.ChunkAllocationMap
 	httpClient    *http.Client
+	transport     chunk_transport.Transport
 	logger        *slog.Logger
+	hedgePolicy   *download_service.HedgePolicy
+	readAhead     int64
 }
 
-func NewFrontService(registry *registry_service.ChunkServerRegistry, allocationMap *registry_service.ChunkAllocationMap) *FrontService {
-	return &FrontService{
+type FrontServiceOption func(*FrontService)
+
+// WithHedging enables hedged reads of replicated chunks.
+func WithHedging(config download_service.HedgeConfig) FrontServiceOption {
+	return func(s *FrontService) {
+		s.hedgePolicy = download_service.NewHedgePolicy(config)
+	}
+}
+
+// WithTransport moves chunks with the given transport instead of HTTP.
+func WithTransport(transport chunk_transport.Transport) FrontServiceOption {
+	return func(s *FrontService) {
+		s.transport = transport
+	}
+}
+
+// WithReadAhead limits the memory every download may use to prefetch chunks. Zero disables prefetching.
+func WithReadAhead(budget int64) FrontServiceOption {
+	return func(s *FrontService) {
+		s.readAhead = budget
+	}
+}
+
+func NewFrontService(registry *registry_service.ChunkServerRegistry, allocationMap *registry_service.ChunkAllocationMap, opts ...FrontServiceOption) *FrontService {
+	s := &FrontService{
 		registry:      registry,
 		allocationMap: allocationMap,
 		httpClient:    &http.Client{},
 		logger:        logger.GetLogger(),
 	}
+	s.transport = chunk_transport.NewHTTPTransport(s.httpClient)
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// HedgeStats returns the hedging counters. They are zero if hedging is disabled.
+func (s *FrontService) HedgeStats() download_service.HedgeStats {
+	if s.hedgePolicy == nil {
+		return download_service.HedgeStats{}
+	}
+	return s.hedgePolicy.Stats()
+}
+
+// ObjectCount returns the number of stored files.
+func (s *FrontService) ObjectCount() int {
+	return s.allocationMap.Len()
 }
@@ -0,0 +1,80 @@
+package front_service
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// ClusterStats describes how data is distributed across chunk servers.
+type ClusterStats struct {
+	TotalBytes int64 `json:"total_bytes"`
+	Objects    int   `json:"objects"`
+	// MeanBytes is the average number of bytes per chunk server
+	MeanBytes float64 `json:"mean_bytes"`
+	// SizeThreshold is the size below which a chunk server is preferred for new chunks
+	SizeThreshold int64 `json:"size_threshold"`
+	// NextServer is the chunk server the next upload starts from
+	NextServer   string             `json:"next_server,omitempty"`
+	ChunkServers []ChunkServerStats `json:"chunk_servers"`
+}
+
+// ChunkServerStats describes the data stored on a chunk server relative to other servers.
+type ChunkServerStats struct {
+	ChunkServerInfo
+	// DeviationFromMean is how many bytes the server stores above the mean, negative if below
+	DeviationFromMean float64 `json:"deviation_from_mean"`
+	// DeviationFromThreshold is how many bytes the server stores above the size threshold, negative if below
+	DeviationFromThreshold int64 `json:"deviation_from_threshold"`
+	Underloaded            bool  `json:"underloaded"`
+}
+
+// Stats returns the statistics of the cluster.
+func (s *FrontService) Stats() ClusterStats {
+	servers := s.ListChunkServers()
+	stats := ClusterStats{
+		TotalBytes:    s.registry.TotalSize(),
+		Objects:       s.allocationMap.Len(),
+		SizeThreshold: s.registry.SizeThreshold(),
+		ChunkServers:  make([]ChunkServerStats, len(servers)),
+	}
+	if next := s.registry.NextChunkServer(); next != nil {
+		stats.NextServer = next.Address()
+	}
+	if len(servers) > 0 {
+		stats.MeanBytes = float64(stats.TotalBytes) / float64(len(servers))
+	}
+
+	for i, server := range servers {
+		stats.ChunkServers[i] = ChunkServerStats{
+			ChunkServerInfo:        server,
+			DeviationFromMean:      float64(server.Bytes) - stats.MeanBytes,
+			DeviationFromThreshold: server.Bytes - stats.SizeThreshold,
+			Underloaded:            server.Bytes < stats.SizeThreshold,
+		}
+	}
+	return stats
+}
+
+// WriteText renders the statistics as a table for terminals.
+func (s ClusterStats) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Total bytes:\t%d\n", s.TotalBytes)
+	fmt.Fprintf(tw, "Objects:\t%d\n", s.Objects)
+	fmt.Fprintf(tw, "Mean bytes:\t%.0f\n", s.MeanBytes)
+	fmt.Fprintf(tw, "Size threshold:\t%d\n", s.SizeThreshold)
+	fmt.Fprintf(tw, "Next server:\t%s\n", s.NextServer)
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "ADDRESS\tSTATE\tBYTES\tCHUNKS\tFROM MEAN\tFROM THRESHOLD\tUNDERLOADED\t")
+	for _, server := range s.ChunkServers {
+		next := ""
+		if server.Address == s.NextServer {
+			next = "<- next"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%+.0f\t%+d\t%t\t%s\n",
+			server.Address, server.State, server.Bytes, server.Chunks,
+			server.DeviationFromMean, server.DeviationFromThreshold, server.Underloaded, next)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,60 @@
+package front_service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	registry := registry_service.NewChunkServerRegistry()
+	allocationMap := registry_service.NewChunkAllocationMap()
+	require.NoError(t, registry.AddChunkServer("http://chunkserver1"))
+	require.NoError(t, registry.AddChunkServer("http://chunkserver2"))
+
+	servers := registry.ChunkServers()
+	allocationMap.AddChunk("file1", servers, []int64{100, 300})
+	registry.AdjustSizes(servers, []int64{100, 300}, 400)
+
+	service := NewFrontService(registry, allocationMap)
+	stats := service.Stats()
+	assert.Equal(t, ClusterStats{
+		TotalBytes:    400,
+		Objects:       1,
+		MeanBytes:     200,
+		SizeThreshold: 240,
+		NextServer:    "http://chunkserver1",
+		ChunkServers: []ChunkServerStats{
+			{
+				ChunkServerInfo:        ChunkServerInfo{Address: "http://chunkserver1", State: "active", Mode: "read_write", Bytes: 100, Chunks: 1},
+				DeviationFromMean:      -100,
+				DeviationFromThreshold: -140,
+				Underloaded:            true,
+			},
+			{
+				ChunkServerInfo:        ChunkServerInfo{Address: "http://chunkserver2", State: "active", Mode: "read_write", Bytes: 300, Chunks: 1},
+				DeviationFromMean:      100,
+				DeviationFromThreshold: 60,
+			},
+		},
+	}, stats)
+
+	var buf bytes.Buffer
+	require.NoError(t, stats.WriteText(&buf))
+	lines := strings.Split(buf.String(), "\n")
+	assert.Contains(t, lines[0], "400")
+	assert.Regexp(t, `^http://chunkserver1\s+active\s+100\s+1\s+-100\s+-140\s+true\s+<- next$`, lines[7])
+}
+
+func TestStats_NoChunkServers(t *testing.T) {
+	service := NewFrontService(registry_service.NewChunkServerRegistry(), registry_service.NewChunkAllocationMap())
+	stats := service.Stats()
+	assert.Zero(t, stats.MeanBytes)
+	assert.Empty(t, stats.NextServer)
+	assert.Empty(t, stats.ChunkServers)
+}
@@ -0,0 +1,67 @@
+// Package metrics defines the Prometheus metrics of the front server.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "s3"
+	subsystem = "front"
+)
+
+var (
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by handler and status code.",
+	}, []string{"handler", "code"})
+
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by handler and status code.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"handler", "code"})
+
+	UploadedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "uploaded_bytes_total",
+		Help:      "Bytes of successfully uploaded files.",
+	})
+
+	DownloadedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "downloaded_bytes_total",
+		Help:      "Bytes sent to clients downloading files.",
+	})
+
+	ChunkUploadRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "chunk_upload_retries_total",
+		Help:      "Retried requests uploading a chunk to a chunk server.",
+	})
+
+	ChunkUploadFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "chunk_upload_failures_total",
+		Help:      "Chunks that could not be uploaded to a chunk server after all retries.",
+	})
+)
+
+// InstrumentHandler counts the requests of the handler and measures their latency by status code.
+func InstrumentHandler(name string, handler http.HandlerFunc) http.Handler {
+	labels := prometheus.Labels{"handler": name}
+	return promhttp.InstrumentHandlerCounter(RequestsTotal.MustCurryWith(labels),
+		promhttp.InstrumentHandlerDuration(RequestDuration.MustCurryWith(labels), handler))
+}
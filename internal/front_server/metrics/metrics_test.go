@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumentHandler(t *testing.T) {
+	handler := InstrumentHandler("test", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+		}
+	})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test?fail=1", nil))
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(RequestsTotal.WithLabelValues("test", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(RequestsTotal.WithLabelValues("test", "400")))
+	assert.Equal(t, 2, testutil.CollectAndCount(RequestDuration.MustCurryWith(map[string]string{"handler": "test"})))
+}
@@ -0,0 +1,97 @@
+package placement_sim
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Reporter writes snapshots in a particular output format.
+type Reporter interface {
+	Report(Snapshot) error
+	Close() error
+}
+
+// NewReporter creates a reporter for the given format: "csv" or "json".
+func NewReporter(format string, w io.Writer) (Reporter, error) {
+	switch format {
+	case "csv":
+		return &csvReporter{w: csv.NewWriter(w)}, nil
+	case "json":
+		return &jsonReporter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format: %q", format)
+	}
+}
+
+var csvHeader = []string{
+	"step", "servers", "objects", "failed_uploads", "total_bytes", "mean_bytes",
+	"stddev_bytes", "min_bytes", "max_bytes", "max_min_ratio", "server_bytes",
+}
+
+// csvReporter writes one row per snapshot. Per-server sizes are joined with ';' in registry order,
+// because the number of servers changes over time.
+type csvReporter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (r *csvReporter) Report(s Snapshot) error {
+	if !r.headerWritten {
+		if err := r.w.Write(csvHeader); err != nil {
+			return err
+		}
+		r.headerWritten = true
+	}
+
+	ratio := ""
+	if s.MaxMinRatio != nil {
+		ratio = strconv.FormatFloat(*s.MaxMinRatio, 'f', 4, 64)
+	}
+	serverBytes := make([]string, len(s.Servers))
+	for i, server := range s.Servers {
+		serverBytes[i] = strconv.FormatInt(server.Bytes, 10)
+	}
+
+	return r.w.Write([]string{
+		strconv.Itoa(s.Step),
+		strconv.Itoa(len(s.Servers)),
+		strconv.Itoa(s.Objects),
+		strconv.Itoa(s.FailedUploads),
+		strconv.FormatInt(s.TotalBytes, 10),
+		strconv.FormatFloat(s.MeanBytes, 'f', 2, 64),
+		strconv.FormatFloat(s.StdDevBytes, 'f', 2, 64),
+		strconv.FormatInt(s.MinBytes, 10),
+		strconv.FormatInt(s.MaxBytes, 10),
+		ratio,
+		strings.Join(serverBytes, ";"),
+	})
+}
+
+func (r *csvReporter) Close() error {
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// jsonReporter writes all snapshots as a single JSON array.
+type jsonReporter struct {
+	w         io.Writer
+	snapshots []Snapshot
+}
+
+func (r *jsonReporter) Report(s Snapshot) error {
+	r.snapshots = append(r.snapshots, s)
+	return nil
+}
+
+func (r *jsonReporter) Close() error {
+	if r.snapshots == nil {
+		r.snapshots = []Snapshot{}
+	}
+	encoder := json.NewEncoder(r.w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.snapshots)
+}
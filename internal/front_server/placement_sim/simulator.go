@@ -0,0 +1,222 @@
+package placement_sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"simple-s3-adventure/internal/front_server/chunker"
+	"simple-s3-adventure/internal/front_server/registry_service"
+	"simple-s3-adventure/internal/front_server/upload_service"
+)
+
+// Config describes a synthetic workload.
+type Config struct {
+	// Steps is the number of operations (uploads or deletes) to simulate.
+	Steps int
+	// NumParts is the number of chunks each file is split into.
+	NumParts int
+	// InitialServers is the number of chunk servers registered before the first step.
+	InitialServers int
+	// JoinEvery adds a new chunk server every JoinEvery steps. Zero disables joins.
+	JoinEvery int
+	// MaxServers caps the number of chunk servers. Zero means no limit.
+	MaxServers int
+	// DeleteProbability is the probability that a step deletes a random stored file instead of uploading.
+	DeleteProbability float64
+	// Sizes is the file size distribution.
+	Sizes SizeDistribution
+	// FillFactor is passed to the registry. Zero keeps the registry default.
+	FillFactor float64
+	// ReportEvery takes a snapshot every ReportEvery steps. The last step is always reported.
+	ReportEvery int
+	// Seed makes the simulation reproducible.
+	Seed int64
+}
+
+func (c *Config) validate() error {
+	if c.Steps <= 0 {
+		return fmt.Errorf("steps must be positive")
+	}
+	if c.NumParts <= 0 {
+		return fmt.Errorf("number of parts must be positive")
+	}
+	if c.InitialServers <= 0 {
+		return fmt.Errorf("initial servers must be positive")
+	}
+	if c.JoinEvery < 0 || c.MaxServers < 0 || c.ReportEvery < 0 {
+		return fmt.Errorf("join interval, max servers and report interval must not be negative")
+	}
+	if c.DeleteProbability < 0 || c.DeleteProbability >= 1 {
+		return fmt.Errorf("delete probability must be in [0, 1)")
+	}
+	if c.FillFactor != 0 && c.FillFactor <= 1 {
+		return fmt.Errorf("fill factor must be greater than 1")
+	}
+	if c.Sizes == nil {
+		return fmt.Errorf("size distribution is not set")
+	}
+	return nil
+}
+
+// ServerBytes is the amount of data stored on one chunk server.
+type ServerBytes struct {
+	Address string `json:"address"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// Snapshot is the state of the cluster after a given step.
+type Snapshot struct {
+	Step          int     `json:"step"`
+	Objects       int     `json:"objects"`
+	FailedUploads int     `json:"failed_uploads"`
+	TotalBytes    int64   `json:"total_bytes"`
+	MeanBytes     float64 `json:"mean_bytes"`
+	StdDevBytes   float64 `json:"stddev_bytes"`
+	MinBytes      int64   `json:"min_bytes"`
+	MaxBytes      int64   `json:"max_bytes"`
+	// MaxMinRatio is nil while the least loaded server is empty.
+	MaxMinRatio *float64      `json:"max_min_ratio"`
+	Servers     []ServerBytes `json:"servers"`
+}
+
+type storedFile struct {
+	servers []*registry_service.ChunkServer
+	sizes   []int64
+	size    int64
+}
+
+// Simulator drives a ChunkServerRegistry with a synthetic workload.
+type Simulator struct {
+	config   Config
+	rnd      *rand.Rand
+	registry *registry_service.ChunkServerRegistry
+	files    []storedFile
+	servers  int
+	failed   int
+}
+
+func NewSimulator(config Config) (*Simulator, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	var opts []registry_service.RegistryOption
+	if config.FillFactor != 0 {
+		opts = append(opts, registry_service.WithFillFactor(config.FillFactor))
+	}
+
+	sim := &Simulator{
+		config:   config,
+		rnd:      rand.New(rand.NewSource(config.Seed)),
+		registry: registry_service.NewChunkServerRegistry(opts...),
+	}
+	for i := 0; i < config.InitialServers; i++ {
+		if err := sim.addServer(); err != nil {
+			return nil, err
+		}
+	}
+	return sim, nil
+}
+
+// Run executes the workload and calls report for every snapshot.
+func (s *Simulator) Run(report func(Snapshot) error) error {
+	for step := 1; step <= s.config.Steps; step++ {
+		if err := s.step(step); err != nil {
+			return err
+		}
+
+		if step == s.config.Steps || (s.config.ReportEvery > 0 && step%s.config.ReportEvery == 0) {
+			if err := report(s.snapshot(step)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) step(step int) error {
+	if s.config.JoinEvery > 0 && step%s.config.JoinEvery == 0 &&
+		(s.config.MaxServers == 0 || s.servers < s.config.MaxServers) {
+		if err := s.addServer(); err != nil {
+			return err
+		}
+	}
+
+	if len(s.files) > 0 && s.rnd.Float64() < s.config.DeleteProbability {
+		s.deleteFile()
+		return nil
+	}
+	s.uploadFile()
+	return nil
+}
+
+func (s *Simulator) addServer() error {
+	s.servers++
+	return s.registry.AddChunkServer(fmt.Sprintf("sim://chunk-server-%d", s.servers))
+}
+
+// uploadFile mirrors FrontService.UploadFile without moving any data.
+func (s *Simulator) uploadFile() {
+	size := s.config.Sizes.Sample(s.rnd)
+	offsets := chunker.ChunkOffsets(size, s.config.NumParts)
+
+	servers := s.registry.SelectUnderloadedChunkServers(s.config.NumParts)
+	if len(servers) != s.config.NumParts {
+		s.failed++
+		return
+	}
+
+	sizes := make([]int64, len(servers))
+	for i := range servers {
+		sizes[i] = upload_service.CalculateChunkSize(size, offsets, i)
+	}
+	s.registry.AdjustSizes(servers, sizes, size)
+	s.files = append(s.files, storedFile{servers: servers, sizes: sizes, size: size})
+}
+
+func (s *Simulator) deleteFile() {
+	i := s.rnd.Intn(len(s.files))
+	file := s.files[i]
+	s.files[i] = s.files[len(s.files)-1]
+	s.files = s.files[:len(s.files)-1]
+
+	sizes := make([]int64, len(file.sizes))
+	for i, size := range file.sizes {
+		sizes[i] = -size
+	}
+	s.registry.AdjustSizes(file.servers, sizes, -file.size)
+}
+
+func (s *Simulator) snapshot(step int) Snapshot {
+	servers := s.registry.ChunkServers()
+	snapshot := Snapshot{
+		Step:          step,
+		Objects:       len(s.files),
+		FailedUploads: s.failed,
+		TotalBytes:    s.registry.TotalSize(),
+		Servers:       make([]ServerBytes, len(servers)),
+		MinBytes:      math.MaxInt64,
+	}
+
+	for i, server := range servers {
+		size := server.Size()
+		snapshot.Servers[i] = ServerBytes{Address: server.Address(), Bytes: size}
+		snapshot.MinBytes = min(snapshot.MinBytes, size)
+		snapshot.MaxBytes = max(snapshot.MaxBytes, size)
+	}
+
+	snapshot.MeanBytes = float64(snapshot.TotalBytes) / float64(len(servers))
+	var variance float64
+	for _, server := range snapshot.Servers {
+		diff := float64(server.Bytes) - snapshot.MeanBytes
+		variance += diff * diff
+	}
+	snapshot.StdDevBytes = math.Sqrt(variance / float64(len(servers)))
+
+	if snapshot.MinBytes > 0 {
+		ratio := float64(snapshot.MaxBytes) / float64(snapshot.MinBytes)
+		snapshot.MaxMinRatio = &ratio
+	}
+	return snapshot
+}
@@ -0,0 +1,173 @@
+package placement_sim
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSizeDistribution(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected SizeDistribution
+		wantErr  bool
+	}{
+		{spec: "fixed:1024", expected: FixedSize{Size: 1024}},
+		{spec: "uniform:10:20", expected: UniformSize{Min: 10, Max: 20}},
+		{spec: "lognormal:1048576:1.5", expected: LogNormalSize{Median: 1048576, Sigma: 1.5}},
+		{spec: "fixed", wantErr: true},
+		{spec: "fixed:-1", wantErr: true},
+		{spec: "uniform:20:10", wantErr: true},
+		{spec: "lognormal:100:abc", wantErr: true},
+		{spec: "pareto:1:2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			dist, err := ParseSizeDistribution(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, dist)
+		})
+	}
+}
+
+func TestSimulator_Run(t *testing.T) {
+	sim, err := NewSimulator(Config{
+		Steps:          100,
+		NumParts:       3,
+		InitialServers: 3,
+		JoinEvery:      10,
+		MaxServers:     5,
+		Sizes:          FixedSize{Size: 3000},
+		ReportEvery:    25,
+		Seed:           1,
+	})
+	require.NoError(t, err)
+
+	var snapshots []Snapshot
+	require.NoError(t, sim.Run(func(s Snapshot) error {
+		snapshots = append(snapshots, s)
+		return nil
+	}))
+
+	require.Len(t, snapshots, 4)
+	last := snapshots[len(snapshots)-1]
+	assert.Equal(t, 100, last.Step)
+	assert.Equal(t, 100, last.Objects)
+	assert.Equal(t, 0, last.FailedUploads)
+	assert.Equal(t, int64(300000), last.TotalBytes)
+	assert.Len(t, last.Servers, 5)
+
+	var sum int64
+	for _, server := range last.Servers {
+		sum += server.Bytes
+	}
+	assert.Equal(t, last.TotalBytes, sum)
+	assert.Equal(t, float64(60000), last.MeanBytes)
+	require.NotNil(t, last.MaxMinRatio)
+	assert.GreaterOrEqual(t, *last.MaxMinRatio, 1.0)
+}
+
+func TestSimulator_Deletes(t *testing.T) {
+	sim, err := NewSimulator(Config{
+		Steps:             1000,
+		NumParts:          2,
+		InitialServers:    4,
+		DeleteProbability: 0.5,
+		Sizes:             UniformSize{Min: 100, Max: 1000},
+		Seed:              42,
+	})
+	require.NoError(t, err)
+
+	var last Snapshot
+	require.NoError(t, sim.Run(func(s Snapshot) error {
+		last = s
+		return nil
+	}))
+
+	assert.Less(t, last.Objects, 1000)
+	var expected int64
+	for _, file := range sim.files {
+		expected += file.size
+	}
+	assert.Equal(t, expected, last.TotalBytes)
+}
+
+func TestSimulator_NotEnoughServers(t *testing.T) {
+	sim, err := NewSimulator(Config{
+		Steps:          10,
+		NumParts:       6,
+		InitialServers: 2,
+		Sizes:          FixedSize{Size: 100},
+	})
+	require.NoError(t, err)
+
+	var last Snapshot
+	require.NoError(t, sim.Run(func(s Snapshot) error {
+		last = s
+		return nil
+	}))
+	assert.Equal(t, 10, last.FailedUploads)
+	assert.Nil(t, last.MaxMinRatio)
+}
+
+func TestNewSimulator_InvalidConfig(t *testing.T) {
+	_, err := NewSimulator(Config{Steps: 10, NumParts: 2, InitialServers: 2, Sizes: FixedSize{Size: 1}, FillFactor: 0.5})
+	assert.Error(t, err)
+
+	_, err = NewSimulator(Config{Steps: 10, NumParts: 2, InitialServers: 2})
+	assert.Error(t, err)
+}
+
+func TestReporters(t *testing.T) {
+	ratio := 2.0
+	snapshot := Snapshot{
+		Step:        1,
+		Objects:     1,
+		TotalBytes:  300,
+		MeanBytes:   150,
+		StdDevBytes: 50,
+		MinBytes:    100,
+		MaxBytes:    200,
+		MaxMinRatio: &ratio,
+		Servers:     []ServerBytes{{Address: "a", Bytes: 100}, {Address: "b", Bytes: 200}},
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		r, err := NewReporter("csv", &buf)
+		require.NoError(t, err)
+		require.NoError(t, r.Report(snapshot))
+		require.NoError(t, r.Close())
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 2)
+		assert.Equal(t, strings.Join(csvHeader, ","), lines[0])
+		assert.Equal(t, "1,2,1,0,300,150.00,50.00,100,200,2.0000,100;200", lines[1])
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		r, err := NewReporter("json", &buf)
+		require.NoError(t, err)
+		require.NoError(t, r.Report(snapshot))
+		require.NoError(t, r.Close())
+
+		var decoded []Snapshot
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+		assert.Equal(t, []Snapshot{snapshot}, decoded)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := NewReporter("xml", &bytes.Buffer{})
+		assert.Error(t, err)
+	})
+}
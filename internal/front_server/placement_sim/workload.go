@@ -0,0 +1,117 @@
+package placement_sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// SizeDistribution generates file sizes for the synthetic workload.
+type SizeDistribution interface {
+	Sample(r *rand.Rand) int64
+	String() string
+}
+
+// FixedSize always returns the same file size.
+type FixedSize struct {
+	Size int64
+}
+
+func (d FixedSize) Sample(_ *rand.Rand) int64 {
+	return d.Size
+}
+
+func (d FixedSize) String() string {
+	return fmt.Sprintf("fixed:%d", d.Size)
+}
+
+// UniformSize returns file sizes uniformly distributed in [Min, Max].
+type UniformSize struct {
+	Min int64
+	Max int64
+}
+
+func (d UniformSize) Sample(r *rand.Rand) int64 {
+	return d.Min + r.Int63n(d.Max-d.Min+1)
+}
+
+func (d UniformSize) String() string {
+	return fmt.Sprintf("uniform:%d:%d", d.Min, d.Max)
+}
+
+// LogNormalSize returns log-normally distributed file sizes, which is close to what
+// real object stores see: many small files and a long tail of large ones.
+type LogNormalSize struct {
+	Median int64
+	Sigma  float64
+}
+
+func (d LogNormalSize) Sample(r *rand.Rand) int64 {
+	size := int64(float64(d.Median) * math.Exp(r.NormFloat64()*d.Sigma))
+	if size < 1 {
+		return 1
+	}
+	return size
+}
+
+func (d LogNormalSize) String() string {
+	return fmt.Sprintf("lognormal:%d:%g", d.Median, d.Sigma)
+}
+
+// ParseSizeDistribution parses a distribution spec of the form
+// "fixed:<size>", "uniform:<min>:<max>" or "lognormal:<median>:<sigma>".
+func ParseSizeDistribution(spec string) (SizeDistribution, error) {
+	parts := strings.Split(spec, ":")
+	switch parts[0] {
+	case "fixed":
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("fixed distribution expects 1 parameter: %q", spec)
+		}
+		size, err := parseSize(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return FixedSize{Size: size}, nil
+	case "uniform":
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("uniform distribution expects 2 parameters: %q", spec)
+		}
+		minSize, err := parseSize(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		maxSize, err := parseSize(parts[2])
+		if err != nil {
+			return nil, err
+		}
+		if maxSize < minSize {
+			return nil, fmt.Errorf("uniform distribution max is less than min: %q", spec)
+		}
+		return UniformSize{Min: minSize, Max: maxSize}, nil
+	case "lognormal":
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("lognormal distribution expects 2 parameters: %q", spec)
+		}
+		median, err := parseSize(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		sigma, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || sigma < 0 {
+			return nil, fmt.Errorf("invalid sigma: %q", parts[2])
+		}
+		return LogNormalSize{Median: median, Sigma: sigma}, nil
+	default:
+		return nil, fmt.Errorf("unknown size distribution: %q", spec)
+	}
+}
+
+func parseSize(value string) (int64, error) {
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid size: %q", value)
+	}
+	return size, nil
+}
@@ -0,0 +1,85 @@
+package rebalance_service
+
+import (
+	"log/slog"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+)
+
+// DrainStatus describes the progress of draining a chunk server.
+type DrainStatus struct {
+	Address         string  `json:"address"`
+	State           string  `json:"state"`
+	InitialBytes    int64   `json:"initial_bytes"`
+	RemainingBytes  int64   `json:"remaining_bytes"`
+	RemainingChunks int     `json:"remaining_chunks"`
+	Progress        float64 `json:"progress"`
+}
+
+// Drain stops placing new chunks on the chunk server and starts migrating its chunks to other servers.
+func (r *Rebalancer) Drain(url string) (DrainStatus, error) {
+	server, err := r.registry.DrainChunkServer(url)
+	if err != nil {
+		return DrainStatus{}, err
+	}
+	r.logger.Info("Draining chunk server", slog.String("server", url))
+	r.Trigger()
+	return r.drainStatus(server), nil
+}
+
+// CancelDrain makes the chunk server active again. Chunks that have already been migrated stay where they are.
+func (r *Rebalancer) CancelDrain(url string) (DrainStatus, error) {
+	server, err := r.registry.ActivateChunkServer(url)
+	if err != nil {
+		return DrainStatus{}, err
+	}
+	r.logger.Info("Draining cancelled", slog.String("server", url))
+	return r.drainStatus(server), nil
+}
+
+func (r *Rebalancer) DrainStatus(url string) (DrainStatus, error) {
+	server, err := r.registry.GetChunkServer(url)
+	if err != nil {
+		return DrainStatus{}, err
+	}
+	return r.drainStatus(server), nil
+}
+
+// RemoveChunkServer removes a drained chunk server from the registry.
+func (r *Rebalancer) RemoveChunkServer(url string) error {
+	server, err := r.registry.GetChunkServer(url)
+	if err != nil {
+		return err
+	}
+	if len(r.allocationMap.ChunksOnServer(server)) != 0 {
+		return registry_service.ErrChunkServerNotRemovable
+	}
+	if err := r.registry.RemoveChunkServer(url); err != nil {
+		return err
+	}
+	r.logger.Info("Chunk server removed", slog.String("server", url))
+	return nil
+}
+
+func (r *Rebalancer) drainStatus(server *registry_service.ChunkServer) DrainStatus {
+	chunks := r.allocationMap.ChunksOnServer(server)
+	status := DrainStatus{
+		Address:         server.Address(),
+		State:           server.State().String(),
+		InitialBytes:    r.registry.DrainProgress(server),
+		RemainingChunks: len(chunks),
+	}
+	for _, chunk := range chunks {
+		status.RemainingBytes += chunk.Size
+	}
+
+	switch {
+	case server.State() == registry_service.ChunkServerActive:
+		status.Progress = 0
+	case status.RemainingChunks == 0:
+		status.Progress = 1
+	case status.InitialBytes > 0:
+		status.Progress = max(0, 1-float64(status.RemainingBytes)/float64(status.InitialBytes))
+	}
+	return status
+}
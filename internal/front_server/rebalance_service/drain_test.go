@@ -0,0 +1,114 @@
+package rebalance_service
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebalancer_Drain(t *testing.T) {
+	f := newRebalancerFixture(t, 3)
+	f.addFile("file1", 0, bytes.Repeat([]byte("a"), 100))
+	f.addFile("file2", 0, bytes.Repeat([]byte("b"), 50))
+	f.addFile("file3", 1, bytes.Repeat([]byte("c"), 10))
+
+	// The threshold prevents balancing, only draining moves chunks
+	r := NewRebalancer(Config{Interval: time.Hour, Threshold: 1 << 30}, f.registry, f.allocationMap, http.DefaultClient)
+
+	status, err := r.Drain(f.servers[0].Address())
+	require.NoError(t, err)
+	assert.Equal(t, DrainStatus{
+		Address:         f.servers[0].Address(),
+		State:           "draining",
+		InitialBytes:    150,
+		RemainingBytes:  150,
+		RemainingChunks: 2,
+	}, status)
+
+	assert.ErrorIs(t, r.RemoveChunkServer(f.servers[0].Address()), registry_service.ErrChunkServerNotRemovable)
+
+	r.rebalance(context.Background())
+
+	assert.Equal(t, int64(2), r.Status().MovedChunks)
+	assert.Empty(t, f.allocationMap.ChunksOnServer(f.servers[0]))
+	assert.Zero(t, f.servers[0].Size())
+	assert.Equal(t, int64(160), f.servers[1].Size()+f.servers[2].Size())
+
+	// The largest chunk goes to the empty server
+	assert.Equal(t, []*registry_service.ChunkServer{f.servers[2]}, f.allocationMap.GetChunkServers("file1"))
+	data, ok := f.fakes[2].chunk("file1", 0)
+	assert.True(t, ok)
+	assert.Equal(t, bytes.Repeat([]byte("a"), 100), data)
+
+	status, err = r.DrainStatus(f.servers[0].Address())
+	require.NoError(t, err)
+	assert.Equal(t, "removable", status.State)
+	assert.Equal(t, float64(1), status.Progress)
+
+	require.NoError(t, r.RemoveChunkServer(f.servers[0].Address()))
+	assert.Len(t, f.registry.ChunkServers(), 2)
+	assert.Equal(t, int64(160), f.registry.TotalSize())
+}
+
+func TestRebalancer_DrainToServerWithOtherChunksOfTheFile(t *testing.T) {
+	f := newRebalancerFixture(t, 2)
+	f.fakes[1].store("file1", 0, bytes.Repeat([]byte("a"), 100))
+	f.fakes[0].store("file1", 1, bytes.Repeat([]byte("b"), 50))
+	f.allocationMap.AddChunk("file1", []*registry_service.ChunkServer{f.servers[1], f.servers[0]}, []int64{100, 50})
+	f.registry.AdjustSizes([]*registry_service.ChunkServer{f.servers[1], f.servers[0]}, []int64{100, 50}, 150)
+
+	r := NewRebalancer(Config{Interval: time.Hour, Threshold: 1 << 30}, f.registry, f.allocationMap, http.DefaultClient)
+	_, err := r.Drain(f.servers[0].Address())
+	require.NoError(t, err)
+	r.rebalance(context.Background())
+
+	assert.Empty(t, f.allocationMap.ChunksOnServer(f.servers[0]))
+	assert.Equal(t, []*registry_service.ChunkServer{f.servers[1], f.servers[1]}, f.allocationMap.GetChunkServers("file1"))
+	data, ok := f.fakes[1].chunk("file1", 1)
+	assert.True(t, ok)
+	assert.Equal(t, bytes.Repeat([]byte("b"), 50), data)
+}
+
+func TestRebalancer_DrainProgress(t *testing.T) {
+	f := newRebalancerFixture(t, 2)
+	f.addFile("file1", 0, bytes.Repeat([]byte("a"), 100))
+	f.addFile("file2", 0, bytes.Repeat([]byte("b"), 300))
+	f.fakes[1].Close()
+
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	_, err := r.Drain(f.servers[0].Address())
+	require.NoError(t, err)
+
+	// Simulate that the first chunk has been migrated
+	require.True(t, f.allocationMap.MoveChunk("file1", 0, f.servers[0], f.servers[1]))
+	f.registry.AdjustSizes([]*registry_service.ChunkServer{f.servers[0], f.servers[1]}, []int64{-100, 100}, 0)
+
+	status, err := r.DrainStatus(f.servers[0].Address())
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), status.RemainingBytes)
+	assert.Equal(t, 1, status.RemainingChunks)
+	assert.Equal(t, 0.25, status.Progress)
+
+	status, err = r.CancelDrain(f.servers[0].Address())
+	require.NoError(t, err)
+	assert.Equal(t, "active", status.State)
+	assert.Zero(t, status.Progress)
+}
+
+func TestRebalancer_DrainUnknownServer(t *testing.T) {
+	f := newRebalancerFixture(t, 1)
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+
+	_, err := r.Drain("http://unknown")
+	assert.ErrorIs(t, err, registry_service.ErrChunkServerNotFound)
+	_, err = r.DrainStatus("http://unknown")
+	assert.ErrorIs(t, err, registry_service.ErrChunkServerNotFound)
+	assert.ErrorIs(t, r.RemoveChunkServer("http://unknown"), registry_service.ErrChunkServerNotFound)
+}
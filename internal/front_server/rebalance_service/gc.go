@@ -0,0 +1,240 @@
+package rebalance_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+)
+
+const (
+	// maxReportedOrphans caps the orphan chunks kept in GCStatus, the counters still cover all of them.
+	maxReportedOrphans = 10000
+	// listPageSize is the number of chunks requested from a chunk server at once.
+	listPageSize = 1000
+)
+
+var ErrGCRunning = errors.New("garbage collection is already running")
+
+// OrphanChunk is a chunk stored on a chunk server that no file in the allocation map refers to.
+type OrphanChunk struct {
+	Server   string    `json:"server"`
+	FileUUID string    `json:"file_uuid"`
+	Index    int       `json:"index"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	Deleted  bool      `json:"deleted"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// GCStatus describes the running or the last finished garbage collection.
+type GCStatus struct {
+	Running       bool          `json:"running"`
+	DryRun        bool          `json:"dry_run"`
+	StartedAt     *time.Time    `json:"started_at,omitempty"`
+	FinishedAt    *time.Time    `json:"finished_at,omitempty"`
+	Servers       int           `json:"servers"`
+	Chunks        int           `json:"chunks"`
+	OrphanChunks  int           `json:"orphan_chunks"`
+	OrphanBytes   int64         `json:"orphan_bytes"`
+	DeletedChunks int           `json:"deleted_chunks"`
+	DeletedBytes  int64         `json:"deleted_bytes"`
+	Orphans       []OrphanChunk `json:"orphans"`
+	Errors        []string      `json:"errors,omitempty"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// listedChunk is a chunk in the response of the /list endpoint of a chunk server.
+type listedChunk struct {
+	UUID    string    `json:"uuid"`
+	Index   int       `json:"index"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// StartGC deletes in the background the chunks that chunk servers store but the allocation map doesn't know,
+// e.g. left by failed uploads, deletes while a chunk server was down or a lost front server state. Chunks
+// modified within GCGracePeriod are kept, since uploads and migrations write chunks before they are recorded.
+// With dryRun, the orphan chunks are only reported.
+func (r *Rebalancer) StartGC(dryRun bool) (GCStatus, error) {
+	r.mu.Lock()
+	if r.gc.Running {
+		r.mu.Unlock()
+		return GCStatus{}, ErrGCRunning
+	}
+	now := time.Now()
+	r.gc = GCStatus{Running: true, DryRun: dryRun, StartedAt: &now}
+	r.mu.Unlock()
+
+	ctx, cancel := r.untilStopped()
+	go func() {
+		defer cancel()
+		err := r.runGC(ctx, dryRun)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		now := time.Now()
+		r.gc.Running = false
+		r.gc.FinishedAt = &now
+		if err != nil {
+			r.gc.Error = err.Error()
+		}
+	}()
+
+	return r.GCStatus(), nil
+}
+
+func (r *Rebalancer) GCStatus() GCStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.gc
+	status.Orphans = append([]OrphanChunk{}, r.gc.Orphans...)
+	status.Errors = append([]string(nil), r.gc.Errors...)
+	return status
+}
+
+func (r *Rebalancer) runGC(ctx context.Context, dryRun bool) error {
+	r.logger.Info("Garbage collection started", slog.Bool("dry_run", dryRun))
+
+	for _, server := range r.registry.ChunkServers() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Its chunks are collected once it is back
+		if server.Offline() {
+			continue
+		}
+		// Chunks written after the listing started are younger than the grace period anyway
+		cutoff := time.Now().Add(-r.config.GCGracePeriod)
+		chunks, err := r.listChunks(ctx, server)
+		if err != nil {
+			r.logger.Error("Failed to list chunks", slog.String("server", server.Address()), slog.Any("error", err))
+			r.mu.Lock()
+			r.gc.Errors = append(r.gc.Errors, fmt.Sprintf("%s: %v", server.Address(), err))
+			r.mu.Unlock()
+			continue
+		}
+
+		for _, chunk := range chunks {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if chunk.ModTime.After(cutoff) || r.allocationMap.HasReplica(chunk.UUID, chunk.Index, server) {
+				continue
+			}
+			r.collectOrphan(ctx, server, chunk, dryRun)
+		}
+
+		r.mu.Lock()
+		r.gc.Servers++
+		r.gc.Chunks += len(chunks)
+		r.mu.Unlock()
+	}
+
+	status := r.GCStatus()
+	r.logger.Info("Garbage collection finished",
+		slog.Bool("dry_run", dryRun),
+		slog.Int("chunks", status.Chunks),
+		slog.Int("orphan_chunks", status.OrphanChunks),
+		slog.Int64("orphan_bytes", status.OrphanBytes),
+		slog.Int("deleted_chunks", status.DeletedChunks))
+	return nil
+}
+
+// collectOrphan records the orphan chunk and, unless dryRun, deletes it.
+func (r *Rebalancer) collectOrphan(ctx context.Context, server *registry_service.ChunkServer, chunk listedChunk, dryRun bool) {
+	orphan := OrphanChunk{
+		Server:   server.Address(),
+		FileUUID: chunk.UUID,
+		Index:    chunk.Index,
+		Size:     chunk.Size,
+		ModTime:  chunk.ModTime,
+	}
+	if !dryRun {
+		if err := r.removeChunk(ctx, server, chunk.UUID, chunk.Index); err != nil {
+			orphan.Error = err.Error()
+			r.logger.Warn("Failed to delete orphan chunk",
+				slog.String("uuid", chunk.UUID),
+				slog.Int("chunk", chunk.Index),
+				slog.String("server", server.Address()),
+				slog.Any("error", err))
+		} else {
+			orphan.Deleted = true
+			r.logger.Info("Deleted orphan chunk",
+				slog.String("uuid", chunk.UUID),
+				slog.Int("chunk", chunk.Index),
+				slog.String("server", server.Address()))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gc.OrphanChunks++
+	r.gc.OrphanBytes += chunk.Size
+	if orphan.Deleted {
+		r.gc.DeletedChunks++
+		r.gc.DeletedBytes += chunk.Size
+	}
+	if len(r.gc.Orphans) < maxReportedOrphans {
+		r.gc.Orphans = append(r.gc.Orphans, orphan)
+	}
+}
+
+// listChunks returns all chunks stored on the chunk server, reading the list page by page.
+func (r *Rebalancer) listChunks(ctx context.Context, server *registry_service.ChunkServer) ([]listedChunk, error) {
+	var (
+		chunks []listedChunk
+		after  string
+	)
+	for {
+		page, next, err := r.listPage(ctx, server, after)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, page...)
+		if next == "" {
+			return chunks, nil
+		}
+		after = next
+	}
+}
+
+// listPage returns the chunks following after and the cursor of the next page, empty on the last page.
+func (r *Rebalancer) listPage(ctx context.Context, server *registry_service.ChunkServer, after string) ([]listedChunk, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	params := url.Values{"limit": {strconv.Itoa(listPageSize)}}
+	if after != "" {
+		params.Set("after", after)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.Address()+"/list?"+params.Encode(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create GET request: %w", err)
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to send GET request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("received non-OK HTTP status: %d", resp.StatusCode)
+	}
+	var list struct {
+		Chunks []listedChunk `json:"chunks"`
+		Next   string        `json:"next"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, "", fmt.Errorf("failed to decode chunk list: %w", err)
+	}
+	return list.Chunks, list.Next, nil
+}
@@ -0,0 +1,93 @@
+package rebalance_service
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitForGC(t *testing.T, r *Rebalancer) GCStatus {
+	require.Eventually(t, func() bool {
+		return !r.GCStatus().Running
+	}, 5*time.Second, 10*time.Millisecond)
+	return r.GCStatus()
+}
+
+func newGCFixture(t *testing.T) *rebalancerFixture {
+	f := newRebalancerFixture(t, 2)
+	f.addReplicatedFile("file1", []int{0, 1}, bytes.Repeat([]byte("a"), 100))
+	f.fakes[0].age("file1", 0, 2*time.Hour)
+	// Left by a failed upload
+	f.fakes[1].store("orphan", 0, bytes.Repeat([]byte("b"), 50))
+	f.fakes[1].age("orphan", 0, 2*time.Hour)
+	// A chunk of a known file, but not on this server, e.g. left by a migration
+	f.fakes[1].store("file1", 1, bytes.Repeat([]byte("c"), 30))
+	f.fakes[1].age("file1", 1, 2*time.Hour)
+	// Probably an upload in progress
+	f.fakes[0].store("uploading", 0, bytes.Repeat([]byte("d"), 10))
+	return f
+}
+
+func TestRebalancer_GCDryRun(t *testing.T) {
+	f := newGCFixture(t)
+	r := NewRebalancer(Config{Interval: time.Hour, GCGracePeriod: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	_, err := r.StartGC(true)
+	require.NoError(t, err)
+
+	status := waitForGC(t, r)
+	assert.True(t, status.DryRun)
+	assert.Equal(t, 2, status.Servers)
+	assert.Equal(t, 5, status.Chunks)
+	assert.Equal(t, 2, status.OrphanChunks)
+	assert.Equal(t, int64(80), status.OrphanBytes)
+	assert.Zero(t, status.DeletedChunks)
+	require.Len(t, status.Orphans, 2)
+	for _, orphan := range status.Orphans {
+		assert.Equal(t, f.servers[1].Address(), orphan.Server)
+		assert.False(t, orphan.Deleted)
+	}
+	_, ok := f.fakes[1].chunk("orphan", 0)
+	assert.True(t, ok)
+}
+
+func TestRebalancer_GC(t *testing.T) {
+	f := newGCFixture(t)
+	r := NewRebalancer(Config{Interval: time.Hour, GCGracePeriod: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	_, err := r.StartGC(false)
+	require.NoError(t, err)
+
+	status := waitForGC(t, r)
+	assert.Equal(t, 2, status.DeletedChunks)
+	assert.Equal(t, int64(80), status.DeletedBytes)
+	assert.NotNil(t, status.FinishedAt)
+	assert.Empty(t, status.Errors)
+
+	_, ok := f.fakes[1].chunk("orphan", 0)
+	assert.False(t, ok)
+	_, ok = f.fakes[1].chunk("file1", 1)
+	assert.False(t, ok)
+	for _, server := range []int{0, 1} {
+		_, ok = f.fakes[server].chunk("file1", 0)
+		assert.True(t, ok)
+	}
+	_, ok = f.fakes[0].chunk("uploading", 0)
+	assert.True(t, ok)
+}
+
+func TestRebalancer_GCUnreachableServer(t *testing.T) {
+	f := newGCFixture(t)
+	f.fakes[0].Close()
+	r := NewRebalancer(Config{Interval: time.Hour, GCGracePeriod: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	_, err := r.StartGC(false)
+	require.NoError(t, err)
+
+	status := waitForGC(t, r)
+	assert.Equal(t, 1, status.Servers)
+	assert.Equal(t, 2, status.DeletedChunks)
+	require.Len(t, status.Errors, 1)
+	assert.Contains(t, status.Errors[0], f.servers[0].Address())
+}
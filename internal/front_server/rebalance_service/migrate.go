@@ -0,0 +1,267 @@
+package rebalance_service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+)
+
+var ErrMoveAborted = errors.New("chunk allocation changed during migration")
+
+// MoveReason explains why a chunk is migrated.
+type MoveReason string
+
+const (
+	MoveReasonBalance MoveReason = "balance"
+	MoveReasonDrain   MoveReason = "drain"
+	MoveReasonRepair  MoveReason = "repair"
+)
+
+// Move describes the migration of one chunk between chunk servers.
+type Move struct {
+	FileUUID string     `json:"file_uuid"`
+	Index    int        `json:"index"`
+	Size     int64      `json:"size"`
+	From     string     `json:"from"`
+	To       string     `json:"to"`
+	Reason   MoveReason `json:"reason"`
+
+	from *registry_service.ChunkServer
+	to   *registry_service.ChunkServer
+}
+
+func newMove(ref registry_service.ChunkRef, to *registry_service.ChunkServer, reason MoveReason) *Move {
+	return &Move{
+		FileUUID: ref.FileUUID,
+		Index:    ref.Index,
+		Size:     ref.Size,
+		From:     ref.Server.Address(),
+		To:       to.Address(),
+		Reason:   reason,
+		from:     ref.Server,
+		to:       to,
+	}
+}
+
+// migrate copies the chunk to the target server, verifies the copy, switches the allocation map entry
+// and schedules deletion of the old copy.
+func (r *Rebalancer) migrate(ctx context.Context, m *Move) error {
+	r.logger.Info("Migrating chunk",
+		slog.String("reason", string(m.Reason)),
+		slog.String("uuid", m.FileUUID),
+		slog.Int("chunk", m.Index),
+		slog.Int64("size", m.Size),
+		slog.String("from", m.From),
+		slog.String("to", m.To))
+
+	checksum, err := r.copyChunk(ctx, m)
+	if err != nil {
+		return fmt.Errorf("failed to copy chunk: %w", err)
+	}
+
+	if err := r.verifyChunk(ctx, m, checksum); err != nil {
+		r.deleteChunk(m.to, m.FileUUID, m.Index)
+		return fmt.Errorf("failed to verify chunk copy: %w", err)
+	}
+
+	if !r.allocationMap.MoveChunk(m.FileUUID, m.Index, m.from, m.to) {
+		r.deleteChunk(m.to, m.FileUUID, m.Index)
+		return ErrMoveAborted
+	}
+	r.registry.AdjustSizes([]*registry_service.ChunkServer{m.from, m.to}, []int64{-m.Size, m.Size}, 0)
+
+	// Downloads that have already resolved the old location may still be reading from it,
+	// so the old copy is deleted after a delay.
+	r.scheduleDelete(m.from, m.FileUUID, m.Index)
+	return nil
+}
+
+// copyChunk streams the chunk from the source server to the target server and returns its checksum.
+func (r *Rebalancer) copyChunk(ctx context.Context, m *Move) ([]byte, error) {
+	resp, err := r.get(ctx, m.from, m.FileUUID, m.Index)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	body := io.TeeReader(newThrottledReader(ctx, resp.Body, r.config.BandwidthLimit), io.MultiWriter(hash, counter))
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	written := make(chan error, 1)
+	go func() {
+		err := writeMultipart(writer, m.FileUUID, m.Index, body)
+		pw.CloseWithError(err)
+		written <- err
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, m.to.Address()+"/put", pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		<-written
+		return nil, fmt.Errorf("failed to create PUT request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	putResp, err := r.httpClient.Do(req)
+	if err != nil {
+		pr.CloseWithError(err)
+		<-written
+		return nil, fmt.Errorf("failed to send PUT request: %w", err)
+	}
+	defer putResp.Body.Close()
+
+	if putResp.StatusCode != http.StatusOK {
+		pr.CloseWithError(errors.New("chunk server rejected the chunk"))
+		<-written
+		return nil, fmt.Errorf("received non-OK HTTP status: %d", putResp.StatusCode)
+	}
+	if err := <-written; err != nil {
+		r.deleteChunk(m.to, m.FileUUID, m.Index)
+		return nil, err
+	}
+	if counter.n != m.Size {
+		r.deleteChunk(m.to, m.FileUUID, m.Index)
+		return nil, fmt.Errorf("copied %d bytes, expected %d", counter.n, m.Size)
+	}
+	return hash.Sum(nil), nil
+}
+
+// verifyChunk reads the chunk back from the target server and compares it with the source checksum.
+func (r *Rebalancer) verifyChunk(ctx context.Context, m *Move, checksum []byte) error {
+	resp, err := r.get(ctx, m.to, m.FileUUID, m.Index)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, newThrottledReader(ctx, resp.Body, r.config.BandwidthLimit))
+	if err != nil {
+		return fmt.Errorf("failed to read chunk: %w", err)
+	}
+	if n != m.Size {
+		return fmt.Errorf("target has %d bytes, expected %d", n, m.Size)
+	}
+	if !bytes.Equal(hash.Sum(nil), checksum) {
+		return fmt.Errorf("checksum mismatch")
+	}
+	return nil
+}
+
+func (r *Rebalancer) get(ctx context.Context, server *registry_service.ChunkServer, uuid string, index int) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, chunkURL(server, "/get", uuid, index), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GET request: %w", err)
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send GET request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("received non-OK HTTP status: %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (r *Rebalancer) scheduleDelete(server *registry_service.ChunkServer, uuid string, index int) {
+	if r.config.DeleteDelay <= 0 {
+		r.deleteChunk(server, uuid, index)
+		return
+	}
+	r.pendingDeletes.Add(1)
+	go func() {
+		defer r.pendingDeletes.Done()
+		timer := time.NewTimer(r.config.DeleteDelay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.stopped:
+		}
+		r.deleteChunk(server, uuid, index)
+	}()
+}
+
+// deleteChunk removes a chunk from the chunk server. Failures are only logged: the chunk is no longer
+// referenced by the allocation map, so it just wastes space on the chunk server.
+func (r *Rebalancer) deleteChunk(server *registry_service.ChunkServer, uuid string, index int) {
+	if err := r.removeChunk(context.Background(), server, uuid, index); err != nil {
+		r.logger.Warn("Failed to delete chunk",
+			slog.String("uuid", uuid),
+			slog.Int("chunk", index),
+			slog.String("server", server.Address()),
+			slog.Any("error", err))
+	}
+}
+
+// removeChunk removes a chunk from the chunk server.
+func (r *Rebalancer) removeChunk(ctx context.Context, server *registry_service.ChunkServer, uuid string, index int) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, chunkURL(server, "/delete", uuid, index), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create DELETE request: %w", err)
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send DELETE request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-OK HTTP status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// writeMultipart writes the upload form of the chunk. Any copy already on the target is stale,
+// so it is overwritten.
+func writeMultipart(writer *multipart.Writer, uuid string, index int, body io.Reader) error {
+	if err := writer.WriteField("uuid", uuid); err != nil {
+		return fmt.Errorf("failed to add UUID field: %w", err)
+	}
+	if err := writer.WriteField("index", strconv.Itoa(index)); err != nil {
+		return fmt.Errorf("failed to add index field: %w", err)
+	}
+	if err := writer.WriteField("overwrite", "true"); err != nil {
+		return fmt.Errorf("failed to add overwrite field: %w", err)
+	}
+	part, err := writer.CreateFormFile("file", "file.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, body); err != nil {
+		return fmt.Errorf("failed to copy chunk to part: %w", err)
+	}
+	return writer.Close()
+}
+
+// chunkURL returns the URL of the endpoint for the chunk.
+func chunkURL(server *registry_service.ChunkServer, path string, uuid string, index int) string {
+	params := url.Values{"uuid": {uuid}, "index": {strconv.Itoa(index)}}
+	return server.Address() + path + "?" + params.Encode()
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
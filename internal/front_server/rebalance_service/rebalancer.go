@@ -0,0 +1,303 @@
+package rebalance_service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+	"simple-s3-adventure/pkg/logger"
+)
+
+const requestTimeout = 30 * time.Second
+
+type Config struct {
+	// Interval is how often the rebalancer checks whether the cluster is balanced. 0 disables periodic checks.
+	Interval time.Duration
+	// BandwidthLimit is the maximum number of bytes per second read from chunk servers. 0 means unlimited.
+	BandwidthLimit int64
+	// Threshold is the minimal difference in bytes between the most and the least loaded chunk servers
+	// that triggers migration.
+	Threshold int64
+	// DeleteDelay is how long the old copy of a migrated chunk is kept, so in-flight downloads can finish.
+	DeleteDelay time.Duration
+	// GCInterval is how often orphan chunks are deleted from chunk servers. 0 disables periodic collection.
+	GCInterval time.Duration
+	// GCGracePeriod is how long a chunk unknown to the allocation map is kept after it was written. It must be
+	// longer than the slowest upload and than DeleteDelay.
+	GCGracePeriod time.Duration
+}
+
+// Status describes the state of the rebalancer.
+type Status struct {
+	Paused      bool   `json:"paused"`
+	Active      bool   `json:"active"`
+	MovedChunks int64  `json:"moved_chunks"`
+	MovedBytes  int64  `json:"moved_bytes"`
+	FailedMoves int64  `json:"failed_moves"`
+	CurrentMove *Move  `json:"current_move,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+}
+
+// Rebalancer migrates chunks from the most loaded chunk servers to the least loaded ones,
+// e.g. to newly registered chunk servers, and empties draining chunk servers.
+type Rebalancer struct {
+	config        Config
+	registry      *registry_service.ChunkServerRegistry
+	allocationMap *registry_service.ChunkAllocationMap
+	httpClient    *http.Client
+	logger        *slog.Logger
+
+	mu     sync.Mutex
+	status Status
+	scrub  ScrubStatus
+	gc     GCStatus
+	wake   chan struct{}
+
+	stopped chan struct{}
+	// stopping is set under mu when Run returns, so no repair is added to pendingRepairs while it is waited for
+	stopping       bool
+	pendingDeletes sync.WaitGroup
+	pendingRepairs sync.WaitGroup
+}
+
+func NewRebalancer(config Config, registry *registry_service.ChunkServerRegistry, allocationMap *registry_service.ChunkAllocationMap, httpClient *http.Client) *Rebalancer {
+	return &Rebalancer{
+		config:        config,
+		registry:      registry,
+		allocationMap: allocationMap,
+		httpClient:    httpClient,
+		logger:        logger.GetLogger(),
+		wake:          make(chan struct{}, 1),
+		stopped:       make(chan struct{}),
+	}
+}
+
+// Run checks the balance of the cluster every Interval and migrates chunks until the context is cancelled.
+// Every GCInterval it also starts a garbage collection of orphan chunks.
+func (r *Rebalancer) Run(ctx context.Context) {
+	defer func() {
+		r.mu.Lock()
+		r.stopping = true
+		r.mu.Unlock()
+		close(r.stopped)
+		r.pendingDeletes.Wait()
+		r.pendingRepairs.Wait()
+	}()
+
+	// Without an interval the rebalancer only runs when it is triggered
+	var tick <-chan time.Time
+	if r.config.Interval > 0 {
+		ticker := time.NewTicker(r.config.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var gcTick <-chan time.Time
+	if r.config.GCInterval > 0 {
+		ticker := time.NewTicker(r.config.GCInterval)
+		defer ticker.Stop()
+		gcTick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gcTick:
+			if _, err := r.StartGC(false); err != nil {
+				r.logger.Warn("Skipped garbage collection", slog.Any("error", err))
+			}
+			continue
+		case <-tick:
+		case <-r.wake:
+		}
+		r.rebalance(ctx)
+	}
+}
+
+// Pause stops the rebalancer after the current migration is finished.
+func (r *Rebalancer) Pause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.Paused = true
+}
+
+// Resume resumes the rebalancer and triggers a balance check.
+func (r *Rebalancer) Resume() {
+	r.mu.Lock()
+	r.status.Paused = false
+	r.mu.Unlock()
+
+	r.Trigger()
+}
+
+// Trigger wakes the rebalancer up without waiting for the next interval.
+func (r *Rebalancer) Trigger() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Rebalancer) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.status
+	if status.CurrentMove != nil {
+		move := *status.CurrentMove
+		status.CurrentMove = &move
+	}
+	return status
+}
+
+func (r *Rebalancer) paused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.status.Paused
+}
+
+// rebalance migrates chunks one by one until the cluster is balanced, the rebalancer is paused
+// or a migration fails.
+func (r *Rebalancer) rebalance(ctx context.Context) {
+	for ctx.Err() == nil && !r.paused() {
+		move := r.plan()
+		if move == nil {
+			return
+		}
+
+		r.setCurrentMove(move)
+		err := r.migrate(ctx, move)
+		r.finishMove(move, err)
+
+		if err != nil && !errors.Is(err, ErrMoveAborted) {
+			r.logger.Error("Failed to migrate chunk", slog.String("uuid", move.FileUUID), slog.Any("error", err))
+			// Let the next tick retry, the chunk servers might be temporarily unavailable.
+			return
+		}
+	}
+}
+
+// plan selects the next chunk to migrate. Draining chunk servers are emptied first. Chunks are only moved
+// to and balanced between chunk servers that accept chunks, and not moved away from chunk servers in
+// maintenance, which wouldn't delete the old copy, or offline ones, which can't be read.
+func (r *Rebalancer) plan() *Move {
+	var active, draining []*registry_service.ChunkServer
+	for _, server := range r.registry.ChunkServers() {
+		switch {
+		case server.AcceptsChunks():
+			active = append(active, server)
+		case server.State() != registry_service.ChunkServerActive && server.Mode() != registry_service.ChunkServerMaintenance && !server.Offline():
+			draining = append(draining, server)
+		}
+	}
+
+	if move := r.planDrain(draining, active); move != nil {
+		return move
+	}
+	return r.planBalance(active)
+}
+
+// planDrain moves the largest chunk of a draining chunk server to the least loaded active server that can accept it.
+// Draining servers without chunks are marked as removable.
+func (r *Rebalancer) planDrain(draining []*registry_service.ChunkServer, active []*registry_service.ChunkServer) *Move {
+	sortBySize(active)
+
+	for _, source := range draining {
+		chunks := r.allocationMap.ChunksOnServer(source)
+		r.registry.SetDrained(source, len(chunks) == 0)
+
+		sort.Slice(chunks, func(i, j int) bool {
+			return chunks[i].Size > chunks[j].Size
+		})
+		for _, chunk := range chunks {
+			for _, target := range active {
+				if !r.allocationMap.HasReplica(chunk.FileUUID, chunk.Index, target) {
+					return newMove(chunk, target, MoveReasonDrain)
+				}
+			}
+			r.logger.Warn("No chunk server can accept chunk of draining server",
+				slog.String("uuid", chunk.FileUUID),
+				slog.String("server", source.Address()))
+		}
+	}
+	return nil
+}
+
+// planBalance takes the most loaded chunk server and moves the largest chunk that doesn't overshoot
+// the balance to the least loaded chunk server that can accept it.
+func (r *Rebalancer) planBalance(servers []*registry_service.ChunkServer) *Move {
+	if len(servers) < 2 {
+		return nil
+	}
+
+	sizes := sortBySize(servers)
+
+	source := servers[len(servers)-1]
+	chunks := r.allocationMap.ChunksOnServer(source)
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].Size > chunks[j].Size
+	})
+
+	for _, target := range servers[:len(servers)-1] {
+		diff := sizes[source] - sizes[target]
+		if diff < r.config.Threshold || diff <= 0 {
+			// Servers are sorted by size, so the remaining targets are even closer to the source.
+			return nil
+		}
+
+		for _, chunk := range chunks {
+			// Moving a chunk larger than half of the difference would make the target more loaded than the source.
+			if chunk.Size == 0 || chunk.Size > diff/2 {
+				continue
+			}
+			if r.allocationMap.HasReplica(chunk.FileUUID, chunk.Index, target) {
+				continue
+			}
+			return newMove(chunk, target, MoveReasonBalance)
+		}
+	}
+	return nil
+}
+
+// sortBySize sorts chunk servers from the least loaded to the most loaded and returns the sizes it used.
+func sortBySize(servers []*registry_service.ChunkServer) map[*registry_service.ChunkServer]int64 {
+	sizes := make(map[*registry_service.ChunkServer]int64, len(servers))
+	for _, server := range servers {
+		sizes[server] = server.Size()
+	}
+	sort.SliceStable(servers, func(i, j int) bool {
+		return sizes[servers[i]] < sizes[servers[j]]
+	})
+	return sizes
+}
+
+func (r *Rebalancer) setCurrentMove(move *Move) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.Active = true
+	r.status.CurrentMove = move
+}
+
+func (r *Rebalancer) finishMove(move *Move, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.Active = false
+	r.status.CurrentMove = nil
+	if err != nil {
+		r.status.FailedMoves++
+		r.status.LastError = err.Error()
+		return
+	}
+	r.status.MovedChunks++
+	r.status.MovedBytes += move.Size
+}
@@ -0,0 +1,319 @@
+package rebalance_service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChunkServer is an in-memory chunk server. Chunks are keyed by "<uuid>_<index>".
+type fakeChunkServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	chunks   map[string][]byte
+	modTimes map[string]time.Time
+}
+
+func newFakeChunkServer(t *testing.T) *fakeChunkServer {
+	s := &fakeChunkServer{chunks: make(map[string][]byte), modTimes: make(map[string]time.Time)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch r.URL.Path {
+		case "/put":
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			s.chunks[r.FormValue("uuid")+"_"+r.FormValue("index")] = data
+			s.modTimes[r.FormValue("uuid")+"_"+r.FormValue("index")] = time.Now()
+		case "/get":
+			data, ok := s.chunks[r.URL.Query().Get("uuid")+"_"+r.URL.Query().Get("index")]
+			if !ok {
+				http.Error(w, "File not found", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data)
+		case "/delete":
+			key := r.URL.Query().Get("uuid") + "_" + r.URL.Query().Get("index")
+			if _, ok := s.chunks[key]; !ok {
+				http.Error(w, "File not found", http.StatusNotFound)
+				return
+			}
+			delete(s.chunks, key)
+			delete(s.modTimes, key)
+		case "/list":
+			// Pages of two chunks, to exercise pagination
+			var list struct {
+				Chunks []listedChunk `json:"chunks"`
+				Next   string        `json:"next,omitempty"`
+			}
+			keys := make([]string, 0, len(s.chunks))
+			for key := range s.chunks {
+				if key > r.URL.Query().Get("after") {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			if len(keys) > 2 {
+				keys = keys[:2]
+				list.Next = keys[1]
+			}
+			for _, key := range keys {
+				i := strings.LastIndex(key, "_")
+				index, _ := strconv.Atoi(key[i+1:])
+				list.Chunks = append(list.Chunks, listedChunk{UUID: key[:i], Index: index, Size: int64(len(s.chunks[key])), ModTime: s.modTimes[key]})
+			}
+			json.NewEncoder(w).Encode(list)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeChunkServer) store(uuid string, index int, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks[uuid+"_"+strconv.Itoa(index)] = data
+	s.modTimes[uuid+"_"+strconv.Itoa(index)] = time.Now()
+}
+
+// age makes the chunk look as if it was written d ago.
+func (s *fakeChunkServer) age(uuid string, index int, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.modTimes[uuid+"_"+strconv.Itoa(index)] = time.Now().Add(-d)
+}
+
+func (s *fakeChunkServer) chunk(uuid string, index int) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.chunks[uuid+"_"+strconv.Itoa(index)]
+	return data, ok
+}
+
+type rebalancerFixture struct {
+	registry      *registry_service.ChunkServerRegistry
+	allocationMap *registry_service.ChunkAllocationMap
+	servers       []*registry_service.ChunkServer
+	fakes         []*fakeChunkServer
+}
+
+func newRebalancerFixture(t *testing.T, numServers int) *rebalancerFixture {
+	f := &rebalancerFixture{
+		registry:      registry_service.NewChunkServerRegistry(),
+		allocationMap: registry_service.NewChunkAllocationMap(),
+	}
+	for i := 0; i < numServers; i++ {
+		fake := newFakeChunkServer(t)
+		require.NoError(t, f.registry.AddChunkServer(fake.URL))
+		f.fakes = append(f.fakes, fake)
+	}
+	f.servers = f.registry.ChunkServers()
+	return f
+}
+
+// addFile stores a single-chunk file on the given server.
+func (f *rebalancerFixture) addFile(uuid string, server int, data []byte) {
+	f.fakes[server].store(uuid, 0, data)
+	size := int64(len(data))
+	f.allocationMap.AddChunk(uuid, []*registry_service.ChunkServer{f.servers[server]}, []int64{size})
+	f.registry.AdjustSizes([]*registry_service.ChunkServer{f.servers[server]}, []int64{size}, size)
+}
+
+func TestRebalancer_MovesChunksToNewServer(t *testing.T) {
+	f := newRebalancerFixture(t, 2)
+	files := map[string][]byte{}
+	for _, uuid := range []string{"file1", "file2", "file3", "file4"} {
+		files[uuid] = bytes.Repeat([]byte(uuid[len(uuid)-1:]), 100)
+		f.addFile(uuid, 0, files[uuid])
+	}
+
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	r.rebalance(context.Background())
+
+	assert.Equal(t, int64(200), f.servers[0].Size())
+	assert.Equal(t, int64(200), f.servers[1].Size())
+	assert.Equal(t, int64(400), f.registry.TotalSize())
+
+	status := r.Status()
+	assert.Equal(t, int64(2), status.MovedChunks)
+	assert.Equal(t, int64(200), status.MovedBytes)
+	assert.Zero(t, status.FailedMoves)
+
+	for _, ref := range f.allocationMap.ChunksOnServer(f.servers[1]) {
+		data, ok := f.fakes[1].chunk(ref.FileUUID, ref.Index)
+		assert.True(t, ok)
+		assert.Equal(t, files[ref.FileUUID], data)
+
+		_, ok = f.fakes[0].chunk(ref.FileUUID, ref.Index)
+		assert.False(t, ok, "old copy must be deleted")
+	}
+}
+
+func TestRebalancer_Threshold(t *testing.T) {
+	f := newRebalancerFixture(t, 2)
+	f.addFile("file1", 0, bytes.Repeat([]byte("a"), 100))
+	f.addFile("file2", 0, bytes.Repeat([]byte("b"), 100))
+
+	r := NewRebalancer(Config{Interval: time.Hour, Threshold: 1000}, f.registry, f.allocationMap, http.DefaultClient)
+	assert.Nil(t, r.plan())
+}
+
+func TestRebalancer_DoesNotOvershoot(t *testing.T) {
+	f := newRebalancerFixture(t, 2)
+	// Moving the only chunk would just swap the servers
+	f.addFile("file1", 0, bytes.Repeat([]byte("a"), 100))
+
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	assert.Nil(t, r.plan())
+}
+
+func TestRebalancer_SkipsServersWithTheSameChunk(t *testing.T) {
+	f := newRebalancerFixture(t, 2)
+	// Both servers store a replica of the chunk, the other chunk is too large to move
+	f.fakes[0].store("file1", 0, []byte(strings.Repeat("a", 40)))
+	f.fakes[1].store("file1", 0, []byte(strings.Repeat("a", 40)))
+	f.allocationMap.AddChunkReplicas("file1", [][]*registry_service.ChunkServer{f.servers}, []int64{40})
+	f.registry.AdjustSizes(f.servers, []int64{40, 40}, 80)
+	f.addFile("file2", 0, []byte(strings.Repeat("b", 100)))
+
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	assert.Nil(t, r.plan())
+}
+
+func TestRebalancer_MovesChunkToServerWithOtherChunksOfTheFile(t *testing.T) {
+	f := newRebalancerFixture(t, 2)
+	f.fakes[1].store("file1", 0, []byte(strings.Repeat("a", 10)))
+	f.fakes[0].store("file1", 1, []byte(strings.Repeat("b", 40)))
+	f.allocationMap.AddChunk("file1", []*registry_service.ChunkServer{f.servers[1], f.servers[0]}, []int64{10, 40})
+	f.registry.AdjustSizes([]*registry_service.ChunkServer{f.servers[1], f.servers[0]}, []int64{10, 40}, 50)
+	f.addFile("file2", 0, []byte(strings.Repeat("c", 100)))
+
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	r.rebalance(context.Background())
+
+	assert.Equal(t, int64(1), r.Status().MovedChunks)
+	assert.Equal(t, []*registry_service.ChunkServer{f.servers[1], f.servers[1]}, f.allocationMap.GetChunkServers("file1"))
+	data, ok := f.fakes[1].chunk("file1", 1)
+	assert.True(t, ok)
+	assert.Equal(t, []byte(strings.Repeat("b", 40)), data)
+}
+
+func TestRebalancer_Modes(t *testing.T) {
+	f := newRebalancerFixture(t, 3)
+	f.addFile("file1", 0, bytes.Repeat([]byte("a"), 40))
+	f.addFile("file2", 0, bytes.Repeat([]byte("b"), 40))
+	f.addFile("file3", 0, bytes.Repeat([]byte("c"), 40))
+	f.addFile("file4", 1, bytes.Repeat([]byte("d"), 10))
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+
+	t.Run("Read-only servers are not targets", func(t *testing.T) {
+		f.registry.SetMode(f.servers[2], registry_service.ChunkServerReadOnly)
+		move := r.plan()
+		require.NotNil(t, move)
+		assert.Equal(t, f.servers[1], move.to)
+	})
+
+	t.Run("Chunks stay on servers in maintenance", func(t *testing.T) {
+		f.registry.SetMode(f.servers[0], registry_service.ChunkServerMaintenance)
+		assert.Nil(t, r.plan())
+
+		_, err := f.registry.DrainChunkServer(f.servers[0].Address())
+		require.NoError(t, err)
+		assert.Nil(t, r.plan())
+	})
+
+	t.Run("Draining resumes after maintenance", func(t *testing.T) {
+		f.registry.SetMode(f.servers[0], registry_service.ChunkServerReadWrite)
+		move := r.plan()
+		require.NotNil(t, move)
+		assert.Equal(t, MoveReasonDrain, move.Reason)
+		assert.Equal(t, f.servers[1], move.to)
+	})
+
+	t.Run("Offline servers are neither drained nor targets", func(t *testing.T) {
+		_, err := f.registry.DeregisterChunkServer("", f.servers[0].Address())
+		require.NoError(t, err)
+		assert.Nil(t, r.plan())
+
+		_, _, err = f.registry.RegisterChunkServer("", f.servers[0].Address())
+		require.NoError(t, err)
+		_, err = f.registry.DeregisterChunkServer("", f.servers[1].Address())
+		require.NoError(t, err)
+		assert.Nil(t, r.plan())
+	})
+}
+
+func TestRebalancer_Pause(t *testing.T) {
+	f := newRebalancerFixture(t, 2)
+	f.addFile("file1", 0, bytes.Repeat([]byte("a"), 100))
+	f.addFile("file2", 0, bytes.Repeat([]byte("b"), 100))
+
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	r.Pause()
+	r.rebalance(context.Background())
+	assert.True(t, r.Status().Paused)
+	assert.Zero(t, r.Status().MovedChunks)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	r.Resume()
+	assert.Eventually(t, func() bool {
+		return r.Status().MovedChunks == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.False(t, r.Status().Paused)
+
+	cancel()
+	<-done
+}
+
+func TestRebalancer_FailedMigration(t *testing.T) {
+	f := newRebalancerFixture(t, 2)
+	f.addFile("file1", 0, bytes.Repeat([]byte("a"), 100))
+	f.addFile("file2", 0, bytes.Repeat([]byte("b"), 100))
+	f.fakes[1].Close()
+
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	r.rebalance(context.Background())
+
+	status := r.Status()
+	assert.Equal(t, int64(1), status.FailedMoves)
+	assert.NotEmpty(t, status.LastError)
+	assert.Equal(t, int64(200), f.servers[0].Size())
+	assert.Empty(t, f.allocationMap.ChunksOnServer(f.servers[1]))
+}
+
+func TestThrottledReader(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 300)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, newThrottledReader(context.Background(), bytes.NewReader(data), 1000))
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), n)
+	assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+}
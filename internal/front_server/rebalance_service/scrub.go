@@ -0,0 +1,361 @@
+package rebalance_service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+)
+
+var (
+	ErrScrubRunning    = errors.New("scrub is already running")
+	ErrReplicaNotFound = errors.New("chunk replica not found")
+	ErrStopped         = errors.New("rebalancer is stopped")
+)
+
+// ReplicaProblem describes a replica of a chunk that is missing or damaged on its chunk server.
+type ReplicaProblem struct {
+	FileUUID    string `json:"file_uuid"`
+	Index       int    `json:"index"`
+	Size        int64  `json:"size"`
+	Server      string `json:"server"`
+	Error       string `json:"error"`
+	Repaired    bool   `json:"repaired"`
+	RepairError string `json:"repair_error,omitempty"`
+}
+
+// ScrubStatus describes the running or the last finished scrub.
+type ScrubStatus struct {
+	Running    bool             `json:"running"`
+	Repair     bool             `json:"repair"`
+	StartedAt  *time.Time       `json:"started_at,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	Objects    int              `json:"objects"`
+	Replicas   int              `json:"replicas"`
+	Problems   []ReplicaProblem `json:"problems"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// ReplicaReport describes a replica of a chunk read back from its chunk server.
+type ReplicaReport struct {
+	Server string `json:"server"`
+	OK     bool   `json:"ok"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ChunkReport describes all replicas of a chunk. The chunk is consistent if all readable replicas are equal.
+type ChunkReport struct {
+	Index      int             `json:"index"`
+	Size       int64           `json:"size"`
+	Consistent bool            `json:"consistent"`
+	Replicas   []ReplicaReport `json:"replicas"`
+}
+
+// ObjectReport is the result of verifying every replica of every chunk of a file.
+type ObjectReport struct {
+	UUID    string        `json:"uuid"`
+	Size    int64         `json:"size"`
+	Healthy bool          `json:"healthy"`
+	Chunks  []ChunkReport `json:"chunks"`
+}
+
+// StartScrub checks in the background that every replica of every chunk is present on its chunk server
+// with the expected size. With repair, damaged replicas are copied again from a healthy replica.
+func (r *Rebalancer) StartScrub(repair bool) (ScrubStatus, error) {
+	r.mu.Lock()
+	if r.scrub.Running {
+		r.mu.Unlock()
+		return ScrubStatus{}, ErrScrubRunning
+	}
+	now := time.Now()
+	r.scrub = ScrubStatus{Running: true, Repair: repair, StartedAt: &now}
+	r.mu.Unlock()
+
+	ctx, cancel := r.untilStopped()
+	go func() {
+		defer cancel()
+		err := r.runScrub(ctx, repair)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		now := time.Now()
+		r.scrub.Running = false
+		r.scrub.FinishedAt = &now
+		if err != nil {
+			r.scrub.Error = err.Error()
+		}
+	}()
+
+	return r.ScrubStatus(), nil
+}
+
+// untilStopped returns a context that is cancelled when the rebalancer stops.
+func (r *Rebalancer) untilStopped() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-r.stopped:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func (r *Rebalancer) ScrubStatus() ScrubStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.scrub
+	status.Problems = append([]ReplicaProblem{}, r.scrub.Problems...)
+	return status
+}
+
+func (r *Rebalancer) runScrub(ctx context.Context, repair bool) error {
+	r.logger.Info("Scrub started", slog.Bool("repair", repair))
+
+	for _, fileUUID := range r.allocationMap.FileUUIDs() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		chunks := r.allocationMap.GetChunks(fileUUID)
+		healthy := make(map[int]*registry_service.ChunkServer)
+		var problems []ReplicaProblem
+		for _, chunk := range chunks {
+			err := r.checkReplica(ctx, fileUUID, chunk)
+			// The chunk might have been migrated or deleted in the meantime
+			if err != nil && r.allocationMap.HasReplica(fileUUID, chunk.Index, chunk.Server) {
+				problems = append(problems, ReplicaProblem{
+					FileUUID: fileUUID,
+					Index:    chunk.Index,
+					Size:     chunk.Size,
+					Server:   chunk.Server.Address(),
+					Error:    err.Error(),
+				})
+				r.logger.Warn("Damaged chunk replica",
+					slog.String("uuid", fileUUID),
+					slog.Int("chunk", chunk.Index),
+					slog.String("server", chunk.Server.Address()),
+					slog.Any("error", err))
+				continue
+			}
+			if err == nil {
+				healthy[chunk.Index] = chunk.Server
+			}
+		}
+
+		if repair {
+			for i := range problems {
+				r.repairReplica(ctx, &problems[i], healthy[problems[i].Index])
+			}
+		}
+
+		r.mu.Lock()
+		r.scrub.Objects++
+		r.scrub.Replicas += len(chunks)
+		r.scrub.Problems = append(r.scrub.Problems, problems...)
+		r.mu.Unlock()
+	}
+
+	status := r.ScrubStatus()
+	r.logger.Info("Scrub finished",
+		slog.Int("objects", status.Objects),
+		slog.Int("replicas", status.Replicas),
+		slog.Int("problems", len(status.Problems)))
+	return nil
+}
+
+// checkReplica asks the chunk server for the size of the chunk without reading it.
+func (r *Rebalancer) checkReplica(ctx context.Context, fileUUID string, chunk registry_service.ChunkLocation) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, chunkURL(chunk.Server, "/get", fileUUID, chunk.Index), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HEAD request: %w", err)
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send HEAD request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-OK HTTP status: %d", resp.StatusCode)
+	}
+	if resp.ContentLength != chunk.Size {
+		return fmt.Errorf("chunk server has %d bytes, expected %d", resp.ContentLength, chunk.Size)
+	}
+	return nil
+}
+
+// repairReplica copies the chunk from a healthy replica to the chunk server of the damaged one.
+func (r *Rebalancer) repairReplica(ctx context.Context, problem *ReplicaProblem, source *registry_service.ChunkServer) {
+	if source == nil {
+		problem.RepairError = "no healthy replica"
+		return
+	}
+	target, err := r.registry.GetChunkServer(problem.Server)
+	if err != nil {
+		problem.RepairError = err.Error()
+		return
+	}
+
+	m := &Move{
+		FileUUID: problem.FileUUID,
+		Index:    problem.Index,
+		Size:     problem.Size,
+		From:     source.Address(),
+		To:       target.Address(),
+		Reason:   MoveReasonRepair,
+		from:     source,
+		to:       target,
+	}
+	r.logger.Info("Repairing chunk replica",
+		slog.String("uuid", m.FileUUID),
+		slog.Int("chunk", m.Index),
+		slog.String("from", m.From),
+		slog.String("to", m.To))
+
+	checksum, err := r.copyChunk(ctx, m)
+	if err == nil {
+		err = r.verifyChunk(ctx, m, checksum)
+	}
+	if err != nil {
+		problem.RepairError = err.Error()
+		r.logger.Error("Failed to repair chunk replica", slog.String("uuid", m.FileUUID), slog.Any("error", err))
+		return
+	}
+	problem.Repaired = true
+}
+
+// RepairCorruptReplica copies the chunk again to the chunk server that found its replica corrupted and removed it.
+// The copy runs in the background, from the first other replica that is present with the expected size.
+func (r *Rebalancer) RepairCorruptReplica(serverURL string, fileUUID string, index int) error {
+	server, err := r.registry.GetChunkServer(serverURL)
+	if err != nil {
+		return err
+	}
+	var (
+		damaged *registry_service.ChunkLocation
+		others  []registry_service.ChunkLocation
+	)
+	for _, chunk := range r.allocationMap.GetChunks(fileUUID) {
+		switch {
+		case chunk.Index != index:
+		case chunk.Server == server:
+			damaged = &chunk
+		default:
+			others = append(others, chunk)
+		}
+	}
+	if damaged == nil {
+		return ErrReplicaNotFound
+	}
+
+	problem := ReplicaProblem{
+		FileUUID: fileUUID,
+		Index:    index,
+		Size:     damaged.Size,
+		Server:   server.Address(),
+		Error:    "corrupted",
+	}
+	r.logger.Warn("Corrupted chunk replica reported",
+		slog.String("uuid", fileUUID),
+		slog.Int("chunk", index),
+		slog.String("server", server.Address()))
+
+	r.mu.Lock()
+	if r.stopping {
+		r.mu.Unlock()
+		return ErrStopped
+	}
+	r.pendingRepairs.Add(1)
+	r.mu.Unlock()
+
+	ctx, cancel := r.untilStopped()
+	go func() {
+		defer r.pendingRepairs.Done()
+		defer cancel()
+
+		var source *registry_service.ChunkServer
+		for _, other := range others {
+			if r.checkReplica(ctx, fileUUID, other) == nil {
+				source = other.Server
+				break
+			}
+		}
+		r.repairReplica(ctx, &problem, source)
+		if problem.Repaired {
+			r.logger.Info("Repaired corrupted chunk replica", slog.String("uuid", fileUUID), slog.Int("chunk", index))
+		} else {
+			r.logger.Error("Failed to repair corrupted chunk replica",
+				slog.String("uuid", fileUUID),
+				slog.Int("chunk", index),
+				slog.String("server", problem.Server),
+				slog.String("error", problem.RepairError))
+		}
+	}()
+	return nil
+}
+
+// Verify reads every replica of every chunk of the file and compares their checksums.
+func (r *Rebalancer) Verify(ctx context.Context, fileUUID string) (ObjectReport, error) {
+	chunks := r.allocationMap.GetChunks(fileUUID)
+	if chunks == nil {
+		return ObjectReport{}, registry_service.ErrFileNotFound
+	}
+
+	report := ObjectReport{UUID: fileUUID, Healthy: true}
+	for _, chunk := range chunks {
+		if len(report.Chunks) == 0 || report.Chunks[len(report.Chunks)-1].Index != chunk.Index {
+			report.Chunks = append(report.Chunks, ChunkReport{Index: chunk.Index, Size: chunk.Size, Consistent: true})
+			report.Size += chunk.Size
+		}
+		chunkReport := &report.Chunks[len(report.Chunks)-1]
+
+		replica := r.readReplica(ctx, fileUUID, chunk)
+		for _, other := range chunkReport.Replicas {
+			if replica.OK && other.OK && other.SHA256 != replica.SHA256 {
+				chunkReport.Consistent = false
+			}
+		}
+		chunkReport.Replicas = append(chunkReport.Replicas, replica)
+		report.Healthy = report.Healthy && replica.OK && chunkReport.Consistent
+	}
+	return report, nil
+}
+
+func (r *Rebalancer) readReplica(ctx context.Context, fileUUID string, chunk registry_service.ChunkLocation) ReplicaReport {
+	report := ReplicaReport{Server: chunk.Server.Address()}
+
+	resp, err := r.get(ctx, chunk.Server, fileUUID, chunk.Index)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	defer resp.Body.Close()
+
+	hash := sha256.New()
+	report.Size, err = io.Copy(hash, resp.Body)
+	report.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	switch {
+	case err != nil:
+		report.Error = fmt.Sprintf("failed to read chunk: %v", err)
+	case report.Size != chunk.Size:
+		report.Error = fmt.Sprintf("chunk server has %d bytes, expected %d", report.Size, chunk.Size)
+	default:
+		report.OK = true
+	}
+	return report
+}
@@ -0,0 +1,149 @@
+package rebalance_service
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"simple-s3-adventure/internal/front_server/registry_service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addReplicatedFile stores a single-chunk file on every given server.
+func (f *rebalancerFixture) addReplicatedFile(uuid string, servers []int, data []byte) {
+	replicas := make([]*registry_service.ChunkServer, len(servers))
+	for i, server := range servers {
+		f.fakes[server].store(uuid, 0, data)
+		replicas[i] = f.servers[server]
+	}
+	f.allocationMap.AddChunkReplicas(uuid, [][]*registry_service.ChunkServer{replicas}, []int64{int64(len(data))})
+}
+
+func waitForScrub(t *testing.T, r *Rebalancer) ScrubStatus {
+	require.Eventually(t, func() bool {
+		return !r.ScrubStatus().Running
+	}, 5*time.Second, 10*time.Millisecond)
+	return r.ScrubStatus()
+}
+
+func TestRebalancer_Scrub(t *testing.T) {
+	f := newRebalancerFixture(t, 3)
+	f.addReplicatedFile("file1", []int{0, 1}, bytes.Repeat([]byte("a"), 100))
+	f.addReplicatedFile("file2", []int{1, 2}, bytes.Repeat([]byte("b"), 100))
+	// Damage one replica of every file
+	f.fakes[1].store("file1", 0, []byte("truncated"))
+	f.fakes[2].Close()
+
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	_, err := r.StartScrub(false)
+	require.NoError(t, err)
+
+	status := waitForScrub(t, r)
+	assert.Equal(t, 2, status.Objects)
+	assert.Equal(t, 4, status.Replicas)
+	require.Len(t, status.Problems, 2)
+	assert.ElementsMatch(t, []string{f.servers[1].Address(), f.servers[2].Address()},
+		[]string{status.Problems[0].Server, status.Problems[1].Server})
+	for _, problem := range status.Problems {
+		assert.False(t, problem.Repaired)
+	}
+	assert.NotNil(t, status.FinishedAt)
+}
+
+func TestRebalancer_ScrubRepair(t *testing.T) {
+	f := newRebalancerFixture(t, 2)
+	data := bytes.Repeat([]byte("a"), 100)
+	f.addReplicatedFile("file1", []int{0, 1}, data)
+	f.addFile("file2", 0, []byte("lost"))
+	f.fakes[1].store("file1", 0, []byte("truncated"))
+	f.fakes[0].store("file2", 0, nil)
+
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	_, err := r.StartScrub(true)
+	require.NoError(t, err)
+
+	status := waitForScrub(t, r)
+	require.Len(t, status.Problems, 2)
+	for _, problem := range status.Problems {
+		switch problem.FileUUID {
+		case "file1":
+			assert.True(t, problem.Repaired)
+		case "file2":
+			assert.False(t, problem.Repaired)
+			assert.Equal(t, "no healthy replica", problem.RepairError)
+		}
+	}
+
+	repaired, ok := f.fakes[1].chunk("file1", 0)
+	assert.True(t, ok)
+	assert.Equal(t, data, repaired)
+}
+
+func TestRebalancer_ScrubAlreadyRunning(t *testing.T) {
+	f := newRebalancerFixture(t, 1)
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	r.mu.Lock()
+	r.scrub.Running = true
+	r.mu.Unlock()
+
+	_, err := r.StartScrub(false)
+	assert.ErrorIs(t, err, ErrScrubRunning)
+}
+
+func TestRebalancer_Verify(t *testing.T) {
+	f := newRebalancerFixture(t, 3)
+	f.addReplicatedFile("file1", []int{0, 1}, []byte("data"))
+	f.addReplicatedFile("file2", []int{1, 2}, []byte("data"))
+	f.fakes[2].store("file2", 0, []byte("DATA"))
+
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+
+	report, err := r.Verify(context.Background(), "file1")
+	require.NoError(t, err)
+	assert.True(t, report.Healthy)
+	assert.Equal(t, int64(4), report.Size)
+	require.Len(t, report.Chunks, 1)
+	assert.True(t, report.Chunks[0].Consistent)
+	require.Len(t, report.Chunks[0].Replicas, 2)
+	assert.Equal(t, "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7", report.Chunks[0].Replicas[0].SHA256)
+
+	report, err = r.Verify(context.Background(), "file2")
+	require.NoError(t, err)
+	assert.False(t, report.Healthy)
+	assert.False(t, report.Chunks[0].Consistent)
+
+	_, err = r.Verify(context.Background(), "unknown")
+	assert.ErrorIs(t, err, registry_service.ErrFileNotFound)
+}
+
+func TestRebalancer_RepairCorruptReplica(t *testing.T) {
+	f := newRebalancerFixture(t, 3)
+	data := bytes.Repeat([]byte("a"), 100)
+	f.addReplicatedFile("file1", []int{0, 1, 2}, data)
+	// The chunk server quarantined its replica, and another replica is damaged as well
+	f.fakes[1].mu.Lock()
+	delete(f.fakes[1].chunks, "file1_0")
+	f.fakes[1].mu.Unlock()
+	f.fakes[0].store("file1", 0, []byte("truncated"))
+
+	r := NewRebalancer(Config{Interval: time.Hour}, f.registry, f.allocationMap, http.DefaultClient)
+	require.NoError(t, r.RepairCorruptReplica(f.servers[1].Address(), "file1", 0))
+	require.Eventually(t, func() bool {
+		repaired, ok := f.fakes[1].chunk("file1", 0)
+		return ok && bytes.Equal(data, repaired)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.ErrorIs(t, r.RepairCorruptReplica(f.servers[1].Address(), "file1", 1), ErrReplicaNotFound)
+	assert.ErrorIs(t, r.RepairCorruptReplica(f.servers[1].Address(), "unknown", 0), ErrReplicaNotFound)
+	assert.ErrorIs(t, r.RepairCorruptReplica("http://unknown:1", "file1", 0), registry_service.ErrChunkServerNotFound)
+
+	// No repair starts once the rebalancer is stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.Run(ctx)
+	assert.ErrorIs(t, r.RepairCorruptReplica(f.servers[1].Address(), "file1", 0), ErrStopped)
+}
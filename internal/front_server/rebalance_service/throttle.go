@@ -0,0 +1,45 @@
+package rebalance_service
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// throttledReader limits the rate at which data is read from the underlying reader.
+type throttledReader struct {
+	ctx   context.Context
+	r     io.Reader
+	limit int64 // bytes per second, 0 means unlimited
+	start time.Time
+	read  int64
+}
+
+func newThrottledReader(ctx context.Context, r io.Reader, limit int64) *throttledReader {
+	return &throttledReader{ctx: ctx, r: r, limit: limit, start: time.Now()}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if t.limit <= 0 {
+		return t.r.Read(p)
+	}
+
+	// Don't read more than one second worth of data at once
+	if int64(len(p)) > t.limit {
+		p = p[:t.limit]
+	}
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+
+	expected := time.Duration(float64(t.read) / float64(t.limit) * float64(time.Second))
+	if wait := expected - time.Since(t.start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		}
+	}
+	return n, err
+}
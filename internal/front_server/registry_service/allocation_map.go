@@ -0,0 +1,227 @@
+package registry_service
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrFileNotFound = errors.New("file not found")
+
+// ChunkLocation describes where a single replica of a chunk of a file is stored.
+type ChunkLocation struct {
+	Index  int
+	Size   int64
+	Server *ChunkServer
+}
+
+// ChunkRef identifies a chunk of a particular file.
+type ChunkRef struct {
+	FileUUID string
+	ChunkLocation
+}
+
+// FileInfo is the metadata of a file.
+type FileInfo struct {
+	Name      string
+	CreatedAt time.Time
+}
+
+// ChunkAllocationMap is a map of file UUIDs to their parts.
+type ChunkAllocationMap struct {
+	chunks map[string][]ChunkLocation
+	files  map[string]FileInfo
+	mu     sync.RWMutex
+}
+
+func NewChunkAllocationMap() *ChunkAllocationMap {
+	return &ChunkAllocationMap{
+		chunks: make(map[string][]ChunkLocation),
+		files:  make(map[string]FileInfo),
+	}
+}
+
+// AddChunk stores the location of every chunk of the file. The i-th chunk of size sizes[i] is stored on servers[i].
+func (c *ChunkAllocationMap) AddChunk(fileUUID string, servers []*ChunkServer, sizes []int64) {
+	replicas := make([][]*ChunkServer, len(servers))
+	for i, server := range servers {
+		replicas[i] = []*ChunkServer{server}
+	}
+	c.AddChunkReplicas(fileUUID, replicas, sizes)
+}
+
+// AddChunkReplicas stores the locations of every chunk of the file. The i-th chunk of size sizes[i]
+// is stored on every server of replicas[i].
+func (c *ChunkAllocationMap) AddChunkReplicas(fileUUID string, replicas [][]*ChunkServer, sizes []int64) {
+	c.AddFile(fileUUID, FileInfo{}, replicas, sizes)
+}
+
+// AddFile stores the metadata of the file together with the locations of its chunks, see AddChunkReplicas.
+func (c *ChunkAllocationMap) AddFile(fileUUID string, info FileInfo, replicas [][]*ChunkServer, sizes []int64) {
+	var locations []ChunkLocation
+	for i, servers := range replicas {
+		for _, server := range servers {
+			locations = append(locations, ChunkLocation{Index: i, Size: sizes[i], Server: server})
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.chunks[fileUUID] = locations
+	c.files[fileUUID] = info
+}
+
+// GetFileInfo returns the metadata of the file.
+func (c *ChunkAllocationMap) GetFileInfo(fileUUID string) (FileInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, ok := c.chunks[fileUUID]; !ok {
+		return FileInfo{}, false
+	}
+	return c.files[fileUUID], true
+}
+
+// RemoveFile removes the file and returns the locations of its chunks, or nil if the file does not exist.
+func (c *ChunkAllocationMap) RemoveFile(fileUUID string) []ChunkLocation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	locations := c.chunks[fileUUID]
+	delete(c.chunks, fileUUID)
+	delete(c.files, fileUUID)
+	return locations
+}
+
+func (c *ChunkAllocationMap) GetChunkServers(fileUUID string) []*ChunkServer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	locations, ok := c.chunks[fileUUID]
+	if !ok {
+		return nil
+	}
+	servers := make([]*ChunkServer, len(locations))
+	for i, location := range locations {
+		servers[i] = location.Server
+	}
+	return servers
+}
+
+// GetChunks returns a copy of the chunk locations of the file in chunk order.
+// A replicated chunk has a location for every replica.
+func (c *ChunkAllocationMap) GetChunks(fileUUID string) []ChunkLocation {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	locations, ok := c.chunks[fileUUID]
+	if !ok {
+		return nil
+	}
+	return append([]ChunkLocation(nil), locations...)
+}
+
+// ChunksOnServer returns all chunks stored on the given chunk server.
+func (c *ChunkAllocationMap) ChunksOnServer(server *ChunkServer) []ChunkRef {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var refs []ChunkRef
+	for fileUUID, locations := range c.chunks {
+		for _, location := range locations {
+			if location.Server == server {
+				refs = append(refs, ChunkRef{FileUUID: fileUUID, ChunkLocation: location})
+			}
+		}
+	}
+	return refs
+}
+
+// IsStoredOn reports whether any chunk of the file is stored on the given chunk server. A server can hold
+// several chunks of a file, use HasReplica to ask about a single chunk.
+func (c *ChunkAllocationMap) IsStoredOn(fileUUID string, server *ChunkServer) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, location := range c.chunks[fileUUID] {
+		if location.Server == server {
+			return true
+		}
+	}
+	return false
+}
+
+// HasReplica reports whether the chunk of the file is stored on the given chunk server.
+func (c *ChunkAllocationMap) HasReplica(fileUUID string, index int, server *ChunkServer) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, location := range c.chunks[fileUUID] {
+		if location.Index == index && location.Server == server {
+			return true
+		}
+	}
+	return false
+}
+
+// MoveChunk switches the location of a chunk replica from one chunk server to another.
+// It returns false if the file no longer exists, the chunk is not stored on the expected server anymore
+// or the target already stores a replica of the chunk. The target may store other chunks of the file.
+func (c *ChunkAllocationMap) MoveChunk(fileUUID string, index int, from *ChunkServer, to *ChunkServer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	moved := -1
+	for i, location := range c.chunks[fileUUID] {
+		if location.Index != index {
+			continue
+		}
+		if location.Server == to {
+			return false
+		}
+		if location.Server == from {
+			moved = i
+		}
+	}
+	if moved < 0 {
+		return false
+	}
+
+	c.chunks[fileUUID][moved].Server = to
+	return true
+}
+
+// FileUUIDs returns the UUIDs of all files.
+func (c *ChunkAllocationMap) FileUUIDs() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	uuids := make([]string, 0, len(c.chunks))
+	for fileUUID := range c.chunks {
+		uuids = append(uuids, fileUUID)
+	}
+	return uuids
+}
+
+// Len returns the number of files.
+func (c *ChunkAllocationMap) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.chunks)
+}
+
+// ChunkCounts returns the number of chunks stored on every chunk server.
+func (c *ChunkAllocationMap) ChunkCounts() map[*ChunkServer]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	counts := make(map[*ChunkServer]int)
+	for _, locations := range c.chunks {
+		for _, location := range locations {
+			counts[location.Server]++
+		}
+	}
+	return counts
+}
@@ -0,0 +1,141 @@
+package registry_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkAllocationMap_GetChunks(t *testing.T) {
+	cam := NewChunkAllocationMap()
+	server1 := NewChunkServer("http://chunkserver1")
+	server2 := NewChunkServer("http://chunkserver2")
+	cam.AddChunk("file1", []*ChunkServer{server1, server2}, []int64{10, 20})
+
+	chunks := cam.GetChunks("file1")
+	assert.Equal(t, []ChunkLocation{
+		{Index: 0, Size: 10, Server: server1},
+		{Index: 1, Size: 20, Server: server2},
+	}, chunks)
+
+	// The result is a copy
+	chunks[0].Server = server2
+	assert.Equal(t, server1, cam.GetChunks("file1")[0].Server)
+
+	assert.Nil(t, cam.GetChunks("file2"))
+}
+
+func TestChunkAllocationMap_ChunksOnServer(t *testing.T) {
+	cam := NewChunkAllocationMap()
+	server1 := NewChunkServer("http://chunkserver1")
+	server2 := NewChunkServer("http://chunkserver2")
+	server3 := NewChunkServer("http://chunkserver3")
+	cam.AddChunk("file1", []*ChunkServer{server1, server2}, []int64{10, 20})
+	cam.AddChunk("file2", []*ChunkServer{server2, server1}, []int64{30, 40})
+
+	refs := cam.ChunksOnServer(server1)
+	assert.ElementsMatch(t, []ChunkRef{
+		{FileUUID: "file1", ChunkLocation: ChunkLocation{Index: 0, Size: 10, Server: server1}},
+		{FileUUID: "file2", ChunkLocation: ChunkLocation{Index: 1, Size: 40, Server: server1}},
+	}, refs)
+
+	assert.Empty(t, cam.ChunksOnServer(server3))
+}
+
+func TestChunkAllocationMap_MoveChunk(t *testing.T) {
+	cam := NewChunkAllocationMap()
+	server1 := NewChunkServer("http://chunkserver1")
+	server2 := NewChunkServer("http://chunkserver2")
+	server3 := NewChunkServer("http://chunkserver3")
+	cam.AddChunk("file1", []*ChunkServer{server1, server2}, []int64{10, 20})
+
+	t.Run("Move to a server without chunks of the file", func(t *testing.T) {
+		assert.True(t, cam.MoveChunk("file1", 0, server1, server3))
+		assert.Equal(t, []*ChunkServer{server3, server2}, cam.GetChunkServers("file1"))
+		assert.True(t, cam.IsStoredOn("file1", server3))
+		assert.False(t, cam.IsStoredOn("file1", server1))
+	})
+
+	t.Run("Chunk is not on the expected server", func(t *testing.T) {
+		assert.False(t, cam.MoveChunk("file1", 0, server1, server3))
+	})
+
+	t.Run("Move to the server holding another chunk of the file", func(t *testing.T) {
+		assert.True(t, cam.MoveChunk("file1", 1, server2, server3))
+		assert.Equal(t, []*ChunkServer{server3, server3}, cam.GetChunkServers("file1"))
+		assert.True(t, cam.HasReplica("file1", 0, server3))
+		assert.True(t, cam.HasReplica("file1", 1, server3))
+		assert.False(t, cam.IsStoredOn("file1", server2))
+	})
+
+	t.Run("Target already stores the chunk", func(t *testing.T) {
+		cam.AddChunkReplicas("replicated", [][]*ChunkServer{{server1, server2}}, []int64{10})
+		assert.False(t, cam.MoveChunk("replicated", 0, server1, server2))
+	})
+
+	t.Run("Unknown file or index", func(t *testing.T) {
+		assert.False(t, cam.MoveChunk("file2", 0, server1, server3))
+		assert.False(t, cam.MoveChunk("file1", 5, server1, server3))
+	})
+}
+
+func TestChunkAllocationMap_ChunkCounts(t *testing.T) {
+	cam := NewChunkAllocationMap()
+	server1 := NewChunkServer("http://chunkserver1")
+	server2 := NewChunkServer("http://chunkserver2")
+	cam.AddChunk("file1", []*ChunkServer{server1, server2}, []int64{10, 20})
+	cam.AddChunk("file2", []*ChunkServer{server1}, []int64{30})
+
+	assert.Equal(t, map[*ChunkServer]int{server1: 2, server2: 1}, cam.ChunkCounts())
+}
+
+func TestChunkAllocationMap_Replicas(t *testing.T) {
+	cam := NewChunkAllocationMap()
+	server1 := NewChunkServer("http://chunkserver1")
+	server2 := NewChunkServer("http://chunkserver2")
+	server3 := NewChunkServer("http://chunkserver3")
+	server4 := NewChunkServer("http://chunkserver4")
+	server5 := NewChunkServer("http://chunkserver5")
+	cam.AddChunkReplicas("file1", [][]*ChunkServer{{server1, server2}, {server3, server4}}, []int64{10, 20})
+
+	assert.Equal(t, []ChunkLocation{
+		{Index: 0, Size: 10, Server: server1},
+		{Index: 0, Size: 10, Server: server2},
+		{Index: 1, Size: 20, Server: server3},
+		{Index: 1, Size: 20, Server: server4},
+	}, cam.GetChunks("file1"))
+
+	// Only the replica on the given server is moved
+	assert.False(t, cam.MoveChunk("file1", 1, server2, server5))
+	assert.True(t, cam.MoveChunk("file1", 0, server2, server5))
+	assert.Equal(t, []*ChunkServer{server1, server5, server3, server4}, cam.GetChunkServers("file1"))
+
+	assert.True(t, cam.HasReplica("file1", 1, server3))
+	assert.False(t, cam.HasReplica("file1", 0, server3))
+	assert.False(t, cam.HasReplica("file1", 0, server2))
+	assert.False(t, cam.HasReplica("file2", 0, server1))
+}
+
+func TestChunkAllocationMap_RemoveFile(t *testing.T) {
+	cam := NewChunkAllocationMap()
+	server1 := NewChunkServer("http://chunkserver1")
+	server2 := NewChunkServer("http://chunkserver2")
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	cam.AddFile("file1", FileInfo{Name: "docs/a.txt", CreatedAt: createdAt}, [][]*ChunkServer{{server1}, {server2}}, []int64{10, 20})
+
+	info, ok := cam.GetFileInfo("file1")
+	assert.True(t, ok)
+	assert.Equal(t, FileInfo{Name: "docs/a.txt", CreatedAt: createdAt}, info)
+
+	assert.Equal(t, []ChunkLocation{
+		{Index: 0, Size: 10, Server: server1},
+		{Index: 1, Size: 20, Server: server2},
+	}, cam.RemoveFile("file1"))
+
+	_, ok = cam.GetFileInfo("file1")
+	assert.False(t, ok)
+	assert.Nil(t, cam.GetChunks("file1"))
+	assert.Nil(t, cam.RemoveFile("file1"))
+	assert.Equal(t, 0, cam.Len())
+}
@@ -3,6 +3,7 @@ package registry_service
 import (
 	"container/list"
 	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
 	"sync/atomic"
@@ -17,47 +18,166 @@ const (
 
 var (
 	ErrChunkServerAlreadyRegistered = errors.New("chunk server already registered")
+	ErrChunkServerNotFound          = errors.New("chunk server not found")
+	ErrInvalidChunkServerMode       = errors.New("invalid chunk server mode")
+	ErrChunkServerNotRemovable      = errors.New("chunk server is not drained")
 )
 
+// ChunkServerState is the lifecycle state of a chunk server.
+type ChunkServerState int32
+
+const (
+	// ChunkServerActive servers receive new chunks.
+	ChunkServerActive ChunkServerState = iota
+	// ChunkServerDraining servers don't receive new chunks, their chunks are migrated to other servers.
+	ChunkServerDraining
+	// ChunkServerRemovable servers are drained and don't store any chunks.
+	ChunkServerRemovable
+)
+
+func (s ChunkServerState) String() string {
+	switch s {
+	case ChunkServerActive:
+		return "active"
+	case ChunkServerDraining:
+		return "draining"
+	case ChunkServerRemovable:
+		return "removable"
+	default:
+		return "unknown"
+	}
+}
+
+// ChunkServerMode is reported by the chunk server and tells which writes it accepts. Unlike the state,
+// it is set by the chunk server itself, e.g. while one of its disks is replaced.
+type ChunkServerMode string
+
+const (
+	// ChunkServerReadWrite servers accept new chunks.
+	ChunkServerReadWrite ChunkServerMode = "read_write"
+	// ChunkServerReadOnly servers serve and delete chunks, but reject new ones.
+	ChunkServerReadOnly ChunkServerMode = "read_only"
+	// ChunkServerMaintenance servers only serve chunks.
+	ChunkServerMaintenance ChunkServerMode = "maintenance"
+)
+
+// ParseChunkServerMode returns the mode with the given name. Chunk servers that don't report a mode are read-write.
+func ParseChunkServerMode(s string) (ChunkServerMode, error) {
+	switch mode := ChunkServerMode(s); mode {
+	case "":
+		return ChunkServerReadWrite, nil
+	case ChunkServerReadWrite, ChunkServerReadOnly, ChunkServerMaintenance:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w %q", ErrInvalidChunkServerMode, s)
+	}
+}
+
 type ChunkServer struct {
-	Address string
-	size    int64
+	// id is the persistent identity of the chunk server. It is empty for chunk servers that don't report one.
+	id atomic.Pointer[string]
+	// address can change when a chunk server with a known ID registers from a new location.
+	address atomic.Pointer[string]
+	// grpcAddress is the host:port of the gRPC chunk API. It is empty for chunk servers that don't serve it.
+	grpcAddress atomic.Pointer[string]
+	size        int64
+	state       atomic.Int32
+	mode        atomic.Pointer[ChunkServerMode]
+	// offline is set when the chunk server deregisters on shutdown, and cleared when it registers again.
+	offline atomic.Bool
+
+	// drainInitialSize is the size of the server when draining started. Guarded by the registry mutex.
+	drainInitialSize int64
+}
+
+func NewChunkServer(address string) *ChunkServer {
+	cs := &ChunkServer{}
+	cs.setAddress(address)
+	return cs
+}
+
+// Address returns the base URL of the chunk server.
+func (cs *ChunkServer) Address() string {
+	if address := cs.address.Load(); address != nil {
+		return *address
+	}
+	return ""
+}
+
+func (cs *ChunkServer) setAddress(address string) {
+	cs.address.Store(&address)
+}
+
+// GRPCAddress returns the host:port of the gRPC chunk API of the chunk server.
+func (cs *ChunkServer) GRPCAddress() string {
+	if address := cs.grpcAddress.Load(); address != nil {
+		return *address
+	}
+	return ""
+}
+
+// SetGRPCAddress sets the host:port of the gRPC chunk API, which changes when the chunk server registers again.
+func (cs *ChunkServer) SetGRPCAddress(address string) {
+	cs.grpcAddress.Store(&address)
+}
+
+// ID returns the persistent identity of the chunk server.
+func (cs *ChunkServer) ID() string {
+	if id := cs.id.Load(); id != nil {
+		return *id
+	}
+	return ""
+}
+
+func (cs *ChunkServer) setID(id string) {
+	cs.id.Store(&id)
 }
 
 func (cs *ChunkServer) addSize(size int64) {
 	atomic.AddInt64(&cs.size, size)
 }
 
-// ChunkAllocationMap is a map of file UUIDs to their parts.
-type ChunkAllocationMap struct {
-	chunks map[string][]*ChunkServer
-	mu     sync.RWMutex
+// Size returns the number of bytes stored on the chunk server.
+func (cs *ChunkServer) Size() int64 {
+	return atomic.LoadInt64(&cs.size)
 }
 
-func NewChunkAllocationMap() *ChunkAllocationMap {
-	return &ChunkAllocationMap{
-		chunks: make(map[string][]*ChunkServer),
-	}
+func (cs *ChunkServer) State() ChunkServerState {
+	return ChunkServerState(cs.state.Load())
 }
 
-func (c *ChunkAllocationMap) AddChunk(fileUUID string, servers []*ChunkServer) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (cs *ChunkServer) setState(state ChunkServerState) {
+	cs.state.Store(int32(state))
+}
 
-	c.chunks[fileUUID] = servers
+// Mode returns the mode last reported by the chunk server.
+func (cs *ChunkServer) Mode() ChunkServerMode {
+	if mode := cs.mode.Load(); mode != nil {
+		return *mode
+	}
+	return ChunkServerReadWrite
 }
 
-func (c *ChunkAllocationMap) GetChunkServers(fileUUID string) []*ChunkServer {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+func (cs *ChunkServer) setMode(mode ChunkServerMode) {
+	cs.mode.Store(&mode)
+}
 
-	return c.chunks[fileUUID]
+// Offline reports whether the chunk server deregistered and hasn't registered again since.
+func (cs *ChunkServer) Offline() bool {
+	return cs.offline.Load()
+}
+
+// AcceptsChunks reports whether new chunks can be placed on the chunk server: it is online, active and read-write.
+func (cs *ChunkServer) AcceptsChunks() bool {
+	return !cs.Offline() && cs.State() == ChunkServerActive && cs.Mode() == ChunkServerReadWrite
 }
 
 // ChunkServerRegistry is a catalog of chunk servers.
 type ChunkServerRegistry struct {
 	// chunkServerAddresses is a set of chunk server addresses. We use it to ensure the uniqueness.
 	chunkServerAddresses map[string]struct{}
+	// chunkServerIDs maps persistent chunk server IDs to chunk servers.
+	chunkServerIDs map[string]*ChunkServer
 
 	// chunkServers is a round-robin list of chunk servers.
 	chunkServers *list.List
@@ -65,30 +185,268 @@ type ChunkServerRegistry struct {
 
 	totalSize int64
 
+	// fillFactor is how far above the average size a chunk server can be and still be considered underloaded.
+	fillFactor float64
+
 	mu sync.RWMutex
 }
 
-func NewChunkServerRegistry() *ChunkServerRegistry {
-	return &ChunkServerRegistry{
+// RegistryOption configures a ChunkServerRegistry.
+type RegistryOption func(*ChunkServerRegistry)
+
+// WithFillFactor overrides the default fill factor. The value must be greater than 1.
+func WithFillFactor(fillFactor float64) RegistryOption {
+	return func(c *ChunkServerRegistry) {
+		c.fillFactor = fillFactor
+	}
+}
+
+func NewChunkServerRegistry(opts ...RegistryOption) *ChunkServerRegistry {
+	c := &ChunkServerRegistry{
 		chunkServerAddresses: make(map[string]struct{}),
+		chunkServerIDs:       make(map[string]*ChunkServer),
 		chunkServers:         list.New(),
+		fillFactor:           fillFactor,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
+// AddChunkServer registers a chunk server that has no persistent ID.
 func (c *ChunkServerRegistry) AddChunkServer(url string) error {
+	_, _, err := c.RegisterChunkServer("", url)
+	return err
+}
+
+// RegisterChunkServer registers a chunk server with the given ID and URL.
+// If a chunk server with the same ID is already registered, it is treated as the same node coming back,
+// possibly at a new address: its address is updated and it keeps its data. In this case reregistered is true.
+// A chunk server without ID that deregistered comes back the same way at its old address.
+func (c *ChunkServerRegistry) RegisterChunkServer(id string, url string) (server *ChunkServer, reregistered bool, err error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if server, exists := c.chunkServerIDs[id]; exists && id != "" {
+		if server.Address() != url {
+			if _, exists := c.chunkServerAddresses[url]; exists {
+				return nil, false, ErrChunkServerAlreadyRegistered
+			}
+			delete(c.chunkServerAddresses, server.Address())
+			c.chunkServerAddresses[url] = struct{}{}
+			server.setAddress(url)
+		}
+		server.offline.Store(false)
+		return server, true, nil
+	}
+
 	if _, exists := c.chunkServerAddresses[url]; exists {
-		return ErrChunkServerAlreadyRegistered
+		e := c.findChunkServer(url)
+		server := e.Value.(*ChunkServer)
+		// A chunk server registered without ID has been upgraded and now reports one
+		if id != "" && server.ID() == "" {
+			server.setID(id)
+			c.chunkServerIDs[id] = server
+			server.offline.Store(false)
+			return server, true, nil
+		}
+		if id == "" && server.ID() == "" && server.Offline() {
+			server.offline.Store(false)
+			return server, true, nil
+		}
+		return nil, false, ErrChunkServerAlreadyRegistered
 	}
 
+	server = NewChunkServer(url)
+	server.setID(id)
 	c.chunkServerAddresses[url] = struct{}{}
-	c.chunkServers.PushBack(&ChunkServer{Address: url, size: 0})
+	if id != "" {
+		c.chunkServerIDs[id] = server
+	}
+	c.chunkServers.PushBack(server)
+
+	return server, false, nil
+}
 
+// ChunkServers returns a snapshot of the registered chunk servers in round-robin order.
+func (c *ChunkServerRegistry) ChunkServers() []*ChunkServer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	servers := make([]*ChunkServer, 0, c.chunkServers.Len())
+	for e := c.chunkServers.Front(); e != nil; e = e.Next() {
+		servers = append(servers, e.Value.(*ChunkServer))
+	}
+	return servers
+}
+
+// NextChunkServer returns the chunk server the next selection starts from, or nil if there are no chunk servers.
+func (c *ChunkServerRegistry) NextChunkServer() *ChunkServer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	next := c.nextServer
+	if next == nil {
+		next = c.chunkServers.Front()
+	}
+	if next == nil {
+		return nil
+	}
+	return next.Value.(*ChunkServer)
+}
+
+// SizeThreshold returns the size below which a chunk server is considered underloaded, 0 if there are no chunk servers.
+func (c *ChunkServerRegistry) SizeThreshold() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.chunkServerAddresses) == 0 {
+		return 0
+	}
+	return sizeThreshold(c.totalSize, int64(len(c.chunkServerAddresses)), c.registryFillFactor())
+}
+
+// GetChunkServer returns the chunk server registered with the given URL.
+func (c *ChunkServerRegistry) GetChunkServer(url string) (*ChunkServer, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e := c.findChunkServer(url)
+	if e == nil {
+		return nil, ErrChunkServerNotFound
+	}
+	return e.Value.(*ChunkServer), nil
+}
+
+// SetMode records the mode reported by the chunk server.
+func (c *ChunkServerRegistry) SetMode(server *ChunkServer, mode ChunkServerMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	server.setMode(mode)
+}
+
+// DeregisterChunkServer marks the chunk server offline when it shuts down. It keeps its chunks, but new
+// chunks aren't placed on it until it registers again. The ID must be the one the chunk server registered
+// with, so a stale chunk server can't take another node at its old address offline.
+func (c *ChunkServerRegistry) DeregisterChunkServer(id string, url string) (*ChunkServer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.findChunkServer(url)
+	if e == nil {
+		return nil, ErrChunkServerNotFound
+	}
+	server := e.Value.(*ChunkServer)
+	if server.ID() != id {
+		return nil, ErrChunkServerNotFound
+	}
+	server.offline.Store(true)
+	return server, nil
+}
+
+// DrainChunkServer stops placing new chunks on the chunk server. The chunks it stores have to be
+// migrated to other servers before it can be removed.
+func (c *ChunkServerRegistry) DrainChunkServer(url string) (*ChunkServer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.findChunkServer(url)
+	if e == nil {
+		return nil, ErrChunkServerNotFound
+	}
+	server := e.Value.(*ChunkServer)
+	if server.State() == ChunkServerActive {
+		server.drainInitialSize = server.Size()
+		server.setState(ChunkServerDraining)
+	}
+	return server, nil
+}
+
+// ActivateChunkServer cancels draining of the chunk server.
+func (c *ChunkServerRegistry) ActivateChunkServer(url string) (*ChunkServer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.findChunkServer(url)
+	if e == nil {
+		return nil, ErrChunkServerNotFound
+	}
+	server := e.Value.(*ChunkServer)
+	server.drainInitialSize = 0
+	server.setState(ChunkServerActive)
+	return server, nil
+}
+
+// SetDrained switches a draining chunk server to the removable state once it stores no chunks,
+// and back to draining if it got new chunks, e.g. from uploads that started before draining.
+func (c *ChunkServerRegistry) SetDrained(server *ChunkServer, drained bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case drained && server.State() == ChunkServerDraining:
+		server.setState(ChunkServerRemovable)
+	case !drained && server.State() == ChunkServerRemovable:
+		server.setState(ChunkServerDraining)
+	}
+}
+
+// DrainProgress returns the size of the chunk server when draining started.
+func (c *ChunkServerRegistry) DrainProgress(server *ChunkServer) (initialSize int64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return server.drainInitialSize
+}
+
+// RemoveChunkServer removes a drained chunk server from the registry.
+func (c *ChunkServerRegistry) RemoveChunkServer(url string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.findChunkServer(url)
+	if e == nil {
+		return ErrChunkServerNotFound
+	}
+	server := e.Value.(*ChunkServer)
+	if server.State() != ChunkServerRemovable {
+		return ErrChunkServerNotRemovable
+	}
+
+	if c.nextServer == e {
+		c.nextServer = e.Next()
+	}
+	c.chunkServers.Remove(e)
+	delete(c.chunkServerAddresses, url)
+	if server.ID() != "" {
+		delete(c.chunkServerIDs, server.ID())
+	}
+	c.totalSize -= server.Size()
+	return nil
+}
+
+func (c *ChunkServerRegistry) findChunkServer(url string) *list.Element {
+	if _, exists := c.chunkServerAddresses[url]; !exists {
+		return nil
+	}
+	for e := c.chunkServers.Front(); e != nil; e = e.Next() {
+		if e.Value.(*ChunkServer).Address() == url {
+			return e
+		}
+	}
 	return nil
 }
 
+// TotalSize returns the number of bytes stored across all chunk servers.
+func (c *ChunkServerRegistry) TotalSize() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.totalSize
+}
+
 // AdjustSizes adjusts the sizes of the chunk servers.
 func (c *ChunkServerRegistry) AdjustSizes(servers []*ChunkServer, sizes []int64, totalSize int64) {
 	for i, size := range sizes {
@@ -104,12 +462,16 @@ func (c *ChunkServerRegistry) AdjustSizes(servers []*ChunkServer, sizes []int64,
 // SelectUnderloadedChunkServers selects n underloaded chunk servers.
 // An underloaded chunk server is a server whose size is less than the threshold.
 // If there are not enough underloaded servers, it selects the servers with size greater than the threshold.
+// Only active chunk servers are selected.
 func (c *ChunkServerRegistry) SelectUnderloadedChunkServers(n int) []*ChunkServer {
 	lg := logger.GetLogger()
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	// The round-robin cursor is moved, so a write lock is required
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	if len(c.chunkServerAddresses) < n {
+	// Whether a server accepts chunks is evaluated once, so the selection can't run out of candidates
+	writable := c.writableChunkServers()
+	if len(c.chunkServerAddresses) < n || len(writable) < n {
 		return nil
 	}
 
@@ -117,7 +479,7 @@ func (c *ChunkServerRegistry) SelectUnderloadedChunkServers(n int) []*ChunkServe
 	chunkServersMap := make(map[string]struct{}, n)
 	chunkServers := make([]*ChunkServer, 0, n)
 
-	sizeThreshold := sizeThreshold(c.totalSize, int64(len(c.chunkServerAddresses)), fillFactor)
+	sizeThreshold := sizeThreshold(c.totalSize, int64(len(c.chunkServerAddresses)), c.registryFillFactor())
 	lg.Info("Selecting servers",
 		slog.Int64("totalSize", c.totalSize),
 		slog.Int("numOfServers", len(c.chunkServerAddresses)),
@@ -127,7 +489,7 @@ func (c *ChunkServerRegistry) SelectUnderloadedChunkServers(n int) []*ChunkServe
 	if c.nextServer == nil {
 		c.nextServer = c.chunkServers.Front()
 	}
-	startFromServer := c.nextServer.Value.(*ChunkServer).Address
+	startFromServer := c.nextServer.Value.(*ChunkServer).Address()
 	firstRound := true
 	readyToGetOversized := false
 
@@ -139,8 +501,8 @@ func (c *ChunkServerRegistry) SelectUnderloadedChunkServers(n int) []*ChunkServe
 		}
 
 		for ; c.nextServer != nil; c.nextServer = c.nextServer.Next() {
-			address := c.nextServer.Value.(*ChunkServer).Address
-			serverSize := c.nextServer.Value.(*ChunkServer).size
+			address := c.nextServer.Value.(*ChunkServer).Address()
+			serverSize := c.nextServer.Value.(*ChunkServer).Size()
 			if address == startFromServer {
 				if !firstRound {
 					// We have gone through all servers once, so now we are ready get servers with size > threshold
@@ -153,6 +515,9 @@ func (c *ChunkServerRegistry) SelectUnderloadedChunkServers(n int) []*ChunkServe
 			if _, ok := chunkServersMap[address]; ok {
 				continue
 			}
+			if _, ok := writable[c.nextServer.Value.(*ChunkServer)]; !ok {
+				continue
+			}
 			willBeSelected := serverSize < sizeThreshold || readyToGetOversized
 
 			lg.Info("Checking server",
@@ -176,6 +541,26 @@ func (c *ChunkServerRegistry) SelectUnderloadedChunkServers(n int) []*ChunkServe
 	return nil
 }
 
+// writableChunkServers returns the set of chunk servers that accept new chunks.
+func (c *ChunkServerRegistry) writableChunkServers() map[*ChunkServer]struct{} {
+	writable := make(map[*ChunkServer]struct{}, c.chunkServers.Len())
+	for e := c.chunkServers.Front(); e != nil; e = e.Next() {
+		if server := e.Value.(*ChunkServer); server.AcceptsChunks() {
+			writable[server] = struct{}{}
+		}
+	}
+	return writable
+}
+
+// registryFillFactor returns the configured fill factor, falling back to the default
+// for registries that were not created with NewChunkServerRegistry.
+func (c *ChunkServerRegistry) registryFillFactor() float64 {
+	if c.fillFactor == 0 {
+		return fillFactor
+	}
+	return c.fillFactor
+}
+
 // sizeThreshold calculates the threshold for the size of the chunk server.
 // try to choose chunk servers with size less than threshold
 func sizeThreshold(totalSize int64, numOfServers int64, fillFactor float64) int64 {
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestChunkServerRegistry_AddChunkServer(t *testing.T) {
@@ -26,8 +27,8 @@ func TestChunkServerRegistry_AddChunkServer(t *testing.T) {
 func TestChunkServerRegistry_AdjustSizes(t *testing.T) {
 	registry := NewChunkServerRegistry()
 
-	server1 := &ChunkServer{Address: "http://chunkserver1", size: 0}
-	server2 := &ChunkServer{Address: "http://chunkserver2", size: 0}
+	server1 := newTestChunkServer("http://chunkserver1", 0)
+	server2 := newTestChunkServer("http://chunkserver2", 0)
 	registry.chunkServers.PushBack(server1)
 	registry.chunkServers.PushBack(server2)
 
@@ -43,9 +44,9 @@ func TestChunkServerRegistry_AdjustSizes(t *testing.T) {
 func TestChunkServerRegistry_SelectUnderloadedChunkServers(t *testing.T) {
 	registry := NewChunkServerRegistry()
 
-	server1 := &ChunkServer{Address: "http://chunkserver1", size: 50}
-	server2 := &ChunkServer{Address: "http://chunkserver2", size: 100}
-	server3 := &ChunkServer{Address: "http://chunkserver3", size: 150}
+	server1 := newTestChunkServer("http://chunkserver1", 50)
+	server2 := newTestChunkServer("http://chunkserver2", 100)
+	server3 := newTestChunkServer("http://chunkserver3", 150)
 
 	registry.chunkServerAddresses["http://chunkserver1"] = struct{}{}
 	registry.chunkServerAddresses["http://chunkserver2"] = struct{}{}
@@ -81,9 +82,9 @@ func TestChunkServerRegistry_SelectUnderloadedChunkServers(t *testing.T) {
 func TestChunkAllocationMap(t *testing.T) {
 	cam := NewChunkAllocationMap()
 
-	chunkServer1 := &ChunkServer{Address: "http://chunkserver1", size: 0}
-	chunkServer2 := &ChunkServer{Address: "http://chunkserver2", size: 0}
-	cam.AddChunk("file1", []*ChunkServer{chunkServer1, chunkServer2})
+	chunkServer1 := newTestChunkServer("http://chunkserver1", 0)
+	chunkServer2 := newTestChunkServer("http://chunkserver2", 0)
+	cam.AddChunk("file1", []*ChunkServer{chunkServer1, chunkServer2}, []int64{10, 20})
 
 	t.Run("Get chunk servers for existing file", func(t *testing.T) {
 		servers := cam.GetChunkServers("file1")
@@ -109,7 +110,7 @@ func TestSizeThreshold(t *testing.T) {
 }
 
 func TestChunkServer_AddSize(t *testing.T) {
-	server := &ChunkServer{Address: "http://chunkserver1", size: 0}
+	server := newTestChunkServer("http://chunkserver1", 0)
 	server.addSize(100)
 	assert.Equal(t, int64(100), server.size)
 
@@ -139,8 +140,8 @@ func TestChunkServerRegistry_AddChunkServerConcurrency(t *testing.T) {
 func TestChunkServerRegistry_AdjustSizesConcurrency(t *testing.T) {
 	registry := NewChunkServerRegistry()
 
-	server1 := &ChunkServer{Address: "http://chunkserver1", size: 0}
-	server2 := &ChunkServer{Address: "http://chunkserver2", size: 0}
+	server1 := newTestChunkServer("http://chunkserver1", 0)
+	server2 := newTestChunkServer("http://chunkserver2", 0)
 	registry.chunkServers.PushBack(server1)
 	registry.chunkServers.PushBack(server2)
 
@@ -169,9 +170,9 @@ func TestChunkServerRegistry_AdjustSizesConcurrency(t *testing.T) {
 func TestChunkServerRegistry_SelectUnderloadedChunkServersWithWrapAround(t *testing.T) {
 	registry := NewChunkServerRegistry()
 
-	server1 := &ChunkServer{Address: "http://chunkserver1", size: 50}
-	server2 := &ChunkServer{Address: "http://chunkserver2", size: 60}
-	server3 := &ChunkServer{Address: "http://chunkserver3", size: 70}
+	server1 := newTestChunkServer("http://chunkserver1", 50)
+	server2 := newTestChunkServer("http://chunkserver2", 60)
+	server3 := newTestChunkServer("http://chunkserver3", 70)
 
 	registry.chunkServerAddresses["http://chunkserver1"] = struct{}{}
 	registry.chunkServerAddresses["http://chunkserver2"] = struct{}{}
@@ -193,9 +194,9 @@ func TestChunkServerRegistry_SelectUnderloadedChunkServersWithWrapAround(t *test
 func TestChunkServerRegistry_SelectAfterAddingNewServer(t *testing.T) {
 	registry := NewChunkServerRegistry()
 
-	server1 := &ChunkServer{Address: "http://chunkserver1", size: 50}
-	server2 := &ChunkServer{Address: "http://chunkserver2", size: 50}
-	server3 := &ChunkServer{Address: "http://chunkserver3", size: 0}
+	server1 := newTestChunkServer("http://chunkserver1", 50)
+	server2 := newTestChunkServer("http://chunkserver2", 50)
+	server3 := newTestChunkServer("http://chunkserver3", 0)
 
 	registry.chunkServerAddresses["http://chunkserver1"] = struct{}{}
 	registry.chunkServerAddresses["http://chunkserver2"] = struct{}{}
@@ -221,8 +222,8 @@ func TestChunkServerRegistry_SelectUnderloadedChunkServersConcurrency(t *testing
 
 	serverCount := 10
 	for i := 0; i < serverCount; i++ {
-		server := &ChunkServer{Address: "http://chunkserver" + string(rune(i)), size: int64(i * 10)}
-		registry.chunkServerAddresses[server.Address] = struct{}{}
+		server := newTestChunkServer("http://chunkserver"+string(rune(i)), int64(i*10))
+		registry.chunkServerAddresses[server.Address()] = struct{}{}
 		registry.chunkServers.PushBack(server)
 		registry.totalSize += int64(i * 10)
 	}
@@ -275,10 +276,10 @@ func TestChunkServerRegistry_AddAndSelectConcurrency(t *testing.T) {
 func TestChunkServerRegistry_AdjustAndSelectConcurrency(t *testing.T) {
 	registry := NewChunkServerRegistry()
 
-	server1 := &ChunkServer{Address: "http://chunkserver1", size: 0}
-	server2 := &ChunkServer{Address: "http://chunkserver2", size: 0}
-	registry.chunkServerAddresses[server1.Address] = struct{}{}
-	registry.chunkServerAddresses[server2.Address] = struct{}{}
+	server1 := newTestChunkServer("http://chunkserver1", 0)
+	server2 := newTestChunkServer("http://chunkserver2", 0)
+	registry.chunkServerAddresses[server1.Address()] = struct{}{}
+	registry.chunkServerAddresses[server2.Address()] = struct{}{}
 	registry.chunkServers.PushBack(server1)
 	registry.chunkServers.PushBack(server2)
 
@@ -326,3 +327,264 @@ func TestChunkServerRegistry_AddChunkServerHighConcurrency(t *testing.T) {
 	wg.Wait()
 	assert.Equal(t, serverCount, len(registry.chunkServerAddresses))
 }
+
+func TestChunkServerRegistry_WithFillFactor(t *testing.T) {
+	registry := NewChunkServerRegistry(WithFillFactor(2))
+	assert.Equal(t, float64(2), registry.fillFactor)
+
+	registry = NewChunkServerRegistry()
+	assert.Equal(t, fillFactor, registry.fillFactor)
+}
+
+func TestChunkServerRegistry_ChunkServers(t *testing.T) {
+	registry := NewChunkServerRegistry()
+	assert.NoError(t, registry.AddChunkServer("http://chunkserver1"))
+	assert.NoError(t, registry.AddChunkServer("http://chunkserver2"))
+
+	servers := registry.ChunkServers()
+	assert.Len(t, servers, 2)
+	assert.Equal(t, "http://chunkserver1", servers[0].Address())
+	assert.Equal(t, "http://chunkserver2", servers[1].Address())
+
+	registry.AdjustSizes(servers, []int64{10, 20}, 30)
+	assert.Equal(t, int64(10), servers[0].Size())
+	assert.Equal(t, int64(30), registry.TotalSize())
+}
+
+func TestChunkServerRegistry_Drain(t *testing.T) {
+	registry := NewChunkServerRegistry()
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, registry.AddChunkServer("http://chunkserver"+strconv.Itoa(i)))
+	}
+	servers := registry.ChunkServers()
+	registry.AdjustSizes(servers, []int64{100, 100, 100}, 300)
+
+	server, err := registry.DrainChunkServer("http://chunkserver2")
+	assert.NoError(t, err)
+	assert.Equal(t, servers[1], server)
+	assert.Equal(t, ChunkServerDraining, server.State())
+	assert.Equal(t, int64(100), registry.DrainProgress(server))
+
+	t.Run("Draining servers are not selected", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			selected := registry.SelectUnderloadedChunkServers(2)
+			assert.Len(t, selected, 2)
+			assert.NotContains(t, selected, server)
+		}
+		assert.Nil(t, registry.SelectUnderloadedChunkServers(3))
+	})
+
+	t.Run("Only drained servers can be removed", func(t *testing.T) {
+		assert.ErrorIs(t, registry.RemoveChunkServer("http://chunkserver2"), ErrChunkServerNotRemovable)
+
+		registry.SetDrained(server, true)
+		assert.Equal(t, ChunkServerRemovable, server.State())
+		registry.SetDrained(server, false)
+		assert.Equal(t, ChunkServerDraining, server.State())
+		registry.SetDrained(server, true)
+
+		// Chunks were migrated to the first server
+		registry.AdjustSizes([]*ChunkServer{server, servers[0]}, []int64{-100, 100}, 0)
+		assert.NoError(t, registry.RemoveChunkServer("http://chunkserver2"))
+		assert.Len(t, registry.ChunkServers(), 2)
+		assert.Equal(t, int64(300), registry.TotalSize())
+
+		_, err := registry.GetChunkServer("http://chunkserver2")
+		assert.ErrorIs(t, err, ErrChunkServerNotFound)
+		assert.NoError(t, registry.AddChunkServer("http://chunkserver2"))
+	})
+
+	t.Run("Unknown server", func(t *testing.T) {
+		_, err := registry.DrainChunkServer("http://unknown")
+		assert.ErrorIs(t, err, ErrChunkServerNotFound)
+		assert.ErrorIs(t, registry.RemoveChunkServer("http://unknown"), ErrChunkServerNotFound)
+	})
+}
+
+func TestChunkServerRegistry_ActivateChunkServer(t *testing.T) {
+	registry := NewChunkServerRegistry()
+	assert.NoError(t, registry.AddChunkServer("http://chunkserver1"))
+
+	server, err := registry.DrainChunkServer("http://chunkserver1")
+	assert.NoError(t, err)
+	assert.Nil(t, registry.SelectUnderloadedChunkServers(1))
+
+	_, err = registry.ActivateChunkServer("http://chunkserver1")
+	assert.NoError(t, err)
+	assert.Equal(t, ChunkServerActive, server.State())
+	assert.Equal(t, []*ChunkServer{server}, registry.SelectUnderloadedChunkServers(1))
+}
+
+func TestChunkServerRegistry_DeregisterChunkServer(t *testing.T) {
+	registry := NewChunkServerRegistry()
+	nodeID := "123e4567-e89b-12d3-a456-426614174000"
+	server, _, err := registry.RegisterChunkServer(nodeID, "http://chunkserver1")
+	require.NoError(t, err)
+	require.NoError(t, registry.AddChunkServer("http://chunkserver2"))
+	registry.AdjustSizes([]*ChunkServer{server}, []int64{100}, 100)
+
+	t.Run("Wrong ID", func(t *testing.T) {
+		_, err := registry.DeregisterChunkServer("", "http://chunkserver1")
+		assert.ErrorIs(t, err, ErrChunkServerNotFound)
+		_, err = registry.DeregisterChunkServer(nodeID, "http://unknown")
+		assert.ErrorIs(t, err, ErrChunkServerNotFound)
+		assert.False(t, server.Offline())
+	})
+
+	t.Run("Offline servers are not selected", func(t *testing.T) {
+		deregistered, err := registry.DeregisterChunkServer(nodeID, "http://chunkserver1")
+		require.NoError(t, err)
+		assert.Equal(t, server, deregistered)
+		assert.True(t, server.Offline())
+		assert.False(t, server.AcceptsChunks())
+		assert.Len(t, registry.SelectUnderloadedChunkServers(1), 1)
+		assert.Nil(t, registry.SelectUnderloadedChunkServers(2))
+	})
+
+	t.Run("Registering again brings the server back with its data", func(t *testing.T) {
+		registered, reregistered, err := registry.RegisterChunkServer(nodeID, "http://chunkserver1-moved")
+		require.NoError(t, err)
+		assert.True(t, reregistered)
+		assert.Equal(t, server, registered)
+		assert.False(t, server.Offline())
+		assert.Equal(t, int64(100), server.Size())
+		assert.Len(t, registry.SelectUnderloadedChunkServers(2), 2)
+	})
+
+	t.Run("Server without ID comes back at its address", func(t *testing.T) {
+		assert.ErrorIs(t, registry.AddChunkServer("http://chunkserver2"), ErrChunkServerAlreadyRegistered)
+		server, err := registry.DeregisterChunkServer("", "http://chunkserver2")
+		require.NoError(t, err)
+		registered, reregistered, err := registry.RegisterChunkServer("", "http://chunkserver2")
+		require.NoError(t, err)
+		assert.True(t, reregistered)
+		assert.Equal(t, server, registered)
+		assert.False(t, server.Offline())
+	})
+}
+
+func TestParseChunkServerMode(t *testing.T) {
+	for name, want := range map[string]ChunkServerMode{
+		"":            ChunkServerReadWrite,
+		"read_write":  ChunkServerReadWrite,
+		"read_only":   ChunkServerReadOnly,
+		"maintenance": ChunkServerMaintenance,
+	} {
+		mode, err := ParseChunkServerMode(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, mode)
+	}
+
+	_, err := ParseChunkServerMode("offline")
+	assert.ErrorIs(t, err, ErrInvalidChunkServerMode)
+}
+
+func TestChunkServerRegistry_SelectSkipsReadOnlyServers(t *testing.T) {
+	registry := NewChunkServerRegistry()
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, registry.AddChunkServer("http://chunkserver"+strconv.Itoa(i)))
+	}
+	servers := registry.ChunkServers()
+	registry.SetMode(servers[0], ChunkServerReadOnly)
+	registry.SetMode(servers[2], ChunkServerMaintenance)
+
+	assert.False(t, servers[0].AcceptsChunks())
+	assert.Equal(t, ChunkServerActive, servers[0].State())
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, []*ChunkServer{servers[1]}, registry.SelectUnderloadedChunkServers(1))
+	}
+	assert.Nil(t, registry.SelectUnderloadedChunkServers(2))
+
+	registry.SetMode(servers[0], ChunkServerReadWrite)
+	assert.Len(t, registry.SelectUnderloadedChunkServers(2), 2)
+}
+
+func TestChunkServerRegistry_SelectWhileModesChange(t *testing.T) {
+	registry := NewChunkServerRegistry()
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, registry.AddChunkServer("http://chunkserver"+strconv.Itoa(i)))
+	}
+	servers := registry.ChunkServers()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			registry.SetMode(servers[i%3], ChunkServerReadOnly)
+			registry.SetMode(servers[i%3], ChunkServerReadWrite)
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		if selected := registry.SelectUnderloadedChunkServers(3); selected != nil {
+			assert.Len(t, selected, 3)
+		}
+	}
+	<-done
+}
+
+func newTestChunkServer(address string, size int64) *ChunkServer {
+	server := NewChunkServer(address)
+	server.size = size
+	return server
+}
+
+func TestChunkServerRegistry_RegisterChunkServer(t *testing.T) {
+	registry := NewChunkServerRegistry()
+
+	server, reregistered, err := registry.RegisterChunkServer("node-1", "http://chunkserver1")
+	assert.NoError(t, err)
+	assert.False(t, reregistered)
+	assert.Equal(t, "node-1", server.ID())
+	registry.AdjustSizes([]*ChunkServer{server}, []int64{100}, 100)
+
+	t.Run("Same node at the same address", func(t *testing.T) {
+		again, reregistered, err := registry.RegisterChunkServer("node-1", "http://chunkserver1")
+		assert.NoError(t, err)
+		assert.True(t, reregistered)
+		assert.Same(t, server, again)
+	})
+
+	t.Run("Same node at a new address keeps its data", func(t *testing.T) {
+		moved, reregistered, err := registry.RegisterChunkServer("node-1", "http://chunkserver1-new")
+		assert.NoError(t, err)
+		assert.True(t, reregistered)
+		assert.Same(t, server, moved)
+		assert.Equal(t, "http://chunkserver1-new", server.Address())
+		assert.Equal(t, int64(100), server.Size())
+		assert.Len(t, registry.ChunkServers(), 1)
+		assert.NotContains(t, registry.chunkServerAddresses, "http://chunkserver1")
+
+		// The old address can be used by another node
+		_, _, err = registry.RegisterChunkServer("node-2", "http://chunkserver1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Another node at a used address", func(t *testing.T) {
+		_, _, err := registry.RegisterChunkServer("node-3", "http://chunkserver1-new")
+		assert.ErrorIs(t, err, ErrChunkServerAlreadyRegistered)
+
+		_, _, err = registry.RegisterChunkServer("node-1", "http://chunkserver1")
+		assert.ErrorIs(t, err, ErrChunkServerAlreadyRegistered)
+	})
+
+	t.Run("Node without ID starts reporting one", func(t *testing.T) {
+		assert.NoError(t, registry.AddChunkServer("http://chunkserver4"))
+		upgraded, reregistered, err := registry.RegisterChunkServer("node-4", "http://chunkserver4")
+		assert.NoError(t, err)
+		assert.True(t, reregistered)
+		assert.Equal(t, "node-4", upgraded.ID())
+	})
+
+	t.Run("Removed node registers as a new one", func(t *testing.T) {
+		_, err := registry.DrainChunkServer("http://chunkserver4")
+		assert.NoError(t, err)
+		upgraded, _ := registry.GetChunkServer("http://chunkserver4")
+		registry.SetDrained(upgraded, true)
+		assert.NoError(t, registry.RemoveChunkServer("http://chunkserver4"))
+
+		server, reregistered, err := registry.RegisterChunkServer("node-4", "http://chunkserver4")
+		assert.NoError(t, err)
+		assert.False(t, reregistered)
+		assert.Equal(t, ChunkServerActive, server.State())
+	})
+}
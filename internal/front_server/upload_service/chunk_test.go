@@ -12,10 +12,10 @@ func TestCreateChunks(t *testing.T) {
 	fileSize := int64(100)
 	offsets := []int64{0, 25, 50, 75}
 	servers := []*registry_service.ChunkServer{
-		{Address: "server1"},
-		{Address: "server2"},
-		{Address: "server3"},
-		{Address: "server4"},
+		registry_service.NewChunkServer("server1"),
+		registry_service.NewChunkServer("server2"),
+		registry_service.NewChunkServer("server3"),
+		registry_service.NewChunkServer("server4"),
 	}
 
 	expectedChunks := []*Chunk{
@@ -0,0 +1,324 @@
+// Package client is a Go client for the API of the front server.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+const defaultMaxRetries = 3
+
+// Object is the metadata of a stored file.
+type Object struct {
+	UUID      string    `json:"uuid"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Client sends requests to the front server. Requests failing with network errors or server errors are retried
+// with exponential backoff. It is safe for concurrent use.
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	maxRetries   uint64
+	newBackOff   func() backoff.BackOff
+	retryUploads bool
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used to send requests, http.DefaultClient by default.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times a failed request is retried, 0 disables retries.
+func WithRetries(maxRetries uint64) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithBackOff sets the policy of delays between retries, exponential backoff by default.
+func WithBackOff(newBackOff func() backoff.BackOff) Option {
+	return func(c *Client) {
+		c.newBackOff = newBackOff
+	}
+}
+
+// WithUploadRetries makes Put retry uploads that failed after they were sent, e.g. with a server error or a broken
+// connection. The front server may have stored the file anyway, so a retried upload can store it twice.
+func WithUploadRetries() Option {
+	return func(c *Client) {
+		c.retryUploads = true
+	}
+}
+
+// New creates a client of the front server at baseURL, e.g. http://localhost:13090.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		maxRetries: defaultMaxRetries,
+		newBackOff: func() backoff.BackOff {
+			return backoff.NewExponentialBackOff()
+		},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Put stores the content of r as a file with the given name and returns the UUID of the file.
+// An upload isn't idempotent, so by default it is only retried if the request never reached the front server,
+// see WithUploadRetries. It is only retried at all if r implements io.Seeker, so it can be read again.
+func (c *Client) Put(ctx context.Context, name string, r io.Reader) (string, error) {
+	seeker, seekable := r.(io.Seeker)
+	var start int64
+	if seekable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+
+	var uuid string
+	attempt := 0
+	err := c.retry(ctx, func() error {
+		attempt++
+		if attempt > 1 {
+			if !seekable {
+				return backoff.Permanent(errors.New("upload cannot be retried, the reader is not seekable"))
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return backoff.Permanent(fmt.Errorf("failed to rewind the reader: %w", err))
+			}
+		}
+
+		var err error
+		uuid, err = c.put(ctx, name, r)
+		if err != nil && !c.retryUploads && !notSent(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	})
+	return uuid, err
+}
+
+// notSent reports whether the request failed because the connection to the front server couldn't be established.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func (c *Client) put(ctx context.Context, name string, r io.Reader) (string, error) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeMultipart(writer, name, r))
+	}()
+	defer pr.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.baseURL+"/put", pr)
+	if err != nil {
+		return "", backoff.Permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var result struct {
+		UUID string `json:"uuid"`
+	}
+	if err := c.do(req, &result); err != nil {
+		return "", err
+	}
+	return result.UUID, nil
+}
+
+func writeMultipart(writer *multipart.Writer, name string, r io.Reader) error {
+	if err := writer.WriteField("name", name); err != nil {
+		return fmt.Errorf("failed to add name field: %w", err)
+	}
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		return fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return fmt.Errorf("failed to copy file to part: %w", err)
+	}
+	return writer.Close()
+}
+
+// Get writes the file to w and returns the number of bytes written.
+// A broken download is resumed after the last byte written to w.
+func (c *Client) Get(ctx context.Context, uuid string, w io.Writer) (int64, error) {
+	return c.GetRange(ctx, uuid, 0, -1, w)
+}
+
+// GetRange writes length bytes of the file starting at offset to w and returns the number of bytes written.
+// A negative length means up to the end of the file.
+func (c *Client) GetRange(ctx context.Context, uuid string, offset, length int64, w io.Writer) (int64, error) {
+	if length == 0 {
+		return 0, nil
+	}
+
+	var written int64
+	err := c.retry(ctx, func() error {
+		n, err := c.get(ctx, uuid, offset+written, length-written, length < 0, w)
+		written += n
+		return err
+	})
+	return written, err
+}
+
+// get writes the file from offset to w. It does not send a Range header if the whole file is requested.
+func (c *Client) get(ctx context.Context, uuid string, offset, length int64, toEnd bool, w io.Writer) (int64, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/get", url.Values{"uuid": {uuid}})
+	if err != nil {
+		return 0, err
+	}
+	expectedStatus := http.StatusPartialContent
+	switch {
+	case !toEnd:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	default:
+		expectedStatus = http.StatusOK
+	}
+
+	resp, err := c.send(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expectedStatus {
+		return 0, backoff.Permanent(fmt.Errorf("unexpected response status: %s", resp.Status))
+	}
+	n, err := io.Copy(w, resp.Body)
+	if err != nil && ctx.Err() != nil {
+		return n, backoff.Permanent(ctx.Err())
+	}
+	if err == nil && resp.ContentLength >= 0 && n != resp.ContentLength {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Delete deletes the file.
+func (c *Client) Delete(ctx context.Context, uuid string) error {
+	attempt := 0
+	err := c.retry(ctx, func() error {
+		attempt++
+		req, err := c.newRequest(ctx, http.MethodDelete, "/delete", url.Values{"uuid": {uuid}})
+		if err != nil {
+			return err
+		}
+		return c.do(req, nil)
+	})
+	// A previous attempt might have deleted the file even though its response was lost
+	if attempt > 1 && errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Stat returns the metadata of the file.
+func (c *Client) Stat(ctx context.Context, uuid string) (Object, error) {
+	var object Object
+	err := c.retry(ctx, func() error {
+		req, err := c.newRequest(ctx, http.MethodGet, "/stat", url.Values{"uuid": {uuid}})
+		if err != nil {
+			return err
+		}
+		return c.do(req, &object)
+	})
+	return object, err
+}
+
+// List returns the files whose name starts with prefix, ordered by name.
+func (c *Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	err := c.retry(ctx, func() error {
+		req, err := c.newRequest(ctx, http.MethodGet, "/list", url.Values{"prefix": {prefix}})
+		if err != nil {
+			return err
+		}
+		return c.do(req, &objects)
+	})
+	return objects, err
+}
+
+// retry runs the operation until it succeeds, fails permanently or the retries are exhausted.
+func (c *Client) retry(ctx context.Context, operation func() error) error {
+	bo := backoff.WithContext(backoff.WithMaxRetries(c.newBackOff(), c.maxRetries), ctx)
+	err := backoff.Retry(operation, bo)
+	if err != nil && ctx.Err() != nil && !errors.Is(err, ctx.Err()) {
+		return fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
+	return err
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, path string, params url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, backoff.Permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	return req, nil
+}
+
+// do sends the request and decodes the JSON response into v unless v is nil.
+func (c *Client) do(req *http.Request, v any) error {
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// send sends the request and returns the response if it is successful. The caller must close the body.
+// Errors that are not worth retrying are wrapped with backoff.Permanent.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if req.Context().Err() != nil {
+			return nil, backoff.Permanent(req.Context().Err())
+		}
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		statusErr := &StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+		if statusErr.temporary() {
+			return nil, statusErr
+		}
+		return nil, backoff.Permanent(statusErr)
+	}
+	return resp, nil
+}
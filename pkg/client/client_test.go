@@ -0,0 +1,301 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	chunkAPI "simple-s3-adventure/internal/chunk_server/api"
+	chunkService "simple-s3-adventure/internal/chunk_server/service"
+	frontAPI "simple-s3-adventure/internal/front_server/api"
+	"simple-s3-adventure/pkg/client"
+	"simple-s3-adventure/pkg/cluster"
+
+	"github.com/cenkalti/backoff"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// numChunkServers is the default number of chunks a file is split into by the front server
+const numChunkServers = 6
+
+// newTestCluster starts a front server with registered chunk servers and returns its handler,
+// so tests can wrap it to inject failures.
+func newTestCluster(t *testing.T) http.Handler {
+	t.Setenv("CLUSTER_TOKEN", "secret")
+	front := httptest.NewServer(frontAPI.NewFrontServer().Handler())
+	t.Cleanup(front.Close)
+
+	for range numChunkServers {
+		id := uuid.New().String()
+		chunkServer := httptest.NewServer(chunkAPI.NewHandler(&chunkService.ServerConfig{
+			UploadDir:     t.TempDir(),
+			MaxUploadSize: 10 << 20,
+		}, chunkService.WithNodeID(id)))
+		t.Cleanup(chunkServer.Close)
+
+		form := url.Values{"url": {chunkServer.URL}, "id": {id}}
+		req, err := http.NewRequest(http.MethodPut, front.URL+"/register_chunk_server", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		cluster.SetToken(req, "secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	return front.Config.Handler
+}
+
+// newTestClient serves the handler and returns a client retrying without delay.
+func newTestClient(t *testing.T, handler http.Handler, opts ...client.Option) *client.Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	opts = append([]client.Option{client.WithBackOff(func() backoff.BackOff { return &backoff.ZeroBackOff{} })}, opts...)
+	return client.New(server.URL, opts...)
+}
+
+func TestClient_PutGetStatDelete(t *testing.T) {
+	c := newTestClient(t, newTestCluster(t))
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	fileUUID, err := c.Put(ctx, "docs/numbers.txt", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	object, err := c.Stat(ctx, fileUUID)
+	require.NoError(t, err)
+	assert.Equal(t, fileUUID, object.UUID)
+	assert.Equal(t, "docs/numbers.txt", object.Name)
+	assert.Equal(t, int64(len(data)), object.Size)
+	assert.False(t, object.CreatedAt.IsZero())
+
+	var buffer bytes.Buffer
+	n, err := c.Get(ctx, fileUUID, &buffer)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, data, buffer.Bytes())
+
+	buffer.Reset()
+	n, err = c.GetRange(ctx, fileUUID, 1995, 10, &buffer)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), n)
+	assert.Equal(t, "5678901234", buffer.String())
+
+	require.NoError(t, c.Delete(ctx, fileUUID))
+	_, err = c.Stat(ctx, fileUUID)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+}
+
+func TestClient_List(t *testing.T) {
+	c := newTestClient(t, newTestCluster(t))
+	ctx := context.Background()
+
+	for _, name := range []string{"images/b.png", "docs/b.txt", "docs/a.txt"} {
+		_, err := c.Put(ctx, name, strings.NewReader("content of "+name))
+		require.NoError(t, err)
+	}
+
+	objects, err := c.List(ctx, "docs/")
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	assert.Equal(t, "docs/a.txt", objects[0].Name)
+	assert.Equal(t, "docs/b.txt", objects[1].Name)
+	assert.Equal(t, int64(len("content of docs/a.txt")), objects[0].Size)
+
+	objects, err = c.List(ctx, "videos/")
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestClient_TypedErrors(t *testing.T) {
+	c := newTestClient(t, newTestCluster(t))
+	ctx := context.Background()
+
+	_, err := c.Get(ctx, uuid.New().String(), io.Discard)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	var statusErr *client.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+
+	_, err = c.Stat(ctx, "not-a-uuid")
+	assert.ErrorIs(t, err, client.ErrInvalidRequest)
+
+	fileUUID, err := c.Put(ctx, "small.txt", strings.NewReader("small"))
+	require.NoError(t, err)
+	_, err = c.GetRange(ctx, fileUUID, 100, 10, io.Discard)
+	assert.ErrorIs(t, err, client.ErrRangeNotSatisfiable)
+}
+
+func TestClient_RetriesServerErrors(t *testing.T) {
+	cluster := newTestCluster(t)
+	var requests atomic.Int32
+	flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1)%2 == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		cluster.ServeHTTP(w, r)
+	})
+	c := newTestClient(t, flaky, client.WithUploadRetries())
+	ctx := context.Background()
+
+	fileUUID, err := c.Put(ctx, "retried.txt", strings.NewReader("retried"))
+	require.NoError(t, err)
+	object, err := c.Stat(ctx, fileUUID)
+	require.NoError(t, err)
+	assert.Equal(t, "retried.txt", object.Name)
+	assert.Equal(t, int32(4), requests.Load())
+}
+
+func TestClient_GivesUpAfterMaxRetries(t *testing.T) {
+	var requests atomic.Int32
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	})
+	server := httptest.NewServer(failing)
+	defer server.Close()
+	c := client.New(server.URL,
+		client.WithRetries(2),
+		client.WithBackOff(func() backoff.BackOff { return &backoff.ZeroBackOff{} }))
+
+	_, err := c.Stat(context.Background(), uuid.New().String())
+	assert.ErrorIs(t, err, client.ErrServer)
+	assert.ErrorContains(t, err, "internal error")
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestClient_PutIsNotRetriedByDefault(t *testing.T) {
+	var requests atomic.Int32
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	c := newTestClient(t, failing)
+
+	_, err := c.Put(context.Background(), "file.txt", strings.NewReader("data"))
+	assert.ErrorIs(t, err, client.ErrServer)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestClient_PutIsRetriedIfNotSent(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	// Nothing listens on the address until the first attempt fails
+	listener.Close()
+
+	var attempts atomic.Int32
+	bo := func() backoff.BackOff {
+		return &onRetry{BackOff: &backoff.ZeroBackOff{}, retry: func() {
+			if attempts.Add(1) > 1 {
+				return
+			}
+			server := httptest.NewUnstartedServer(newTestCluster(t))
+			listener, err := net.Listen("tcp", addr)
+			require.NoError(t, err)
+			server.Listener = listener
+			server.Start()
+			t.Cleanup(server.Close)
+		}}
+	}
+	c := client.New("http://"+addr, client.WithBackOff(bo))
+
+	fileUUID, err := c.Put(context.Background(), "file.txt", strings.NewReader("data"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, fileUUID)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+// onRetry calls retry before every retry.
+type onRetry struct {
+	backoff.BackOff
+	retry func()
+}
+
+func (b *onRetry) NextBackOff() time.Duration {
+	b.retry()
+	return b.BackOff.NextBackOff()
+}
+
+func TestClient_PutFromUnseekableReaderIsNotRetried(t *testing.T) {
+	var requests atomic.Int32
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	c := newTestClient(t, failing, client.WithUploadRetries())
+
+	_, err := c.Put(context.Background(), "file.txt", io.MultiReader(strings.NewReader("data")))
+	assert.ErrorContains(t, err, "not seekable")
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestClient_ResumesBrokenDownload(t *testing.T) {
+	cluster := newTestCluster(t)
+	var mu sync.Mutex
+	var ranges []string
+	var downloads atomic.Int32
+	breaking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/get" {
+			cluster.ServeHTTP(w, r)
+			return
+		}
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		if downloads.Add(1) > 1 {
+			cluster.ServeHTTP(w, r)
+			return
+		}
+
+		// Promise the whole file but send only the first half of it
+		recorder := httptest.NewRecorder()
+		cluster.ServeHTTP(recorder, r)
+		body := recorder.Body.Bytes()
+		for key, values := range recorder.Header() {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(recorder.Code)
+		w.Write(body[:len(body)/2])
+	})
+	c := newTestClient(t, breaking)
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("abcdefghij"), 100)
+
+	fileUUID, err := c.Put(ctx, "broken.txt", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	var buffer bytes.Buffer
+	n, err := c.Get(ctx, fileUUID, &buffer)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, data, buffer.Bytes())
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"", "bytes=500-"}, ranges)
+}
+
+func TestClient_ContextCancellation(t *testing.T) {
+	c := newTestClient(t, newTestCluster(t))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.List(ctx, "")
+	assert.True(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNotFound means the file does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidRequest means the front server rejected the request, e.g. because of an incorrect UUID.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrRangeNotSatisfiable means the requested range is outside of the file.
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+	// ErrServer means the front server failed to process the request. Such requests are retried.
+	ErrServer = errors.New("server error")
+)
+
+// StatusError is returned when the front server responds with an unsuccessful status code.
+// It matches one of the errors above with errors.Is.
+type StatusError struct {
+	StatusCode int
+	// Message is the body of the response
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		return ErrRangeNotSatisfiable
+	case e.temporary():
+		return ErrServer
+	default:
+		return ErrInvalidRequest
+	}
+}
+
+// temporary reports whether the request may succeed if it is sent again.
+func (e *StatusError) temporary() bool {
+	return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+}
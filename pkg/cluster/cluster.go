@@ -0,0 +1,36 @@
+// Package cluster holds what the front server and the chunk servers have to agree on to form a cluster.
+package cluster
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// ProtocolVersion is the version of the API between the front server and the chunk servers. It changes
+// when a server can no longer work with the previous version of the other side.
+const ProtocolVersion = 1
+
+// Handshake is the response of the /handshake endpoint of a chunk server. The front server checks it
+// before it registers the chunk server.
+type Handshake struct {
+	NodeID          string `json:"node_id"`
+	ProtocolVersion int    `json:"protocol_version"`
+}
+
+// SetToken authenticates a request of a chunk server to the front server with the cluster token.
+// Nothing is sent if the token is empty.
+func SetToken(req *http.Request, token string) {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// ValidToken reports whether the request carries the cluster token. No request is valid if the token is empty.
+func ValidToken(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
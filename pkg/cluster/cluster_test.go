@@ -0,0 +1,27 @@
+package cluster
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidToken(t *testing.T) {
+	req := httptest.NewRequest("PUT", "/register_chunk_server", nil)
+	assert.False(t, ValidToken(req, ""))
+	assert.False(t, ValidToken(req, "secret"))
+
+	SetToken(req, "secret")
+	assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+	assert.True(t, ValidToken(req, "secret"))
+	assert.False(t, ValidToken(req, "other"))
+	assert.False(t, ValidToken(req, "secret2"))
+
+	req.Header.Set("Authorization", "secret")
+	assert.False(t, ValidToken(req, "secret"))
+
+	req = httptest.NewRequest("PUT", "/register_chunk_server", nil)
+	SetToken(req, "")
+	assert.Empty(t, req.Header.Get("Authorization"))
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 func GetEnvInt(key string, defaultValue int) int {
@@ -29,3 +30,21 @@ func GetEnvString(key string, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// GetEnvList splits a comma-separated value, ignoring blank items.
+func GetEnvList(key string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	if len(list) == 0 {
+		return defaultValue
+	}
+	return list
+}